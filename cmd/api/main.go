@@ -10,23 +10,55 @@ import (
 
 	"github.com/assaidy/blogging_app/internal/handler"
 	"github.com/assaidy/blogging_app/internal/middleware"
+	"github.com/assaidy/blogging_app/internal/utils"
 	"github.com/gofiber/fiber/v2"
 	_ "github.com/joho/godotenv/autoload"
 	_ "github.com/lib/pq"
 )
 
 func mountRoutes(app *fiber.App) {
+	app.Get("/robots.txt", middleware.Logger, handler.HandleGetRobots)
+	app.Get("/sitemap.xml", middleware.Logger, handler.HandleGetSitemap)
+	app.Get("/sitemaps/:page.xml", middleware.Logger, handler.HandleGetSitemapPage) // listed by /sitemap.xml on large sites
+
+	app.Get("/.well-known/jwks.json", middleware.Logger, handler.HandleGetJWKS) // the public keys of the access tokens
+
+	// ActivityPub federation
+	app.Get("/.well-known/webfinger", middleware.Logger, handler.HandleWebFinger) // ?resource=acct:<username>@<host>
+	ap := app.Group("ap", middleware.Logger)
+	{
+		ap.Get("/users/:user_id", handler.HandleGetActor)
+		ap.Get("/users/:user_id/outbox", handler.HandleGetOutbox) // ?page=true&cursor=<post_id>
+		ap.Get("/users/:user_id/followers", handler.HandleGetActorFollowers)
+		ap.Post("/users/:user_id/inbox", handler.HandleActorInbox)
+		ap.Get("/posts/:post_id", handler.HandleGetArticle)
+	}
+
 	api := app.Group("api", middleware.Logger)
 
 	v1 := api.Group("v1")
 	{
 		v1.Post("/auth/register", handler.HandleRegister)
 		v1.Post("/auth/login", handler.HandleLogin)
-		v1.Get("/auth/access_tokens", handler.HandleGetAccessToken)
+		v1.Post("/auth/access_tokens", handler.HandleRefreshAccessToken)
+		v1.Post("/auth/logout", handler.HandleLogout)
+		v1.Post("/auth/logout_all", middleware.Auth, handler.HandleLogoutAll)
+		v1.Get("/auth/sessions", middleware.Auth, handler.HandleGetAllSessions)
+		v1.Delete("/auth/sessions/:session_id", middleware.Auth, handler.HandleRevokeSession)
+		v1.Post("/auth/mfa/verify", handler.HandleVerifyMfa)
+		v1.Post("/auth/mfa/totp", middleware.Auth, handler.HandleEnrollTotp)
+		v1.Post("/auth/mfa/totp/confirm", middleware.Auth, handler.HandleConfirmTotp)
+		v1.Delete("/auth/mfa/totp", middleware.Auth, handler.HandleDisableTotp)
+		v1.Post("/auth/mfa/recovery_codes", middleware.Auth, handler.HandleRegenerateRecoveryCodes)
 
 		v1.Get("/users/id/:user_id", handler.HandleGetUserById)
 		v1.Get("/users/username/:username", handler.HandleGetUserByUsername)
+		v1.Get("/users/username/:username/posts/:slug", handler.HandleGetPostBySlug)
+		v1.Get("/users/username/:username/feed.:format", handler.HandleGetUserFeed) // format: rss, atom or json
 		v1.Put("/users", middleware.Auth, handler.HandleUpdateUser)
+		v1.Put("/users/email", middleware.Auth, handler.HandleUpdateEmail)
+		v1.Get("/users/email/verify", handler.HandleVerifyEmail) // ?token=<token>, opened from the verification email
+		v1.Put("/users/digest", middleware.Auth, handler.HandleUpdateDigestFrequency)
 		v1.Delete("/users", middleware.Auth, handler.HandleDeleteUser)
 		v1.Get("/users", middleware.Auth, handler.HandleGetAllUsers) // with filtering (used for searching)
 
@@ -38,15 +70,31 @@ func mountRoutes(app *fiber.App) {
 		v1.Get("/posts/:post_id", handler.HandleGetPost)
 		v1.Put("/posts/:post_id", middleware.Auth, handler.HandleUpdatePost)
 		v1.Delete("/posts/:post_id", middleware.Auth, handler.HandleDeletePost)
+		v1.Post("/posts/:post_id/publish", middleware.Auth, handler.HandlePublishPost)
+		v1.Post("/posts/:post_id/unlist", middleware.Auth, handler.HandleUnlistPost)
+		v1.Get("/drafts", middleware.Auth, handler.HandleGetAllDrafts)
+
+		v1.Get("/tags/popular", handler.HandleGetPopularTags)
+		v1.Get("/tags/:slug/posts", handler.HandleGetAllTagPosts)
+
+		v1.Get("/posts/:post_id/revisions", middleware.Auth, handler.HandleGetAllPostRevisions)
+		v1.Get("/posts/:post_id/revisions/:revision_id", middleware.Auth, handler.HandleGetPostRevision)
+		v1.Get("/posts/:post_id/revisions/:revision_id/diff", middleware.Auth, handler.HandleGetPostRevisionsDiff) // ?to=<revision_id>
+		v1.Post("/posts/:post_id/revisions/:revision_id/restore", middleware.Auth, handler.HandleRestorePostRevision)
 		v1.Get("users/:user_id/posts", middleware.Auth, handler.HandleGetAllUserPosts)
 		v1.Get("posts", middleware.Auth, handler.HandleGetAllPosts) // with filtering (used for searching)
 
+		v1.Get("/feed", middleware.Auth, handler.HandleGetFeed)
+		v1.Get("/feed.:format", handler.HandleGetSiteFeed) // format: rss, atom or json
+
 		v1.Post("/posts/:post_id/views", middleware.Auth, handler.HandleViewPost)
 
 		v1.Post("/posts/:post_id/comments", middleware.Auth, handler.HandleCreateComment)
 		v1.Put("/posts/comments/:comment_id", middleware.Auth, handler.HandleUpdateComment)
 		v1.Delete("/posts/comments/:comment_id", middleware.Auth, handler.HandleDeleteComment)
 		v1.Get("/posts/post_id/comments", middleware.Auth, handler.HandleGetAllPostComments)
+		v1.Post("/posts/comments/:comment_id/replies", middleware.Auth, handler.HandleCreateReply)
+		v1.Get("/posts/comments/:comment_id/replies", middleware.Auth, handler.HandleGetAllCommentReplies)
 
 		v1.Post("/posts/:post_id/reaction", middleware.Auth, handler.HandleReact)
 		v1.Delete("/posts/:post_id/reaction", middleware.Auth, handler.HandleDeleteReaction)
@@ -55,9 +103,29 @@ func mountRoutes(app *fiber.App) {
 		v1.Delete("/bookmarks/post/:post_id", middleware.Auth, handler.HandleDeleteFromBookmarks)
 		v1.Get("/bookmarks", middleware.Auth, handler.HandleGetAllBookmarks)
 
-		v1.Get("/notifications", middleware.Auth, handler.HandleGetAllNotifications)
+		v1.Get("/notifications", middleware.Auth, handler.HandleGetAllNotifications) // ?unread=true
 		v1.Get("/notifications/unread_count", middleware.Auth, handler.HandleGetUnreadNotificationsCount)
+		v1.Get("/notifications/stream", middleware.Auth, handler.HandleStreamNotifications)
+		v1.Post("/notifications/read", middleware.Auth, handler.HandleMarkNotificationsAsRead)
+		v1.Post("/notifications/read_all", middleware.Auth, handler.HandleMarkAllNotificationsAsRead) // ?until=<notification_id>
 		v1.Post("/notifications/:notification_id/read", middleware.Auth, handler.HandleMarkNotificationAsRead)
+		v1.Delete("/notifications/read", middleware.Auth, handler.HandleDeleteAllReadNotifications)
+		v1.Delete("/notifications/:notification_id", middleware.Auth, handler.HandleDeleteNotification)
+		v1.Get("/notifications/preferences", middleware.Auth, handler.HandleGetNotificationPreferences)
+		v1.Put("/notifications/preferences/:kind", middleware.Auth, handler.HandleUpdateNotificationPreference)
+		v1.Post("/notifications/mutes", middleware.Auth, handler.HandleMuteNotifications)
+		v1.Get("/notifications/mutes", middleware.Auth, handler.HandleGetAllNotificationMutes)
+		v1.Delete("/notifications/mutes/:mute_id", middleware.Auth, handler.HandleUnmuteNotifications)
+
+		v1.Post("/webhooks", middleware.Auth, handler.HandleCreateWebhook)
+		v1.Get("/webhooks", middleware.Auth, handler.HandleGetAllWebhooks)
+		v1.Delete("/webhooks/:webhook_id", middleware.Auth, handler.HandleDeleteWebhook)
+		v1.Get("/webhooks/:webhook_id/deliveries", middleware.Auth, handler.HandleGetAllWebhookDeliveries)
+		v1.Post("/webhooks/:webhook_id/deliveries/:delivery_id/redeliver", middleware.Auth, handler.HandleRedeliverWebhook)
+
+		v1.Post("/topics", middleware.Auth, handler.HandleSubscribeToTopic)
+		v1.Get("/topics", middleware.Auth, handler.HandleGetAllTopics)
+		v1.Delete("/topics/:topic_id", middleware.Auth, handler.HandleUnsubscribeFromTopic)
 	}
 }
 
@@ -69,6 +137,17 @@ func main() {
 		ErrorHandler: middleware.ErrorHandler,
 	})
 
+	// check the JWT keys before serving, rather than on the first login
+	if err := utils.LoadJWTKeys(); err != nil {
+		log.Fatal("error loading JWT keys: ", err)
+	}
+
+	// start listening for new notifications to push them to streaming clients
+	if err := handler.StartNotificationListener(); err != nil {
+		log.Fatal("error starting notification listener: ", err)
+	}
+	defer handler.StopNotificationListener()
+
 	// mount routes
 	mountRoutes(app)
 
@@ -79,10 +158,37 @@ func main() {
 		}
 	}()
 
-	// start notification worker (notification channel/bus)
+	// render content created before markdown rendering was cached
+	go handler.RenderUnrenderedContent()
+
+	// start notification workers (processing the notifications outbox)
 	handler.StartNotificationWorkers()
 	defer handler.StopNotificationWorker()
 
+	// start webhook workers (sending the pending webhook deliveries)
+	handler.StartWebhookWorkers()
+	defer handler.StopWebhookWorkers()
+
+	// start ActivityPub workers (delivering the queued activities to remote servers)
+	handler.StartActivityPubWorkers()
+	defer handler.StopActivityPubWorkers()
+
+	// start purging old read notifications
+	handler.StartNotificationRetention()
+	defer handler.StopNotificationRetention()
+
+	// start keeping the sitemap up to date
+	handler.StartSitemapGenerator()
+	defer handler.StopSitemapGenerator()
+
+	// start sending email digests
+	handler.StartDigestSender()
+	defer handler.StopDigestSender()
+
+	// start scheduler for publishing scheduled posts
+	handler.StartPostScheduler()
+	defer handler.StopPostScheduler()
+
 	// listen for termination signals
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
@@ -90,6 +196,9 @@ func main() {
 	// wait for termination signal
 	<-sigChan
 
+	// end the notification streams, which never finish on their own
+	handler.CloseNotificationStreams()
+
 	// shutdown server
 	if err := app.ShutdownWithTimeout(5 * time.Second); err != nil {
 		slog.Error("error shutting down server", "err", err, "pid", os.Getpid())
@@ -0,0 +1,141 @@
+// Package activitypub implements the parts of ActivityPub (https://www.w3.org/TR/activitypub/) needed to
+// federate with Mastodon-compatible servers: the vocabulary, WebFinger, HTTP Signatures, and a client
+// to fetch remote actors and deliver activities to their inboxes.
+package activitypub
+
+import (
+	"encoding/json"
+)
+
+const (
+	// ContentType is the media type of ActivityPub documents.
+	ContentType = "application/activity+json"
+	// ldContentType is the equivalent media type some servers send instead.
+	ldContentType = `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+
+	ActivityStreamsContext = "https://www.w3.org/ns/activitystreams"
+	SecurityContext        = "https://w3id.org/security/v1"
+	// Public is the special collection addressing an object to everyone.
+	Public = "https://www.w3.org/ns/activitystreams#Public"
+)
+
+// Context is the @context of the documents served, which may embed public keys.
+var Context = []string{ActivityStreamsContext, SecurityContext}
+
+type Actor struct {
+	Context           any        `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername"`
+	Name              string     `json:"name,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+	Icon              *Image     `json:"icon,omitempty"`
+	Published         string     `json:"published,omitempty"`
+	PublicKey         PublicKey  `json:"publicKey"`
+}
+
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+type Image struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type Article struct {
+	Context      any      `json:"@context,omitempty"`
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	AttributedTo string   `json:"attributedTo"`
+	Name         string   `json:"name"`
+	Content      string   `json:"content"`
+	MediaType    string   `json:"mediaType"`
+	URL          string   `json:"url"`
+	Published    string   `json:"published"`
+	Updated      string   `json:"updated,omitempty"`
+	To           []string `json:"to"`
+	Cc           []string `json:"cc,omitempty"`
+}
+
+// Activity is an activity received in, or sent from, an inbox. The object is kept raw, as it can be
+// either an id or an embedded object.
+type Activity struct {
+	Context   any             `json:"@context,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Type      string          `json:"type"`
+	Actor     string          `json:"actor"`
+	Object    json.RawMessage `json:"object,omitempty"`
+	Published string          `json:"published,omitempty"`
+	To        []string        `json:"to,omitempty"`
+	Cc        []string        `json:"cc,omitempty"`
+}
+
+// ObjectID returns the id of the object of the activity, whether it's embedded or referenced by its id.
+func (a *Activity) ObjectID() string {
+	var id string
+	if err := json.Unmarshal(a.Object, &id); err == nil {
+		return id
+	}
+	var obj struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(a.Object, &obj); err == nil {
+		return obj.ID
+	}
+	return ""
+}
+
+// EmbeddedActivity decodes the object of the activity as an activity, as in `Undo` of a `Follow`.
+// A referenced object only has its id set.
+func (a *Activity) EmbeddedActivity() (*Activity, error) {
+	var embedded Activity
+	if err := json.Unmarshal(a.Object, &embedded); err == nil {
+		return &embedded, nil
+	}
+	var id string
+	if err := json.Unmarshal(a.Object, &id); err != nil {
+		return nil, err
+	}
+	return &Activity{ID: id}, nil
+}
+
+type OrderedCollection struct {
+	Context    any    `json:"@context,omitempty"`
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	TotalItems int64  `json:"totalItems"`
+	First      string `json:"first,omitempty"`
+}
+
+type OrderedCollectionPage struct {
+	Context      any    `json:"@context,omitempty"`
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	PartOf       string `json:"partOf"`
+	Next         string `json:"next,omitempty"`
+	OrderedItems []any  `json:"orderedItems"`
+}
+
+// WebFinger is a JSON Resource Descriptor (RFC 7033), returned for `acct:username@host` lookups.
+type WebFinger struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases,omitempty"`
+	Links   []WebFingerLink `json:"links"`
+}
+
+type WebFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
@@ -0,0 +1,115 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const maxResponseBodyBytes = 1 << 20
+
+var ErrInsecureURL = errors.New("url must be https")
+
+// Client fetches remote documents and delivers activities, signing every request as a local actor.
+type Client struct {
+	HTTP      *http.Client
+	UserAgent string
+	// AllowHTTP allows plain http urls, which is only meant for local instances in development and tests.
+	AllowHTTP bool
+}
+
+// FetchActor fetches a remote actor. actorURL may have a fragment, like the id of a key.
+func (c *Client) FetchActor(ctx context.Context, actorURL, keyID string, key *rsa.PrivateKey) (*Actor, error) {
+	u, err := c.parseURL(actorURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Fragment = ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", ContentType+", "+ldContentType)
+	resp, err := c.do(req, nil, keyID, key)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var actor Actor
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBodyBytes)).Decode(&actor); err != nil {
+		return nil, fmt.Errorf("error decoding actor: %w", err)
+	}
+	if actor.ID != u.String() {
+		return nil, fmt.Errorf("actor id %q doesn't match its url %q", actor.ID, u.String())
+	}
+	return &actor, nil
+}
+
+// Deliver posts an activity to a remote inbox.
+func (c *Client) Deliver(ctx context.Context, inbox string, activity any, keyID string, key *rsa.PrivateKey) error {
+	u, err := c.parseURL(inbox)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return fmt.Errorf("error encoding activity: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", ContentType)
+	resp, err := c.do(req, body, keyID, key)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBodyBytes))
+	return nil
+}
+
+// do signs and sends the request, and fails for non 2xx responses.
+func (c *Client) do(req *http.Request, body []byte, keyID string, key *rsa.PrivateKey) (*http.Response, error) {
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	if err := SignRequest(req, body, keyID, key, time.Now()); err != nil {
+		return nil, err
+	}
+
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected response status %d from %s", resp.StatusCode, req.URL)
+	}
+	return resp, nil
+}
+
+func (c *Client) parseURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %q: %w", rawURL, err)
+	}
+	if u.Host == "" || (u.Scheme != "https" && !(c.AllowHTTP && u.Scheme == "http")) {
+		return nil, fmt.Errorf("%w: %q", ErrInsecureURL, rawURL)
+	}
+	return u, nil
+}
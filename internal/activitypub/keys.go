@@ -0,0 +1,72 @@
+package activitypub
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+const keyBits = 2048
+
+// GenerateKey generates an RSA key pair for an actor, encoded as PEM (PKCS#8 and PKIX),
+// which is what other servers expect in publicKeyPem.
+func GenerateKey() (privateKeyPem, publicKeyPem string, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return "", "", fmt.Errorf("error generating key: %w", err)
+	}
+	privateDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", "", fmt.Errorf("error encoding private key: %w", err)
+	}
+	publicDer, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", fmt.Errorf("error encoding public key: %w", err)
+	}
+	privateKeyPem = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDer}))
+	publicKeyPem = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer}))
+	return privateKeyPem, publicKeyPem, nil
+}
+
+// ParsePrivateKey parses an RSA private key encoded as PEM, either PKCS#8 or PKCS#1.
+func ParsePrivateKey(keyPem string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(keyPem))
+	if block == nil {
+		return nil, errors.New("invalid PEM")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// ParsePublicKey parses an RSA public key encoded as PEM, either PKIX or PKCS#1.
+func ParsePublicKey(keyPem string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(keyPem))
+	if block == nil {
+		return nil, errors.New("invalid PEM")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,202 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// HTTP Signatures, as used by Mastodon: https://datatracker.ietf.org/doc/html/draft-cavage-http-signatures-12
+// Requests are signed with rsa-sha256 over the request target, host and date, plus the digest of the
+// body when there's one.
+
+// MaxClockSkew is how far the Date of a signed request can be from now. Mastodon uses the same window.
+const MaxClockSkew = 12 * time.Hour
+
+const signatureAlgorithm = "rsa-sha256"
+
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+type Signature struct {
+	KeyID     string
+	Algorithm string
+	Headers   []string
+	Signature []byte
+}
+
+// SignRequest sets the Date, Digest (for requests with a body) and Signature headers of req. body must
+// be the body of req, if any.
+func SignRequest(req *http.Request, body []byte, keyID string, key *rsa.PrivateKey, now time.Time) error {
+	req.Header.Set("Date", now.UTC().Format(http.TimeFormat))
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		req.Header.Set("Digest", Digest(body))
+		headers = append(headers, "digest")
+	}
+
+	signingString, err := buildSigningString(headers, req.Method, req.URL.RequestURI(), RequestHeader(req))
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(signingString))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return fmt.Errorf("error signing request: %w", err)
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="%s",headers="%s",signature="%s"`,
+		keyID, signatureAlgorithm, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+// ParseSignature parses the value of a Signature header.
+func ParseSignature(value string) (*Signature, error) {
+	if value == "" {
+		return nil, ErrMissingSignature
+	}
+
+	params := map[string]string{}
+	for _, param := range splitParams(value) {
+		name, quoted, ok := strings.Cut(param, "=")
+		if !ok || len(quoted) < 2 || quoted[0] != '"' || quoted[len(quoted)-1] != '"' {
+			return nil, fmt.Errorf("%w: malformed parameter %q", ErrInvalidSignature, param)
+		}
+		params[strings.TrimSpace(name)] = quoted[1 : len(quoted)-1]
+	}
+
+	sig := &Signature{
+		KeyID:     params["keyId"],
+		Algorithm: params["algorithm"],
+		Headers:   []string{"date"}, // the default, when headers isn't set
+	}
+	if sig.KeyID == "" {
+		return nil, fmt.Errorf("%w: missing keyId", ErrInvalidSignature)
+	}
+	if headers := params["headers"]; headers != "" {
+		sig.Headers = strings.Fields(strings.ToLower(headers))
+	}
+	signature, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil || len(signature) == 0 {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+	sig.Signature = signature
+	return sig, nil
+}
+
+// Check checks everything about the signature of a request that doesn't need the key: it requires
+// the request target, host and date to be signed, and the date to be within MaxClockSkew of now.
+// For requests with a body, the digest must be signed too, and match the body.
+// It's meant to reject bad requests before fetching the key from the signer.
+// header returns the value of a request header, including Host.
+func (sig *Signature) Check(method, target string, header func(name string) string, body []byte, now time.Time) error {
+	if sig.Algorithm != "" && sig.Algorithm != signatureAlgorithm && sig.Algorithm != "hs2019" {
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, sig.Algorithm)
+	}
+
+	required := []string{"(request-target)", "host", "date"}
+	if len(body) > 0 {
+		required = append(required, "digest")
+	}
+	for _, name := range required {
+		if !slices.Contains(sig.Headers, name) {
+			return fmt.Errorf("%w: %s is not signed", ErrInvalidSignature, name)
+		}
+	}
+
+	date, err := http.ParseTime(header("Date"))
+	if err != nil {
+		return fmt.Errorf("%w: malformed date", ErrInvalidSignature)
+	}
+	if skew := now.Sub(date); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return fmt.Errorf("%w: date is too far from now", ErrInvalidSignature)
+	}
+
+	if len(body) > 0 && header("Digest") != Digest(body) {
+		return fmt.Errorf("%w: digest doesn't match the body", ErrInvalidSignature)
+	}
+
+	_, err = buildSigningString(sig.Headers, method, target, header)
+	return err
+}
+
+// Verify checks the signature of a request, made with the public key of sig.KeyID, after checking
+// the request with Check.
+func (sig *Signature) Verify(method, target string, header func(name string) string, body []byte, key *rsa.PublicKey, now time.Time) error {
+	if err := sig.Check(method, target, header, body, now); err != nil {
+		return err
+	}
+
+	signingString, err := buildSigningString(sig.Headers, method, target, header)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256([]byte(signingString))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig.Signature); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Digest returns the value of the Digest header of a body.
+func Digest(body []byte) string {
+	hash := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// RequestHeader returns a function getting the headers of req, including Host.
+func RequestHeader(req *http.Request) func(name string) string {
+	return func(name string) string {
+		if strings.EqualFold(name, "host") {
+			if req.Host != "" {
+				return req.Host
+			}
+			return req.URL.Host
+		}
+		return req.Header.Get(name)
+	}
+}
+
+func buildSigningString(headers []string, method, target string, header func(name string) string) (string, error) {
+	lines := make([]string, 0, len(headers))
+	for _, name := range headers {
+		if name == "(request-target)" {
+			lines = append(lines, "(request-target): "+strings.ToLower(method)+" "+target)
+			continue
+		}
+		value := header(name)
+		if value == "" {
+			return "", fmt.Errorf("%w: missing signed header %s", ErrInvalidSignature, name)
+		}
+		lines = append(lines, name+": "+strings.TrimSpace(value))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// splitParams splits the comma separated parameters of a Signature header, ignoring commas in quotes.
+func splitParams(value string) []string {
+	var params []string
+	start, quoted := 0, false
+	for i, c := range value {
+		switch c {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				params = append(params, strings.TrimSpace(value[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(params, strings.TrimSpace(value[start:]))
+}
@@ -0,0 +1,36 @@
+-- +goose Up
+
+ALTER TABLE post_comments
+    ADD COLUMN parent_id UUID,
+    ADD COLUMN replies_count INTEGER NOT NULL DEFAULT 0,
+    ADD FOREIGN KEY(parent_id) REFERENCES post_comments(id) ON DELETE CASCADE;
+
+CREATE INDEX ON post_comments(parent_id);
+
+-- +goose StatementBegin
+CREATE FUNCTION update_comment_replies_count()
+RETURNS TRIGGER
+AS $$
+BEGIN
+    IF TG_OP = 'INSERT' AND NEW.parent_id IS NOT NULL THEN
+        UPDATE post_comments SET replies_count = replies_count + 1 WHERE id = NEW.parent_id;
+    ELSIF TG_OP = 'DELETE' AND OLD.parent_id IS NOT NULL THEN
+        UPDATE post_comments SET replies_count = replies_count - 1 WHERE id = OLD.parent_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE PLPGSQL;
+-- +goose StatementEnd
+
+CREATE TRIGGER trg_update_comment_replies_count
+AFTER INSERT OR DELETE ON post_comments FOR EACH ROW
+EXECUTE FUNCTION update_comment_replies_count();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS trg_update_comment_replies_count ON post_comments;
+DROP FUNCTION IF EXISTS update_comment_replies_count;
+
+ALTER TABLE post_comments
+    DROP COLUMN IF EXISTS parent_id,
+    DROP COLUMN IF EXISTS replies_count;
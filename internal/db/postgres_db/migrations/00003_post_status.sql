@@ -0,0 +1,19 @@
+-- +goose Up
+
+-- existing posts were published on creation, so they start as 'published'.
+ALTER TABLE posts
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'published',
+    ADD COLUMN published_at TIMESTAMP,
+    ADD CONSTRAINT posts_status_check CHECK(status IN ('draft', 'published', 'unlisted'));
+
+UPDATE posts SET published_at = created_at;
+
+-- new posts start as drafts until explicitly published.
+ALTER TABLE posts ALTER COLUMN status SET DEFAULT 'draft';
+
+CREATE INDEX ON posts(user_id, status);
+
+-- +goose Down
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS published_at;
@@ -0,0 +1,17 @@
+-- +goose Up
+
+ALTER TABLE posts
+    ADD COLUMN publish_at TIMESTAMP,
+    DROP CONSTRAINT posts_status_check,
+    ADD CONSTRAINT posts_status_check CHECK(status IN ('draft', 'scheduled', 'published', 'unlisted'));
+
+-- used by the scheduler to find due posts
+CREATE INDEX ON posts(publish_at) WHERE status = 'scheduled';
+
+-- +goose Down
+UPDATE posts SET status = 'draft' WHERE status = 'scheduled';
+
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS publish_at,
+    DROP CONSTRAINT posts_status_check,
+    ADD CONSTRAINT posts_status_check CHECK(status IN ('draft', 'published', 'unlisted'));
@@ -0,0 +1,65 @@
+-- +goose Up
+
+CREATE TABLE post_revisions(
+    id UUID DEFAULT generate_ulid_as_uuid(),
+    post_id UUID NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    content VARCHAR NOT NULL,
+    featured_image_url VARCHAR,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(id),
+    FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON post_revisions(post_id);
+
+-- the current content of existing posts becomes their first revision
+INSERT INTO post_revisions(post_id, title, content, featured_image_url, created_at)
+SELECT id, title, content, featured_image_url, created_at FROM posts;
+
+-- +goose StatementBegin
+CREATE FUNCTION create_post_revision()
+RETURNS TRIGGER
+AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND
+        NEW.title = OLD.title AND
+        NEW.content = OLD.content AND
+        NEW.featured_image_url IS NOT DISTINCT FROM OLD.featured_image_url
+    THEN
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO post_revisions(post_id, title, content, featured_image_url)
+    VALUES(NEW.id, NEW.title, NEW.content, NEW.featured_image_url);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE PLPGSQL;
+-- +goose StatementEnd
+
+CREATE TRIGGER trg_create_post_revision
+AFTER INSERT OR UPDATE OF title, content, featured_image_url ON posts FOR EACH ROW
+EXECUTE FUNCTION create_post_revision();
+
+-- +goose StatementBegin
+CREATE FUNCTION prevent_post_revision_update()
+RETURNS TRIGGER
+AS $$
+BEGIN
+    RAISE EXCEPTION 'post revisions are immutable';
+END;
+$$ LANGUAGE PLPGSQL;
+-- +goose StatementEnd
+
+CREATE TRIGGER trg_prevent_post_revision_update
+BEFORE UPDATE ON post_revisions FOR EACH ROW
+EXECUTE FUNCTION prevent_post_revision_update();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS trg_create_post_revision ON posts;
+DROP TABLE IF EXISTS post_revisions CASCADE;
+
+DROP FUNCTION IF EXISTS create_post_revision;
+DROP FUNCTION IF EXISTS prevent_post_revision_update;
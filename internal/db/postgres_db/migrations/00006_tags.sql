@@ -0,0 +1,52 @@
+-- +goose Up
+
+CREATE TABLE tags(
+    id SERIAL,
+    slug VARCHAR(50) NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    posts_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(id),
+    UNIQUE(slug)
+);
+
+-- used for popular tags
+CREATE INDEX ON tags(posts_count);
+
+CREATE TABLE post_tags(
+    post_id UUID,
+    tag_id INTEGER,
+
+    PRIMARY KEY(post_id, tag_id),
+    FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY(tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON post_tags(tag_id);
+
+-- +goose StatementBegin
+CREATE FUNCTION update_tag_posts_count()
+RETURNS TRIGGER
+AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE tags SET posts_count = posts_count + 1 WHERE id = NEW.tag_id;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE tags SET posts_count = posts_count - 1 WHERE id = OLD.tag_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE PLPGSQL;
+-- +goose StatementEnd
+
+CREATE TRIGGER trg_update_tag_posts_count
+AFTER INSERT OR DELETE ON post_tags FOR EACH ROW
+EXECUTE FUNCTION update_tag_posts_count();
+
+-- +goose Down
+DROP TABLE IF EXISTS post_tags CASCADE;
+DROP TABLE IF EXISTS tags CASCADE;
+
+DROP FUNCTION IF EXISTS update_tag_posts_count;
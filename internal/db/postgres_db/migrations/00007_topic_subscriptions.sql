@@ -0,0 +1,22 @@
+-- +goose Up
+
+CREATE TABLE topic_subscriptions(
+    id UUID DEFAULT generate_ulid_as_uuid(),
+    user_id UUID NOT NULL,
+    query VARCHAR(255) NOT NULL, -- plain keywords, or a to_tsquery() expression if is_tsquery
+    is_tsquery BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(id),
+    UNIQUE(user_id, query, is_tsquery),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO notification_kinds(name)
+VALUES
+    ('topic_match');
+
+-- +goose Down
+DELETE FROM notification_kinds WHERE name = 'topic_match';
+
+DROP TABLE IF EXISTS topic_subscriptions CASCADE;
@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- content is CommonMark source, content_html is its sanitized rendering and toc the headings of it.
+-- content_html is NULL until the app renders the content, which it does on write, and in the background
+-- for rows that existed before this migration.
+ALTER TABLE posts
+    ADD COLUMN content_html TEXT,
+    ADD COLUMN toc JSONB NOT NULL DEFAULT '[]';
+
+ALTER TABLE post_comments
+    ADD COLUMN content_html TEXT;
+
+CREATE INDEX ON posts(id) WHERE content_html IS NULL;
+CREATE INDEX ON post_comments(id) WHERE content_html IS NULL;
+
+-- +goose Down
+ALTER TABLE posts
+    DROP COLUMN IF EXISTS content_html,
+    DROP COLUMN IF EXISTS toc;
+
+ALTER TABLE post_comments
+    DROP COLUMN IF EXISTS content_html;
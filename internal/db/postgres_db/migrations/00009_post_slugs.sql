@@ -0,0 +1,57 @@
+-- +goose Up
+
+-- every slug a post has ever had, so links using an old slug keep resolving to the post.
+-- slugs are unique per author, including the old ones.
+CREATE TABLE post_slugs(
+    post_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    slug VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(user_id, slug),
+    FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON post_slugs(post_id);
+
+-- the current slug of the post
+ALTER TABLE posts ADD COLUMN slug VARCHAR(255);
+
+-- generate slugs for existing posts, the same way the app does: the slugified title,
+-- suffixed with -2, -3, ... if the author already has a post with that slug.
+-- +goose StatementBegin
+DO $$
+DECLARE
+    p RECORD;
+    base_slug VARCHAR;
+    candidate VARCHAR;
+    n INTEGER;
+BEGIN
+    FOR p IN SELECT id, user_id, title FROM posts ORDER BY id LOOP
+        base_slug := COALESCE(
+            NULLIF(TRIM(BOTH '-' FROM LEFT(LOWER(REGEXP_REPLACE(p.title, '[^[:alnum:]]+', '-', 'g')), 200)), ''),
+            'post'
+        );
+        candidate := base_slug;
+        n := 1;
+        WHILE EXISTS(SELECT 1 FROM post_slugs WHERE user_id = p.user_id AND slug = candidate) LOOP
+            n := n + 1;
+            candidate := base_slug || '-' || n;
+        END LOOP;
+
+        INSERT INTO post_slugs(post_id, user_id, slug) VALUES(p.id, p.user_id, candidate);
+        UPDATE posts SET slug = candidate WHERE id = p.id;
+    END LOOP;
+END;
+$$;
+-- +goose StatementEnd
+
+ALTER TABLE posts
+    ALTER COLUMN slug SET NOT NULL,
+    ADD CONSTRAINT posts_user_id_slug_key UNIQUE(user_id, slug);
+
+-- +goose Down
+ALTER TABLE posts DROP COLUMN IF EXISTS slug;
+
+DROP TABLE IF EXISTS post_slugs CASCADE;
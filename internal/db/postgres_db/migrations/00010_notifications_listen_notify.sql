@@ -0,0 +1,25 @@
+-- +goose Up
+
+-- every app process listens on the 'new_notification' channel, to push new notifications
+-- to the users streaming them. The payload is the id of the user receiving the notification.
+-- +goose StatementBegin
+CREATE FUNCTION notify_new_notification()
+RETURNS TRIGGER
+AS $$
+BEGIN
+    PERFORM pg_notify('new_notification', NEW.user_id::TEXT);
+    RETURN NULL;
+END;
+$$ LANGUAGE PLPGSQL;
+-- +goose StatementEnd
+
+CREATE TRIGGER trg_notify_new_notification
+AFTER INSERT ON notifications FOR EACH ROW
+EXECUTE FUNCTION notify_new_notification();
+
+CREATE INDEX ON notifications(user_id, id);
+
+-- +goose Down
+DROP TRIGGER IF EXISTS trg_notify_new_notification ON notifications;
+DROP FUNCTION IF EXISTS notify_new_notification;
+DROP INDEX IF EXISTS notifications_user_id_id_idx;
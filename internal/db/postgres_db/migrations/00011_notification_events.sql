@@ -0,0 +1,30 @@
+-- +goose Up
+
+-- outbox of notification events. Events are written in the same transaction as the change
+-- that triggers them, then turned into notifications by the notification workers.
+-- An event is deleted once its notifications are created. Failing events are retried with
+-- a backoff, and after too many attempts they're kept as 'dead' for inspection.
+CREATE TABLE notification_events(
+    id UUID DEFAULT generate_ulid_as_uuid(),
+    kind_id INTEGER NOT NULL,
+    user_id UUID, -- the user who recieves the notification, NULL for kinds notifying many users (e.g. 'new_post')
+    sender_id UUID,
+    post_id UUID,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error VARCHAR,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(id),
+    CONSTRAINT notification_events_status_check CHECK(status IN ('pending', 'dead')),
+    FOREIGN KEY(kind_id) REFERENCES notification_kinds(id) ON DELETE CASCADE,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(sender_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON notification_events(next_attempt_at) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE IF EXISTS notification_events CASCADE;
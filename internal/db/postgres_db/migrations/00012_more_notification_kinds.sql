@@ -0,0 +1,22 @@
+-- +goose Up
+
+INSERT INTO notification_kinds(name)
+VALUES
+    ('new_comment'),
+    ('new_reply'),
+    ('new_reaction'),
+    ('mention');
+
+-- in case kind is 'new_comment', 'new_reply' or 'mention' (in a comment)
+ALTER TABLE notifications
+    ADD COLUMN comment_id UUID REFERENCES post_comments(id) ON DELETE CASCADE;
+
+ALTER TABLE notification_events
+    ADD COLUMN comment_id UUID REFERENCES post_comments(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE notification_events DROP COLUMN IF EXISTS comment_id;
+
+ALTER TABLE notifications DROP COLUMN IF EXISTS comment_id;
+
+DELETE FROM notification_kinds WHERE name IN ('new_comment', 'new_reply', 'new_reaction', 'mention');
@@ -0,0 +1,53 @@
+-- +goose Up
+
+-- a missing row means the defaults: notified in-app, and not included in the email digest.
+CREATE TABLE notification_preferences(
+    user_id UUID NOT NULL,
+    kind_id INTEGER NOT NULL,
+    in_app BOOLEAN NOT NULL DEFAULT TRUE,
+    digest BOOLEAN NOT NULL DEFAULT FALSE,
+
+    PRIMARY KEY(user_id, kind_id),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(kind_id) REFERENCES notification_kinds(id) ON DELETE CASCADE
+);
+
+-- mutes all notifications sent by a user, or about a post.
+CREATE TABLE notification_mutes(
+    id UUID DEFAULT generate_ulid_as_uuid(),
+    user_id UUID NOT NULL,
+    muted_user_id UUID,
+    muted_post_id UUID,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(id),
+    CONSTRAINT notification_mutes_target_check CHECK((muted_user_id IS NULL) <> (muted_post_id IS NULL)),
+    UNIQUE(user_id, muted_user_id),
+    UNIQUE(user_id, muted_post_id),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(muted_user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(muted_post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+-- whether a notification should be created, according to the receiver's preferences and mutes.
+-- +goose StatementBegin
+CREATE FUNCTION should_notify(p_user_id UUID, p_kind_id INTEGER, p_sender_id UUID, p_post_id UUID)
+RETURNS BOOLEAN
+AS $$
+    SELECT
+        COALESCE(
+            (SELECT in_app FROM notification_preferences WHERE user_id = p_user_id AND kind_id = p_kind_id),
+            TRUE
+        ) AND
+        NOT EXISTS(
+            SELECT 1 FROM notification_mutes
+            WHERE user_id = p_user_id AND (muted_user_id = p_sender_id OR muted_post_id = p_post_id)
+        );
+$$ LANGUAGE SQL STABLE;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION IF EXISTS should_notify;
+
+DROP TABLE IF EXISTS notification_mutes CASCADE;
+DROP TABLE IF EXISTS notification_preferences CASCADE;
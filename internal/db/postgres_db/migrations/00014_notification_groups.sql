@@ -0,0 +1,19 @@
+-- +goose Up
+
+-- notifications of some kinds are grouped per receiver and post, like "alice and 23 others reacted to your post".
+-- actor_ids holds the most recent actors of the group, latest first, and actors_count the number of all of them.
+ALTER TABLE notifications
+    ADD COLUMN actor_ids UUID[] NOT NULL DEFAULT '{}',
+    ADD COLUMN actors_count INTEGER NOT NULL DEFAULT 1;
+
+UPDATE notifications SET actor_ids = ARRAY[sender_id] WHERE sender_id IS NOT NULL;
+
+-- to find the open (unread) group of a new notification.
+CREATE INDEX ON notifications(user_id, kind_id, post_id) WHERE is_read = FALSE;
+
+-- +goose Down
+DROP INDEX IF EXISTS notifications_user_id_kind_id_post_id_idx;
+
+ALTER TABLE notifications
+    DROP COLUMN IF EXISTS actors_count,
+    DROP COLUMN IF EXISTS actor_ids;
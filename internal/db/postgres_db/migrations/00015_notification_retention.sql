@@ -0,0 +1,7 @@
+-- +goose Up
+
+-- to find the read notifications to purge.
+CREATE INDEX ON notifications(created_at) WHERE is_read = TRUE;
+
+-- +goose Down
+DROP INDEX IF EXISTS notifications_created_at_idx;
@@ -0,0 +1,41 @@
+-- +goose Up
+
+-- the email is only used once verified. Two users may set the same email, but only one can verify it.
+ALTER TABLE users
+    ADD COLUMN email VARCHAR(255),
+    ADD COLUMN email_verified_at TIMESTAMP,
+    ADD COLUMN digest_frequency VARCHAR(20) NOT NULL DEFAULT 'never',
+    ADD COLUMN last_digest_sent_at TIMESTAMP,
+    ADD CONSTRAINT users_digest_frequency_check CHECK(digest_frequency IN ('never', 'daily', 'weekly'));
+
+CREATE UNIQUE INDEX users_verified_email_idx ON users(email) WHERE email_verified_at IS NOT NULL;
+
+-- to find the users with a due digest.
+CREATE INDEX ON users(last_digest_sent_at) WHERE digest_frequency <> 'never' AND email_verified_at IS NOT NULL;
+
+-- a token is sent to the email being verified, and is only valid for that email.
+CREATE TABLE email_verification_tokens(
+    token VARCHAR(255),
+    user_id UUID NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(token),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON email_verification_tokens(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS email_verification_tokens CASCADE;
+
+DROP INDEX IF EXISTS users_last_digest_sent_at_idx;
+DROP INDEX IF EXISTS users_verified_email_idx;
+
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_digest_frequency_check,
+    DROP COLUMN IF EXISTS last_digest_sent_at,
+    DROP COLUMN IF EXISTS digest_frequency,
+    DROP COLUMN IF EXISTS email_verified_at,
+    DROP COLUMN IF EXISTS email;
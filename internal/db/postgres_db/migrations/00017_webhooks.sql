@@ -0,0 +1,45 @@
+-- +goose Up
+
+-- endpoints registered by users to receive the events about their own content, e.g. 'post.published'
+-- for their posts or 'user.followed' when someone follows them.
+CREATE TABLE webhooks(
+    id UUID DEFAULT generate_ulid_as_uuid(),
+    user_id UUID NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL, -- used to sign the payloads (HMAC-SHA256)
+    events VARCHAR(50)[] NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(id),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON webhooks(user_id);
+
+-- outbox and log of webhook deliveries. Deliveries are written in the same transaction as the change
+-- that triggers them, then sent by the webhook workers. Failing deliveries are retried with a backoff,
+-- and after too many attempts they're marked as 'failed'. Delivered ones are kept as 'succeeded'.
+CREATE TABLE webhook_deliveries(
+    id UUID DEFAULT generate_ulid_as_uuid(),
+    webhook_id UUID NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_status_code INTEGER,
+    last_error VARCHAR,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP,
+
+    PRIMARY KEY(id),
+    CONSTRAINT webhook_deliveries_status_check CHECK(status IN ('pending', 'succeeded', 'failed')),
+    FOREIGN KEY(webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX ON webhook_deliveries(webhook_id, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_deliveries CASCADE;
+DROP TABLE IF EXISTS webhooks CASCADE;
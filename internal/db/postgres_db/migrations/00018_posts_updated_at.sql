@@ -0,0 +1,32 @@
+-- +goose Up
+
+-- the last time the visible parts of a post changed. Used by the syndication feeds.
+ALTER TABLE posts ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+UPDATE posts SET updated_at = COALESCE(published_at, created_at);
+
+-- +goose StatementBegin
+CREATE FUNCTION update_post_updated_at()
+RETURNS TRIGGER
+AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE PLPGSQL;
+-- +goose StatementEnd
+
+-- counters (views_count, comments_count) and the rendering cache don't count as changes.
+CREATE TRIGGER trg_update_post_updated_at
+BEFORE UPDATE OF title, content, featured_image_url, status, published_at, slug ON posts
+FOR EACH ROW
+EXECUTE FUNCTION update_post_updated_at();
+
+-- to build the feeds of the latest published posts.
+CREATE INDEX ON posts(published_at) WHERE status = 'published';
+
+-- +goose Down
+DROP INDEX IF EXISTS posts_published_at_idx;
+DROP TRIGGER IF EXISTS trg_update_post_updated_at ON posts;
+DROP FUNCTION IF EXISTS update_post_updated_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS updated_at;
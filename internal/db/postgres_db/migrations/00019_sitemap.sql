@@ -0,0 +1,40 @@
+-- +goose Up
+
+-- the urls listed in the sitemap: published posts and user profiles. Kept up to date by the sitemap
+-- generator from the changes since its last run, so serving the sitemap doesn't scan all posts.
+-- Urls are built when serving, from the current username and slug.
+CREATE TABLE sitemap_urls(
+    id BIGSERIAL, -- keeps the order of the urls, and so which sitemap page each one is on, stable
+    post_id UUID,
+    user_id UUID,
+    lastmod TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(id),
+    UNIQUE(post_id),
+    UNIQUE(user_id),
+    FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CHECK((post_id IS NULL) <> (user_id IS NULL))
+);
+
+-- a single row with the time of the last run of the sitemap generator.
+CREATE TABLE sitemap_sync(
+    id BOOLEAN DEFAULT TRUE,
+    synced_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(id),
+    CHECK(id)
+);
+
+-- the first run adds every post and user
+INSERT INTO sitemap_sync(synced_at) VALUES ('epoch');
+
+-- to find the posts and users changed since the last run.
+CREATE INDEX ON posts(updated_at);
+CREATE INDEX ON users(joined_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS users_joined_at_idx;
+DROP INDEX IF EXISTS posts_updated_at_idx;
+DROP TABLE IF EXISTS sitemap_sync;
+DROP TABLE IF EXISTS sitemap_urls;
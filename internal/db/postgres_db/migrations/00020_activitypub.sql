@@ -0,0 +1,31 @@
+-- +goose Up
+
+-- the keys users sign their ActivityPub requests with. Generated when the actor of the user is
+-- first needed.
+CREATE TABLE actor_keys(
+    user_id UUID,
+    public_key_pem TEXT NOT NULL,
+    private_key_pem TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(user_id),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- followers from other (ActivityPub) servers, parallel to follows. actor_id is the url of the remote actor.
+CREATE TABLE remote_followers(
+    id UUID DEFAULT generate_ulid_as_uuid(),
+    user_id UUID NOT NULL, -- the followed user
+    actor_id VARCHAR(2048) NOT NULL,
+    inbox_url VARCHAR(2048) NOT NULL,
+    shared_inbox_url VARCHAR(2048),
+    followed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(id),
+    UNIQUE(user_id, actor_id),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS remote_followers;
+DROP TABLE IF EXISTS actor_keys;
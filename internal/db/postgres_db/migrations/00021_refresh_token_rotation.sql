@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- refresh tokens are rotated on every use: the used token is kept (with used_at set) and a new one is issued
+-- in the same family. A family starts on login, so a used token being presented again means it leaked,
+-- and the whole family is revoked.
+ALTER TABLE refresh_tokens
+    ADD COLUMN family_id UUID NOT NULL DEFAULT generate_ulid_as_uuid(),
+    ADD COLUMN used_at TIMESTAMP;
+
+CREATE INDEX ON refresh_tokens(family_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS refresh_tokens_family_id_idx;
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS used_at,
+    DROP COLUMN IF EXISTS family_id;
@@ -0,0 +1,33 @@
+-- +goose Up
+
+-- a session is a login on a device. Its id is the family id of the refresh tokens issued from the login,
+-- so revoking a session revokes its refresh tokens.
+CREATE TABLE sessions(
+    id UUID DEFAULT generate_ulid_as_uuid(),
+    user_id UUID NOT NULL,
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP NOT NULL DEFAULT NOW(), -- the last login or refresh
+
+    PRIMARY KEY(id),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON sessions(user_id);
+
+-- the existing refresh token families become sessions, without a known device
+INSERT INTO sessions(id, user_id, created_at, last_used_at)
+SELECT family_id, user_id, MIN(created_at), MAX(COALESCE(used_at, created_at))
+FROM refresh_tokens
+GROUP BY family_id, user_id;
+
+ALTER TABLE refresh_tokens
+    ALTER COLUMN family_id DROP DEFAULT,
+    ADD FOREIGN KEY(family_id) REFERENCES sessions(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+    DROP CONSTRAINT IF EXISTS refresh_tokens_family_id_fkey,
+    ALTER COLUMN family_id SET DEFAULT generate_ulid_as_uuid();
+DROP TABLE IF EXISTS sessions;
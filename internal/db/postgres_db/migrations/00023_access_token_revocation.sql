@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- access tokens issued at or before this time are rejected, e.g. after a password change.
+ALTER TABLE users ADD COLUMN tokens_valid_after TIMESTAMP;
+
+-- access tokens revoked before they expire, by their jti claim.
+-- A row is only needed until the token expires.
+CREATE TABLE revoked_access_tokens(
+    jti VARCHAR(26),
+    user_id UUID NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(jti),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON revoked_access_tokens(expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS revoked_access_tokens;
+ALTER TABLE users DROP COLUMN IF EXISTS tokens_valid_after;
@@ -0,0 +1,46 @@
+-- +goose Up
+
+-- a TOTP authenticator enrolled by the user. Two-factor authentication is enabled once it's confirmed
+-- with a code. last_used_step is the time step of the last accepted code, so a code can't be replayed.
+CREATE TABLE totp_authenticators(
+    user_id UUID,
+    secret VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    confirmed_at TIMESTAMP,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY(user_id),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- one-time codes to log in without the authenticator, stored as sha256 hashes.
+CREATE TABLE recovery_codes(
+    id UUID DEFAULT generate_ulid_as_uuid(),
+    user_id UUID NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+
+    PRIMARY KEY(id),
+    UNIQUE(user_id, code_hash),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- a login waiting for the second factor. It's deleted when it's completed,
+-- or when too many wrong codes are entered.
+CREATE TABLE mfa_challenges(
+    token VARCHAR(255),
+    user_id UUID NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(token),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON mfa_challenges(expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS mfa_challenges;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS totp_authenticators;
@@ -0,0 +1,138 @@
+-- +goose Up
+
+-- the posts counts of users and tags only count published posts, so they don't reveal drafts,
+-- scheduled or unlisted posts. They change when a post is published or unpublished.
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION update_user_posts_count()
+RETURNS TRIGGER
+AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.status = NEW.status THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.status = 'published' THEN
+        UPDATE users SET posts_count = posts_count - 1 WHERE id = OLD.user_id;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.status = 'published' THEN
+        UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE PLPGSQL;
+-- +goose StatementEnd
+
+DROP TRIGGER trg_update_user_posts_count ON posts;
+CREATE TRIGGER trg_update_user_posts_count
+AFTER INSERT OR DELETE OR UPDATE OF status ON posts FOR EACH ROW
+EXECUTE FUNCTION update_user_posts_count();
+
+-- tagging or untagging a post only counts if it's published. When a published post is deleted,
+-- its tags are untagged by cascade after it's gone, so they're counted down before the delete.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION update_tag_posts_count()
+RETURNS TRIGGER
+AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE tags SET posts_count = posts_count + 1
+        WHERE id = NEW.tag_id AND EXISTS(SELECT 1 FROM posts WHERE id = NEW.post_id AND status = 'published');
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE tags SET posts_count = posts_count - 1
+        WHERE id = OLD.tag_id AND EXISTS(SELECT 1 FROM posts WHERE id = OLD.post_id AND status = 'published');
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE PLPGSQL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION update_post_tags_posts_count()
+RETURNS TRIGGER
+AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF OLD.status = 'published' THEN
+            UPDATE tags SET posts_count = posts_count - 1
+            WHERE id IN (SELECT tag_id FROM post_tags WHERE post_id = OLD.id);
+        END IF;
+        RETURN OLD;
+    END IF;
+
+    IF OLD.status = 'published' AND NEW.status <> 'published' THEN
+        UPDATE tags SET posts_count = posts_count - 1
+        WHERE id IN (SELECT tag_id FROM post_tags WHERE post_id = NEW.id);
+    ELSIF OLD.status <> 'published' AND NEW.status = 'published' THEN
+        UPDATE tags SET posts_count = posts_count + 1
+        WHERE id IN (SELECT tag_id FROM post_tags WHERE post_id = NEW.id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE PLPGSQL;
+-- +goose StatementEnd
+
+CREATE TRIGGER trg_update_post_tags_posts_count_on_delete
+BEFORE DELETE ON posts FOR EACH ROW
+EXECUTE FUNCTION update_post_tags_posts_count();
+
+CREATE TRIGGER trg_update_post_tags_posts_count_on_status
+AFTER UPDATE OF status ON posts FOR EACH ROW
+EXECUTE FUNCTION update_post_tags_posts_count();
+
+UPDATE users SET posts_count = (
+    SELECT COUNT(*) FROM posts WHERE posts.user_id = users.id AND posts.status = 'published'
+);
+UPDATE tags SET posts_count = (
+    SELECT COUNT(*)
+    FROM post_tags
+    JOIN posts ON posts.id = post_tags.post_id
+    WHERE post_tags.tag_id = tags.id AND posts.status = 'published'
+);
+
+-- +goose Down
+DROP TRIGGER IF EXISTS trg_update_post_tags_posts_count_on_status ON posts;
+DROP TRIGGER IF EXISTS trg_update_post_tags_posts_count_on_delete ON posts;
+DROP FUNCTION IF EXISTS update_post_tags_posts_count;
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION update_tag_posts_count()
+RETURNS TRIGGER
+AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE tags SET posts_count = posts_count + 1 WHERE id = NEW.tag_id;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE tags SET posts_count = posts_count - 1 WHERE id = OLD.tag_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE PLPGSQL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION update_user_posts_count()
+RETURNS TRIGGER
+AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE users SET posts_count = posts_count + 1 WHERE id = NEW.user_id;
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE users SET posts_count = posts_count - 1 WHERE id = OLD.user_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE PLPGSQL;
+-- +goose StatementEnd
+
+DROP TRIGGER trg_update_user_posts_count ON posts;
+CREATE TRIGGER trg_update_user_posts_count
+AFTER INSERT OR DELETE ON posts FOR EACH ROW
+EXECUTE FUNCTION update_user_posts_count();
+
+UPDATE users SET posts_count = (SELECT COUNT(*) FROM posts WHERE posts.user_id = users.id);
+UPDATE tags SET posts_count = (SELECT COUNT(*) FROM post_tags WHERE post_tags.tag_id = tags.id);
@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- outbox of ActivityPub activities to deliver to remote inboxes, signed by the local actor of user_id.
+-- Activities are written in the same transaction as the change they're about, then sent by the
+-- ActivityPub workers. Failing deliveries are retried with a backoff, and dropped after too many attempts.
+CREATE TABLE activity_deliveries(
+    id UUID DEFAULT generate_ulid_as_uuid(),
+    user_id UUID NOT NULL,
+    inbox_url VARCHAR(2048) NOT NULL,
+    activity JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY(id),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON activity_deliveries(next_attempt_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS activity_deliveries;
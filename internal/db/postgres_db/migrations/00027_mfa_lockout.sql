@@ -0,0 +1,12 @@
+-- +goose Up
+
+-- the wrong codes entered for a user, across mfa challenges and the endpoints asking for a code.
+-- Too many lock two-factor authentication until locked_until, which restarts the count.
+ALTER TABLE totp_authenticators
+ADD COLUMN failed_attempts INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN locked_until TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE totp_authenticators
+DROP COLUMN IF EXISTS locked_until,
+DROP COLUMN IF EXISTS failed_attempts;
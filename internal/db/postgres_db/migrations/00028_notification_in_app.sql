@@ -0,0 +1,82 @@
+-- +goose Up
+
+-- a notification is created when the receiver wants it in-app or in the email digest. in_app is false
+-- for the ones only wanted in the digest, which are kept out of the app (listing, counts and streaming).
+ALTER TABLE notifications ADD COLUMN in_app BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- whether the receiver wants a notification of the kind in-app, according to their preferences.
+-- +goose StatementBegin
+CREATE FUNCTION notify_in_app(p_user_id UUID, p_kind_id INTEGER)
+RETURNS BOOLEAN
+AS $$
+    SELECT COALESCE(
+        (SELECT in_app FROM notification_preferences WHERE user_id = p_user_id AND kind_id = p_kind_id),
+        TRUE
+    );
+$$ LANGUAGE SQL STABLE;
+-- +goose StatementEnd
+
+-- whether a notification should be created, in-app or for the digest, according to the receiver's
+-- preferences and mutes.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION should_notify(p_user_id UUID, p_kind_id INTEGER, p_sender_id UUID, p_post_id UUID)
+RETURNS BOOLEAN
+AS $$
+    SELECT
+        COALESCE(
+            (SELECT in_app OR digest FROM notification_preferences WHERE user_id = p_user_id AND kind_id = p_kind_id),
+            TRUE
+        ) AND
+        NOT EXISTS(
+            SELECT 1 FROM notification_mutes
+            WHERE user_id = p_user_id AND (muted_user_id = p_sender_id OR muted_post_id = p_post_id)
+        );
+$$ LANGUAGE SQL STABLE;
+-- +goose StatementEnd
+
+-- only in-app notifications are streamed.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION notify_new_notification()
+RETURNS TRIGGER
+AS $$
+BEGIN
+    IF NEW.in_app THEN
+        PERFORM pg_notify('new_notification', NEW.user_id::TEXT);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE PLPGSQL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION notify_new_notification()
+RETURNS TRIGGER
+AS $$
+BEGIN
+    PERFORM pg_notify('new_notification', NEW.user_id::TEXT);
+    RETURN NULL;
+END;
+$$ LANGUAGE PLPGSQL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION should_notify(p_user_id UUID, p_kind_id INTEGER, p_sender_id UUID, p_post_id UUID)
+RETURNS BOOLEAN
+AS $$
+    SELECT
+        COALESCE(
+            (SELECT in_app FROM notification_preferences WHERE user_id = p_user_id AND kind_id = p_kind_id),
+            TRUE
+        ) AND
+        NOT EXISTS(
+            SELECT 1 FROM notification_mutes
+            WHERE user_id = p_user_id AND (muted_user_id = p_sender_id OR muted_post_id = p_post_id)
+        );
+$$ LANGUAGE SQL STABLE;
+-- +goose StatementEnd
+
+DROP FUNCTION IF EXISTS notify_in_app;
+
+DELETE FROM notifications WHERE NOT in_app;
+ALTER TABLE notifications DROP COLUMN IF EXISTS in_app;
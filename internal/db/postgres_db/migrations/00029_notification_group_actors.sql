@@ -0,0 +1,34 @@
+-- +goose Up
+
+-- a grouped notification is replaced by a new one on every merge, group_id stays the same across them.
+ALTER TABLE notifications ADD COLUMN group_id UUID;
+
+CREATE INDEX ON notifications(group_id) WHERE group_id IS NOT NULL;
+
+-- all the distinct actors of a group, as actor_ids only holds the most recent ones, so actors_count only
+-- counts an actor once. The actors of closed groups are purged by the notification retention.
+CREATE TABLE notification_group_actors(
+    group_id UUID,
+    actor_id UUID,
+
+    PRIMARY KEY(group_id, actor_id),
+    FOREIGN KEY(actor_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- only the open groups can get new notifications. Their actors beyond actor_ids are unknown.
+UPDATE notifications SET group_id = generate_ulid_as_uuid()
+WHERE
+    is_read = FALSE AND
+    kind_id IN (SELECT id FROM notification_kinds WHERE name IN ('new_follower', 'new_comment', 'new_reaction'));
+
+INSERT INTO notification_group_actors(group_id, actor_id)
+SELECT n.group_id, actors.actor_id
+FROM notifications n, UNNEST(n.actor_ids) AS actors(actor_id)
+WHERE n.group_id IS NOT NULL AND EXISTS(SELECT 1 FROM users WHERE id = actors.actor_id)
+ON CONFLICT DO NOTHING;
+
+-- +goose Down
+DROP TABLE IF EXISTS notification_group_actors;
+
+DROP INDEX IF EXISTS notifications_group_id_idx;
+ALTER TABLE notifications DROP COLUMN IF EXISTS group_id;
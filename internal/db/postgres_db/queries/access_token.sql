@@ -0,0 +1,17 @@
+-- name: GetAccessTokenState :one
+-- returns what's needed to check an unexpired access token. No rows means its user is deleted.
+SELECT
+    users.tokens_valid_after,
+    EXISTS(SELECT 1 FROM sessions WHERE sessions.id = sqlc.arg(session_id) AND sessions.user_id = users.id) AS session_exists,
+    EXISTS(SELECT 1 FROM revoked_access_tokens WHERE revoked_access_tokens.jti = sqlc.arg(jti)) AS revoked
+FROM users
+WHERE users.id = sqlc.arg(user_id);
+
+-- name: RevokeAccessToken :exec
+INSERT INTO revoked_access_tokens(jti, user_id, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: DeleteExpiredRevokedAccessTokens :exec
+-- expired tokens are rejected anyway, so they don't need to be kept.
+DELETE FROM revoked_access_tokens WHERE expires_at < NOW();
@@ -0,0 +1,81 @@
+-- name: CreateActorKey :exec
+-- does nothing if the user already has a key, so concurrent requests end up using the same one.
+INSERT INTO actor_keys(user_id, public_key_pem, private_key_pem)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO NOTHING;
+
+-- name: GetActorKey :one
+SELECT *
+FROM actor_keys
+WHERE user_id = $1;
+
+-- name: CreateRemoteFollower :exec
+-- a repeated follow refreshes the inboxes of the follower.
+INSERT INTO remote_followers(user_id, actor_id, inbox_url, shared_inbox_url)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, actor_id) DO UPDATE
+SET inbox_url = EXCLUDED.inbox_url, shared_inbox_url = EXCLUDED.shared_inbox_url;
+
+-- name: DeleteRemoteFollower :execrows
+DELETE FROM remote_followers
+WHERE user_id = $1 AND actor_id = $2;
+
+-- name: GetRemoteFollowersCount :one
+SELECT COUNT(*)
+FROM remote_followers
+WHERE user_id = $1;
+
+-- name: GetUserOutboxPosts :many
+SELECT *
+FROM posts
+WHERE
+    -- filter
+    user_id = $1 AND
+    status = 'published' AND
+    -- cursor
+    (is_zero_uuid($3::UUID) OR id <= $3::UUID)
+ORDER BY id DESC
+LIMIT $2;
+
+-- name: CreateActivityDelivery :exec
+INSERT INTO activity_deliveries(user_id, inbox_url, activity)
+VALUES ($1, $2, $3);
+
+-- name: CreateFollowersActivityDeliveries :exec
+-- queues a delivery of the activity to every remote follower of the user, once per server when
+-- followers share an inbox.
+INSERT INTO activity_deliveries(user_id, inbox_url, activity)
+SELECT DISTINCT sqlc.arg(user_id)::UUID, COALESCE(shared_inbox_url, inbox_url), sqlc.arg(activity)::JSONB
+FROM remote_followers
+WHERE user_id = sqlc.arg(user_id)::UUID;
+
+-- name: ClaimActivityDelivery :one
+-- leases the next due delivery by moving its next attempt to lease_until, so it's not claimed again
+-- while it's being sent. If the result is never recorded, it's sent again after the lease.
+WITH due AS (
+    SELECT id FROM activity_deliveries
+    WHERE next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE activity_deliveries d
+SET next_attempt_at = sqlc.arg(lease_until)
+FROM due
+WHERE d.id = due.id
+RETURNING
+    d.id,
+    d.user_id,
+    d.inbox_url,
+    d.activity,
+    d.attempts;
+
+-- name: RetryActivityDelivery :exec
+UPDATE activity_deliveries
+SET
+    attempts = attempts + 1,
+    next_attempt_at = $1
+WHERE id = $2;
+
+-- name: DeleteActivityDelivery :exec
+DELETE FROM activity_deliveries WHERE id = $1;
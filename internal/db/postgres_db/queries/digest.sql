@@ -0,0 +1,67 @@
+-- name: ClaimDueDigestUser :one
+-- claims the next user with a verified email whose daily/weekly digest is due.
+SELECT id, username, email, digest_frequency, last_digest_sent_at
+FROM users
+WHERE
+    email_verified_at IS NOT NULL AND
+    digest_frequency <> 'never' AND
+    (
+        last_digest_sent_at IS NULL OR
+        last_digest_sent_at <= NOW() - (CASE digest_frequency WHEN 'daily' THEN INTERVAL '1 day' ELSE INTERVAL '7 days' END)
+    )
+ORDER BY last_digest_sent_at NULLS FIRST
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: GetDigestNotifications :many
+-- gets the unread notifications of the user created after since, of the kinds the user included in the digest.
+-- It includes the ones kept out of the app (in_app is false).
+SELECT
+    n.id,
+    nk.name as kind,
+    sender.username as sender_username,
+    p.title as post_title,
+    n.actors_count,
+    n.created_at
+FROM notifications n
+JOIN notification_kinds nk ON nk.id = n.kind_id
+JOIN notification_preferences np ON np.user_id = n.user_id AND np.kind_id = n.kind_id AND np.digest
+LEFT JOIN users sender ON sender.id = n.sender_id
+LEFT JOIN posts p ON p.id = n.post_id
+WHERE
+    n.user_id = $1 AND
+    n.is_read = false AND
+    n.created_at > sqlc.arg(since)::TIMESTAMP
+ORDER BY n.id DESC
+LIMIT $2;
+
+-- name: GetDigestTopPosts :many
+-- gets the most viewed posts published after since by the authors the user follows.
+SELECT
+    p.id,
+    p.title,
+    p.slug,
+    u.username as author_username,
+    p.views_count
+FROM follows f
+JOIN posts p ON p.user_id = f.followed_id
+JOIN users u ON u.id = p.user_id
+WHERE
+    f.follower_id = $1 AND
+    p.status = 'published' AND
+    p.published_at > sqlc.arg(since)::TIMESTAMP
+ORDER BY p.views_count DESC, p.id DESC
+LIMIT $2;
+
+-- name: MarkDigestOnlyNotificationsAsRead :exec
+-- marks the unread notifications kept out of the app up to until_id as read once they're in a digest,
+-- as they can't be read in the app, so they're purged like the other read notifications.
+UPDATE notifications SET is_read = true
+WHERE
+    user_id = $1 AND
+    NOT in_app AND
+    is_read = false AND
+    id <= sqlc.arg(until_id)::UUID;
+
+-- name: MarkDigestSent :exec
+UPDATE users SET last_digest_sent_at = NOW() WHERE id = $1;
@@ -0,0 +1,29 @@
+-- name: UpdateUserEmail :exec
+-- sets a new unverified email.
+UPDATE users SET email = $1, email_verified_at = NULL WHERE id = $2;
+
+-- name: CheckVerifiedEmail :one
+-- checks if the email is verified by another user.
+SELECT EXISTS(SELECT 1 FROM users WHERE email = $1 AND email_verified_at IS NOT NULL AND id <> $2);
+
+-- name: VerifyUserEmail :execrows
+-- verifies the email of the user, if it wasn't changed since the token was sent.
+UPDATE users SET email_verified_at = NOW() WHERE id = $1 AND email = $2;
+
+-- name: CreateEmailVerificationToken :exec
+INSERT INTO email_verification_tokens(token, user_id, email, expires_at)
+VALUES($1, $2, $3, $4);
+
+-- name: GetEmailVerificationToken :one
+SELECT * FROM email_verification_tokens WHERE token = $1;
+
+-- name: DeleteUserEmailVerificationTokens :exec
+DELETE FROM email_verification_tokens WHERE user_id = $1;
+
+-- name: UpdateUserDigestFrequency :exec
+-- the first digest covers the period starting now.
+UPDATE users
+SET
+    digest_frequency = $1,
+    last_digest_sent_at = COALESCE(last_digest_sent_at, NOW())
+WHERE id = $2;
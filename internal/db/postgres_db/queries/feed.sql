@@ -0,0 +1,30 @@
+-- name: GetUserFeedState :one
+-- summarizes the published posts of the user, to tell if its feed has changed without building it.
+SELECT
+    COUNT(*) AS posts_count,
+    COALESCE(MAX(updated_at), 'epoch')::TIMESTAMP AS last_updated_at
+FROM posts
+WHERE user_id = $1 AND status = 'published';
+
+-- name: GetSiteFeedState :one
+-- summarizes all published posts, to tell if the site feed has changed without building it.
+SELECT
+    COUNT(*) AS posts_count,
+    COALESCE(MAX(updated_at), 'epoch')::TIMESTAMP AS last_updated_at
+FROM posts
+WHERE status = 'published';
+
+-- name: GetUserFeedPosts :many
+SELECT *
+FROM posts
+WHERE user_id = $1 AND status = 'published'
+ORDER BY published_at DESC, id DESC
+LIMIT $2;
+
+-- name: GetSiteFeedPosts :many
+SELECT sqlc.embed(posts), users.username AS author_username, users.name AS author_name
+FROM posts
+JOIN users ON users.id = posts.user_id
+WHERE posts.status = 'published'
+ORDER BY posts.published_at DESC, posts.id DESC
+LIMIT $1;
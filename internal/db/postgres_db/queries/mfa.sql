@@ -0,0 +1,78 @@
+-- name: CreateTotpAuthenticator :execrows
+-- enrolls a new authenticator, replacing an unconfirmed one. No rows are affected if the user already
+-- has a confirmed authenticator.
+INSERT INTO totp_authenticators(user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, created_at = NOW()
+WHERE totp_authenticators.confirmed_at IS NULL;
+
+-- name: GetTotpAuthenticator :one
+SELECT * FROM totp_authenticators WHERE user_id = $1;
+
+-- name: CheckMfaEnabled :one
+SELECT EXISTS(SELECT 1 FROM totp_authenticators WHERE user_id = $1 AND confirmed_at IS NOT NULL);
+
+-- name: ConfirmTotpAuthenticator :execrows
+UPDATE totp_authenticators
+SET confirmed_at = NOW(), last_used_step = $2
+WHERE user_id = $1 AND confirmed_at IS NULL;
+
+-- name: UseTotpStep :execrows
+-- records the step of an accepted code. No rows are affected if a code of the same or a later step
+-- was already used, which means the code is replayed.
+UPDATE totp_authenticators
+SET last_used_step = $2
+WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2;
+
+-- name: RecordMfaFailure :one
+-- counts a wrong code. Reaching max_failed_attempts locks two-factor authentication until locked_until
+-- and restarts the count.
+UPDATE totp_authenticators
+SET
+    failed_attempts = CASE
+        WHEN failed_attempts + 1 >= sqlc.arg(max_failed_attempts)::INTEGER THEN 0
+        ELSE failed_attempts + 1
+    END,
+    locked_until = CASE
+        WHEN failed_attempts + 1 >= sqlc.arg(max_failed_attempts)::INTEGER THEN sqlc.arg(locked_until)::TIMESTAMP
+        ELSE locked_until
+    END
+WHERE user_id = sqlc.arg(user_id)
+RETURNING locked_until;
+
+-- name: ResetMfaFailures :exec
+UPDATE totp_authenticators
+SET failed_attempts = 0, locked_until = NULL
+WHERE user_id = $1;
+
+-- name: DeleteTotpAuthenticator :exec
+DELETE FROM totp_authenticators WHERE user_id = $1;
+
+-- name: CreateRecoveryCodes :exec
+INSERT INTO recovery_codes(user_id, code_hash)
+SELECT sqlc.arg(user_id)::UUID, UNNEST(sqlc.arg(code_hashes)::VARCHAR[]);
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: DeleteAllUserRecoveryCodes :exec
+DELETE FROM recovery_codes WHERE user_id = $1;
+
+-- name: CreateMfaChallenge :exec
+INSERT INTO mfa_challenges(token, user_id, expires_at)
+VALUES ($1, $2, $3);
+
+-- name: AttemptMfaChallenge :one
+-- counts an attempt to complete the challenge. No rows means it doesn't exist or has no attempts left.
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE token = sqlc.arg(token) AND attempts < sqlc.arg(max_attempts)
+RETURNING *;
+
+-- name: DeleteMfaChallenge :execrows
+DELETE FROM mfa_challenges WHERE token = $1;
+
+-- name: DeleteExpiredMfaChallenges :exec
+DELETE FROM mfa_challenges WHERE expires_at < NOW();
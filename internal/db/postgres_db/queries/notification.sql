@@ -1,15 +1,15 @@
 -- name: CreateNotification :one
-INSERT INTO notifications(kind_id, user_id, sender_id, post_id, is_read)
-VALUES($1, $2, $3, $4, $5)
+INSERT INTO notifications(kind_id, user_id, sender_id, post_id, comment_id, is_read)
+VALUES($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: GetNotificationsCount :one
 SELECT COUNT(*) FROM notifications
-WHERE user_id = $1;
+WHERE user_id = $1 AND in_app;
 
 -- name: GetUnreadNotificationsCount :one
 SELECT COUNT(*) FROM notifications
-WHERE user_id = $1 AND is_read = false;
+WHERE user_id = $1 AND in_app AND is_read = false;
 
 -- name: GetAllNotifications :many
 SELECT 
@@ -18,6 +18,9 @@ SELECT
     n.user_id,
     n.sender_id,
     n.post_id,
+    n.comment_id,
+    n.actor_ids,
+    n.actors_count,
     n.is_read,
     n.created_at
 FROM notifications n
@@ -25,13 +28,223 @@ JOIN notification_kinds nk ON nk.id = n.kind_id
 WHERE
     -- filter
     n.user_id = $1 AND
+    n.in_app AND
+    (NOT sqlc.arg(unread_only)::BOOLEAN OR n.is_read = false) AND
     -- cursor
     (is_zero_uuid(sqlc.arg(ID)::UUID) OR id <= sqlc.arg(ID)::UUID)
 ORDER BY n.id DESC
 LIMIT $2;
 
+-- name: GetAllNotificationsAfter :many
+-- used to stream notifications, oldest first.
+SELECT 
+    n.id,
+    nk.name as kind,
+    n.user_id,
+    n.sender_id,
+    n.post_id,
+    n.comment_id,
+    n.actor_ids,
+    n.actors_count,
+    n.is_read,
+    n.created_at
+FROM notifications n
+JOIN notification_kinds nk ON nk.id = n.kind_id
+WHERE
+    n.user_id = $1 AND
+    n.in_app AND
+    n.id > sqlc.arg(ID)::UUID
+ORDER BY n.id ASC
+LIMIT $2;
+
+-- name: GetLatestNotificationID :one
+SELECT id FROM notifications
+WHERE user_id = $1
+ORDER BY id DESC
+LIMIT 1;
+
 -- name: CheckNotificationForUser :one
-SELECT EXISTS(SELECT 1 FROM notifications WHERE id = $1 AND user_id = $2);
+SELECT EXISTS(SELECT 1 FROM notifications WHERE id = $1 AND user_id = $2 AND in_app);
 
 -- name: MarkNotificationAsRead :exec
 UPDATE notifications SET is_read = true WHERE id = $1;
+
+-- name: MarkNotificationsAsRead :execrows
+UPDATE notifications SET is_read = true
+WHERE user_id = $1 AND in_app AND is_read = false AND id = ANY(sqlc.arg(ids)::UUID[]);
+
+-- name: MarkAllNotificationsAsRead :execrows
+-- marks all notifications of the user as read, or only the ones up to until_id if it's not zero.
+UPDATE notifications SET is_read = true
+WHERE
+    user_id = $1 AND
+    in_app AND
+    is_read = false AND
+    (is_zero_uuid(sqlc.arg(until_id)::UUID) OR id <= sqlc.arg(until_id)::UUID);
+
+-- name: DeleteNotification :exec
+DELETE FROM notifications WHERE id = $1;
+
+-- name: DeleteAllReadNotifications :execrows
+DELETE FROM notifications WHERE user_id = $1 AND is_read = true;
+
+-- name: DeleteOldReadNotifications :execrows
+-- deletes up to limit read notifications created before the given time.
+DELETE FROM notifications
+WHERE id IN (
+    SELECT id FROM notifications
+    WHERE is_read = true AND created_at < sqlc.arg(before)::TIMESTAMP
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+);
+
+-- name: DeleteClosedNotificationGroupActors :execrows
+-- deletes up to limit actors of the groups that can't get new notifications: their notification is read,
+-- deleted, or was created before since.
+DELETE FROM notification_group_actors
+WHERE (group_id, actor_id) IN (
+    SELECT a.group_id, a.actor_id FROM notification_group_actors a
+    WHERE NOT EXISTS(
+        SELECT 1 FROM notifications n
+        WHERE n.group_id = a.group_id AND n.is_read = FALSE AND n.created_at > sqlc.arg(since)::TIMESTAMP
+    )
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+);
+
+-- name: CreateNotificationEvent :exec
+INSERT INTO notification_events(kind_id, user_id, sender_id, post_id, comment_id)
+VALUES($1, $2, $3, $4, $5);
+
+-- name: ClaimNotificationEvent :one
+SELECT *
+FROM notification_events
+WHERE status = 'pending' AND next_attempt_at <= NOW()
+ORDER BY next_attempt_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: DeleteNotificationEvent :exec
+DELETE FROM notification_events WHERE id = $1;
+
+-- name: RetryNotificationEvent :exec
+UPDATE notification_events
+SET
+    attempts = attempts + 1,
+    next_attempt_at = $1,
+    last_error = $2
+WHERE id = $3;
+
+-- name: KillNotificationEvent :exec
+UPDATE notification_events
+SET
+    status = 'dead',
+    attempts = attempts + 1,
+    last_error = $1
+WHERE id = $2;
+
+-- name: CreateNotificationIfWanted :exec
+-- creates the notification, unless the receiver opted out of it.
+INSERT INTO notifications(kind_id, user_id, sender_id, post_id, comment_id, actor_ids, in_app)
+SELECT
+    sqlc.arg(kind_id)::INTEGER,
+    sqlc.arg(user_id)::UUID,
+    sqlc.narg(sender_id)::UUID,
+    sqlc.narg(post_id)::UUID,
+    sqlc.narg(comment_id)::UUID,
+    array_remove(ARRAY[sqlc.narg(sender_id)::UUID], NULL),
+    notify_in_app(sqlc.arg(user_id)::UUID, sqlc.arg(kind_id)::INTEGER)
+WHERE should_notify(sqlc.arg(user_id)::UUID, sqlc.arg(kind_id)::INTEGER, sqlc.narg(sender_id)::UUID, sqlc.narg(post_id)::UUID);
+
+-- name: LockNotificationGroup :exec
+-- serializes the creation of grouped notifications of the same group until the end of the transaction.
+SELECT pg_advisory_xact_lock(hashtextextended(
+    sqlc.arg(user_id)::UUID::TEXT || ':' || sqlc.arg(kind_id)::INTEGER::TEXT || ':' || COALESCE(sqlc.narg(post_id)::UUID::TEXT, ''),
+    0
+));
+
+-- name: CreateGroupedNotificationIfWanted :exec
+-- creates the notification, unless the receiver opted out of it, merging it with the latest unread notification
+-- of the same kind and post created after since, if any. The merged notification is replaced with a new one
+-- of the same group, so it's listed and streamed as the latest notification. The sender is only counted
+-- in actors_count if they're new to the group.
+WITH previous AS (
+    DELETE FROM notifications
+    WHERE
+        id = (
+            SELECT id FROM notifications
+            WHERE
+                user_id = sqlc.arg(user_id)::UUID AND
+                kind_id = sqlc.arg(kind_id)::INTEGER AND
+                post_id IS NOT DISTINCT FROM sqlc.narg(post_id)::UUID AND
+                is_read = FALSE AND
+                created_at > sqlc.arg(since)::TIMESTAMP
+            ORDER BY id DESC
+            LIMIT 1
+        ) AND
+        should_notify(sqlc.arg(user_id)::UUID, sqlc.arg(kind_id)::INTEGER, sqlc.arg(sender_id)::UUID, sqlc.narg(post_id)::UUID)
+    RETURNING group_id, actor_ids, actors_count
+),
+notification_group AS MATERIALIZED (
+    SELECT COALESCE((SELECT group_id FROM previous), generate_ulid_as_uuid()) AS id
+    WHERE should_notify(sqlc.arg(user_id)::UUID, sqlc.arg(kind_id)::INTEGER, sqlc.arg(sender_id)::UUID, sqlc.narg(post_id)::UUID)
+),
+new_actor AS (
+    INSERT INTO notification_group_actors(group_id, actor_id)
+    SELECT id, sqlc.arg(sender_id)::UUID FROM notification_group
+    ON CONFLICT DO NOTHING
+    RETURNING actor_id
+)
+INSERT INTO notifications(kind_id, user_id, sender_id, post_id, comment_id, actor_ids, actors_count, in_app, group_id)
+SELECT
+    sqlc.arg(kind_id)::INTEGER,
+    sqlc.arg(user_id)::UUID,
+    sqlc.arg(sender_id)::UUID,
+    sqlc.narg(post_id)::UUID,
+    sqlc.narg(comment_id)::UUID,
+    (sqlc.arg(sender_id)::UUID || array_remove(COALESCE(previous.actor_ids, '{}'), sqlc.arg(sender_id)::UUID))[1:sqlc.arg(max_actors)::INTEGER],
+    COALESCE(previous.actors_count, 0) + (SELECT COUNT(*) FROM new_actor)::INTEGER,
+    notify_in_app(sqlc.arg(user_id)::UUID, sqlc.arg(kind_id)::INTEGER),
+    notification_group.id
+FROM notification_group
+LEFT JOIN previous ON TRUE;
+
+-- name: CreateFollowersNotifications :exec
+INSERT INTO notifications(kind_id, user_id, sender_id, post_id, actor_ids, in_app)
+SELECT
+    sqlc.arg(kind_id)::INTEGER,
+    follower_id,
+    sqlc.arg(sender_id)::UUID,
+    sqlc.narg(post_id)::UUID,
+    ARRAY[sqlc.arg(sender_id)::UUID],
+    notify_in_app(follower_id, sqlc.arg(kind_id)::INTEGER)
+FROM follows
+WHERE
+    followed_id = sqlc.arg(sender_id)::UUID AND
+    should_notify(follower_id, sqlc.arg(kind_id)::INTEGER, sqlc.arg(sender_id)::UUID, sqlc.narg(post_id)::UUID);
+
+-- name: CreateTopicSubscribersNotifications :exec
+INSERT INTO notifications(kind_id, user_id, sender_id, post_id, actor_ids, in_app)
+SELECT DISTINCT
+    sqlc.arg(kind_id)::INTEGER,
+    topic_subscriptions.user_id,
+    posts.user_id,
+    posts.id,
+    ARRAY[posts.user_id],
+    notify_in_app(topic_subscriptions.user_id, sqlc.arg(kind_id)::INTEGER)
+FROM topic_subscriptions, posts
+WHERE
+    posts.id = sqlc.arg(post_id)::UUID AND
+    topic_subscriptions.user_id <> posts.user_id AND
+    should_notify(topic_subscriptions.user_id, sqlc.arg(kind_id)::INTEGER, posts.user_id, posts.id) AND
+    -- followers already get a 'new_post' notification
+    NOT EXISTS(
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = topic_subscriptions.user_id AND follows.followed_id = posts.user_id
+    ) AND
+    to_tsvector('english', posts.title || ' ' || posts.content) @@ (
+        CASE WHEN topic_subscriptions.is_tsquery
+        THEN to_tsquery('english', topic_subscriptions.query)
+        ELSE plainto_tsquery('english', topic_subscriptions.query)
+        END
+    );
@@ -0,0 +1,46 @@
+-- name: GetNotificationKindIDByName :one
+SELECT id FROM notification_kinds WHERE name = $1;
+
+-- name: GetNotificationPreferences :many
+-- returns the preferences of every kind, with the defaults for kinds the user didn't set.
+SELECT
+    nk.name AS kind,
+    COALESCE(np.in_app, TRUE)::BOOLEAN AS in_app,
+    COALESCE(np.digest, FALSE)::BOOLEAN AS digest
+FROM notification_kinds nk
+LEFT JOIN notification_preferences np ON np.kind_id = nk.id AND np.user_id = $1
+ORDER BY nk.id;
+
+-- name: UpsertNotificationPreference :exec
+INSERT INTO notification_preferences(user_id, kind_id, in_app, digest)
+VALUES($1, $2, $3, $4)
+ON CONFLICT(user_id, kind_id) DO UPDATE
+SET in_app = EXCLUDED.in_app, digest = EXCLUDED.digest;
+
+-- name: CreateNotificationMute :one
+INSERT INTO notification_mutes(user_id, muted_user_id, muted_post_id)
+VALUES($1, $2, $3)
+RETURNING *;
+
+-- name: CheckNotificationMute :one
+SELECT EXISTS(
+    SELECT 1 FROM notification_mutes
+    WHERE user_id = $1 AND (muted_user_id = $2 OR muted_post_id = $3)
+);
+
+-- name: CheckUserOwnsNotificationMute :one
+SELECT EXISTS(SELECT 1 FROM notification_mutes WHERE id = $1 AND user_id = $2);
+
+-- name: DeleteNotificationMute :exec
+DELETE FROM notification_mutes WHERE id = $1;
+
+-- name: GetAllNotificationMutes :many
+SELECT *
+FROM notification_mutes
+WHERE
+    -- filter
+    user_id = $1 AND
+    -- cursor
+    (is_zero_uuid(sqlc.arg(ID)::UUID) OR id <= sqlc.arg(ID)::UUID)
+ORDER BY id DESC
+LIMIT $2;
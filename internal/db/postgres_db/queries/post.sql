@@ -1,11 +1,15 @@
 -- name: CreatePost :one
-INSERT INTO posts(user_id, title, content, featured_image_url)
-VALUES($1, $2, $3, $4)
+INSERT INTO posts(user_id, title, slug, content, content_html, toc, featured_image_url, status, publish_at)
+VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
 RETURNING *;
 
 -- name: GetPost :one
 SELECT * FROM posts WHERE id = $1;
 
+-- name: GetPostForUpdate :one
+-- locks the post until the end of the transaction, so concurrent changes of its status are serialized.
+SELECT * FROM posts WHERE id = $1 FOR UPDATE;
+
 -- name: GetPostReactions :many
 SELECT 
     rk.name,
@@ -27,6 +31,13 @@ SET kind_id = EXCLUDED.kind_id;
 -- name: CheckPost :one
 SELECT EXISTS(select 1 FROM posts WHERE id = $1);
 
+-- name: CheckPostVisible :one
+-- checks that the post exists and the user can interact with it: it's published or unlisted, or it's theirs.
+SELECT EXISTS(
+    SELECT 1 FROM posts
+    WHERE id = $1 AND (status IN ('published', 'unlisted') OR user_id = $2)
+);
+
 -- name: CheckUserOwnsPost :one
 SELECT EXISTS(select 1 FROM posts WHERE id = $1 AND user_id = $2);
 
@@ -35,10 +46,34 @@ UPDATE posts
 SET 
     title = $1,
     content = $2,
-    featured_image_url = $3
-WHERE id = $4
+    content_html = $3,
+    toc = $4,
+    featured_image_url = $5
+WHERE id = $6
 RETURNING *;
 
+-- name: PublishPost :one
+UPDATE posts
+SET
+    status = 'published',
+    published_at = COALESCE(published_at, NOW())
+WHERE id = $1 AND status <> 'published'
+RETURNING *;
+
+-- name: UnlistPost :one
+UPDATE posts
+SET status = 'unlisted'
+WHERE id = $1
+RETURNING *;
+
+-- name: GetDueScheduledPosts :many
+SELECT *
+FROM posts
+WHERE status = 'scheduled' AND publish_at <= NOW()
+ORDER BY publish_at
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
 -- name: DeletePost :exec
 DELETE FROM posts WHERE id = $1;
 
@@ -71,10 +106,18 @@ VALUES($1, $2)
 ON CONFLICT(post_id, user_id) DO NOTHING;
 
 -- name: CreateComment :one
-INSERT INTO post_comments(post_id, user_id, content)
-VALUES($1, $2, $3)
+INSERT INTO post_comments(post_id, user_id, content, content_html)
+VALUES($1, $2, $3, $4)
+RETURNING *;
+
+-- name: CreateReply :one
+INSERT INTO post_comments(post_id, user_id, parent_id, content, content_html)
+VALUES($1, $2, $3, $4, $5)
 RETURNING *;
 
+-- name: GetComment :one
+SELECT * FROM post_comments WHERE id = $1;
+
 -- name: CheckComment :one
 SELECT EXISTS(select 1 FROM post_comments WHERE id = $1);
 
@@ -83,8 +126,8 @@ SELECT EXISTS(select 1 FROM post_comments WHERE id = $1 AND user_id = $2);
 
 -- name: UpdateComment :one
 UPDATE post_comments
-SET content = $1
-WHERE id = $2
+SET content = $1, content_html = $2
+WHERE id = $3
 RETURNING *;
 
 -- name: DeleteComment :exec
@@ -148,6 +191,7 @@ FROM posts
 WHERE
     -- filter
     user_id = $1 AND
+    status = 'published' AND
     -- cursor
     (is_zero_uuid(sqlc.arg(ID)::UUID) OR id <= sqlc.arg(ID)::UUID)
 ORDER BY
@@ -155,12 +199,31 @@ ORDER BY
     id DESC
 LIMIT $2;
 
+-- name: GetAllUserDrafts :many
+SELECT *
+FROM posts
+WHERE
+    -- filter
+    user_id = $1 AND
+    status IN ('draft', 'scheduled') AND
+    -- cursor
+    (is_zero_uuid(sqlc.arg(ID)::UUID) OR id <= sqlc.arg(ID)::UUID)
+ORDER BY id DESC
+LIMIT $2;
+
 -- name: GetAllPosts :many
 SELECT *
 FROM posts
 WHERE
     -- filters
-    to_tsvector('english', title || ' ' || content) @@ to_tsquery('english', sqlc.arg(search_query)::VARCHAR) AND
+    status = 'published' AND
+    (sqlc.arg(search_query)::VARCHAR = '' OR to_tsvector('english', title || ' ' || content) @@ to_tsquery('english', sqlc.arg(search_query)::VARCHAR)) AND
+    (COALESCE(cardinality(sqlc.arg(tags)::VARCHAR[]), 0) = 0 OR EXISTS(
+        SELECT 1
+        FROM post_tags
+        JOIN tags ON post_tags.tag_id = tags.id
+        WHERE post_tags.post_id = posts.id AND tags.slug = ANY(sqlc.arg(tags)::VARCHAR[])
+    )) AND
     -- cursor
     (sqlc.arg(views_count)::INTEGER = 0 OR views_count <= sqlc.arg(views_count)::INTEGER) AND
     (is_zero_uuid(sqlc.arg(ID)::UUID) OR id <= sqlc.arg(ID)::UUID)
@@ -169,12 +232,37 @@ ORDER BY
     id DESC
 LIMIT $1;
 
+-- name: GetAllFeedPosts :many
+SELECT posts.*
+FROM follows
+JOIN posts ON follows.followed_id = posts.user_id
+WHERE
+    -- filter
+    follows.follower_id = $1 AND
+    posts.status = 'published' AND
+    -- cursor
+    (is_zero_uuid(sqlc.arg(ID)::UUID) OR posts.id <= sqlc.arg(ID)::UUID)
+ORDER BY posts.id DESC
+LIMIT $2;
+
 -- name: GetAllPostComments :many
 SELECT *
 FROM post_comments
 WHERE
     -- filters
     post_id = $1 AND
+    parent_id IS NULL AND
+    -- cursor
+    (is_zero_uuid(sqlc.arg(ID)::UUID) OR id <= sqlc.arg(ID)::UUID)
+ORDER BY id DESC
+LIMIT $2;
+
+-- name: GetAllCommentReplies :many
+SELECT *
+FROM post_comments
+WHERE
+    -- filters
+    parent_id = $1 AND
     -- cursor
     (is_zero_uuid(sqlc.arg(ID)::UUID) OR id <= sqlc.arg(ID)::UUID)
 ORDER BY id DESC
@@ -194,3 +282,27 @@ WHERE
     )
 ORDER BY bookmarks.created_at DESC
 LIMIT $2;
+
+-- name: GetUnrenderedPosts :many
+SELECT id, content
+FROM posts
+WHERE content_html IS NULL
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: UpdatePostRendering :exec
+UPDATE posts
+SET content_html = $1, toc = $2
+WHERE id = $3;
+
+-- name: GetUnrenderedComments :many
+SELECT id, content
+FROM post_comments
+WHERE content_html IS NULL
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: UpdateCommentRendering :exec
+UPDATE post_comments
+SET content_html = $1
+WHERE id = $2;
@@ -0,0 +1,13 @@
+-- name: GetPostRevision :one
+SELECT * FROM post_revisions WHERE id = $1 AND post_id = $2;
+
+-- name: GetAllPostRevisions :many
+SELECT *
+FROM post_revisions
+WHERE
+    -- filter
+    post_id = $1 AND
+    -- cursor
+    (is_zero_uuid(sqlc.arg(ID)::UUID) OR id <= sqlc.arg(ID)::UUID)
+ORDER BY id DESC
+LIMIT $2;
@@ -0,0 +1,24 @@
+-- name: CreateSession :one
+INSERT INTO sessions(user_id, user_agent, ip_address)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: TouchSession :exec
+UPDATE sessions SET last_used_at = NOW() WHERE id = $1;
+
+-- name: GetAllUserSessions :many
+SELECT *
+FROM sessions
+WHERE user_id = $1
+ORDER BY last_used_at DESC;
+
+-- name: DeleteSession :execrows
+-- deletes the session with its refresh tokens (by cascade).
+DELETE FROM sessions WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteAllUserSessions :exec
+DELETE FROM sessions WHERE user_id = $1;
+
+-- name: DeleteOtherUserSessions :exec
+-- deletes all sessions of the user but the given one.
+DELETE FROM sessions WHERE user_id = $1 AND id <> $2;
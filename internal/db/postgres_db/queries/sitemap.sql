@@ -0,0 +1,67 @@
+-- name: LockSitemapSync :one
+-- locks the sync state for a run of the sitemap generator. No row means another process is running it.
+SELECT synced_at
+FROM sitemap_sync
+FOR UPDATE SKIP LOCKED;
+
+-- name: UpdateSitemapSync :exec
+-- NOW() is the start time of the transaction, so changes committed during the run are picked up by the next one.
+UPDATE sitemap_sync
+SET synced_at = NOW();
+
+-- name: GetSitemapSyncedAt :one
+SELECT synced_at
+FROM sitemap_sync;
+
+-- name: UpsertSitemapPostURLs :execrows
+-- adds the posts published since the last run, and updates the lastmod of the updated ones.
+INSERT INTO sitemap_urls(post_id, lastmod)
+SELECT id, updated_at
+FROM posts
+WHERE status = 'published' AND updated_at > sqlc.arg(since)
+ON CONFLICT (post_id) DO UPDATE SET lastmod = EXCLUDED.lastmod;
+
+-- name: DeleteSitemapUnpublishedPostURLs :execrows
+-- removes the posts unpublished (or unlisted) since the last run. Deleted posts are removed by cascade.
+DELETE FROM sitemap_urls
+USING posts
+WHERE
+    sitemap_urls.post_id = posts.id AND
+    posts.status <> 'published' AND
+    posts.updated_at > sqlc.arg(since);
+
+-- name: UpsertSitemapUserURLs :execrows
+-- adds the users joined since the last run, and updates the lastmod of the users whose posts changed
+-- since then. A profile is last modified when its latest published post is.
+INSERT INTO sitemap_urls(user_id, lastmod)
+SELECT users.id, GREATEST(users.joined_at, MAX(posts.updated_at))
+FROM users
+LEFT JOIN posts ON posts.user_id = users.id AND posts.status = 'published'
+WHERE
+    users.joined_at > sqlc.arg(since) OR
+    users.id IN (SELECT user_id FROM posts WHERE updated_at > sqlc.arg(since))
+GROUP BY users.id
+ON CONFLICT (user_id) DO UPDATE SET lastmod = EXCLUDED.lastmod;
+
+-- name: GetSitemapURLsCount :one
+SELECT COUNT(*)
+FROM sitemap_urls;
+
+-- name: GetSitemapPages :many
+-- splits the urls into pages of page_size, returning the lastmod of each page.
+SELECT numbered.page::BIGINT AS page, MAX(numbered.lastmod)::TIMESTAMP AS lastmod
+FROM (
+    SELECT (ROW_NUMBER() OVER (ORDER BY id) - 1) / sqlc.arg(page_size)::BIGINT AS page, lastmod
+    FROM sitemap_urls
+) AS numbered
+GROUP BY numbered.page
+ORDER BY numbered.page;
+
+-- name: GetSitemapURLs :many
+SELECT sitemap_urls.lastmod, users.username, posts.slug
+FROM sitemap_urls
+LEFT JOIN posts ON posts.id = sitemap_urls.post_id
+JOIN users ON users.id = COALESCE(sitemap_urls.user_id, posts.user_id)
+ORDER BY sitemap_urls.id
+LIMIT $1
+OFFSET $2;
@@ -0,0 +1,25 @@
+-- name: GetTakenSlugs :many
+-- returns the slugs of the author's other posts that are, or could be, a suffixed version of the base slug.
+SELECT slug
+FROM post_slugs
+WHERE
+    user_id = $1 AND
+    post_id <> $2 AND
+    (slug = sqlc.arg(base_slug)::VARCHAR OR slug LIKE sqlc.arg(base_slug)::VARCHAR || '-%');
+
+-- name: CreatePostSlug :exec
+-- a post may get back one of its old slugs, which is already recorded.
+INSERT INTO post_slugs(post_id, user_id, slug)
+VALUES($1, $2, $3)
+ON CONFLICT(user_id, slug) DO NOTHING;
+
+-- name: UpdatePostSlug :exec
+UPDATE posts SET slug = $1 WHERE id = $2;
+
+-- name: GetPostBySlug :one
+-- resolves both current and old slugs.
+SELECT posts.*
+FROM post_slugs
+JOIN posts ON posts.id = post_slugs.post_id
+JOIN users ON users.id = post_slugs.user_id
+WHERE users.username = $1 AND post_slugs.slug = $2;
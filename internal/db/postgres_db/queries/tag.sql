@@ -0,0 +1,54 @@
+-- name: UpsertTag :one
+INSERT INTO tags(slug, name)
+VALUES($1, $2)
+ON CONFLICT(slug) DO UPDATE
+SET slug = EXCLUDED.slug
+RETURNING id;
+
+-- name: GetTagBySlug :one
+SELECT * FROM tags WHERE slug = $1;
+
+-- name: CreatePostTag :exec
+INSERT INTO post_tags(post_id, tag_id)
+VALUES($1, $2)
+ON CONFLICT(post_id, tag_id) DO NOTHING;
+
+-- name: DeletePostTags :exec
+DELETE FROM post_tags WHERE post_id = $1;
+
+-- name: GetPostTags :many
+SELECT tags.*
+FROM post_tags
+JOIN tags ON post_tags.tag_id = tags.id
+WHERE post_tags.post_id = $1
+ORDER BY tags.slug;
+
+-- name: GetTagsForPosts :many
+-- gets the tags of many posts at once, to list posts with their tags.
+SELECT post_tags.post_id, tags.slug, tags.name
+FROM post_tags
+JOIN tags ON post_tags.tag_id = tags.id
+WHERE post_tags.post_id = ANY(sqlc.arg(post_ids)::UUID[])
+ORDER BY post_tags.post_id, tags.slug;
+
+-- name: GetPopularTags :many
+SELECT *
+FROM tags
+WHERE posts_count > 0
+ORDER BY
+    posts_count DESC,
+    id
+LIMIT $1;
+
+-- name: GetAllTagPosts :many
+SELECT posts.*
+FROM post_tags
+JOIN posts ON post_tags.post_id = posts.id
+WHERE
+    -- filter
+    post_tags.tag_id = $1 AND
+    posts.status = 'published' AND
+    -- cursor
+    (is_zero_uuid(sqlc.arg(ID)::UUID) OR posts.id <= sqlc.arg(ID)::UUID)
+ORDER BY posts.id DESC
+LIMIT $2;
@@ -1,9 +1,16 @@
 -- name: CreateRefreshToken :exec
-INSERT INTO refresh_tokens(token, user_id, expires_at)
-VALUES($1, $2, $3);
+-- family_id is the id of the session the token is issued for.
+INSERT INTO refresh_tokens(token, user_id, expires_at, family_id)
+VALUES($1, $2, $3, $4);
 
 -- name: GetRefreshToken :one
 SELECT * FROM refresh_tokens WHERE token = $1;
 
+-- name: UseRefreshToken :one
+-- marks the token as used, only if it's not used yet. Concurrent uses of the same token can't both succeed.
+UPDATE refresh_tokens SET used_at = NOW()
+WHERE token = $1 AND used_at IS NULL
+RETURNING *;
+
 -- name: DeleteRefreshToken :exec
 DELETE FROM refresh_tokens WHERE token = $1;
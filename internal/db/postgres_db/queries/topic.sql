@@ -0,0 +1,33 @@
+-- name: CreateTopicSubscription :one
+INSERT INTO topic_subscriptions(user_id, query, is_tsquery)
+VALUES($1, $2, $3)
+RETURNING *;
+
+-- name: CheckTopicSubscription :one
+SELECT EXISTS(SELECT 1 FROM topic_subscriptions WHERE user_id = $1 AND query = $2 AND is_tsquery = $3);
+
+-- name: CheckUserOwnsTopicSubscription :one
+SELECT EXISTS(SELECT 1 FROM topic_subscriptions WHERE id = $1 AND user_id = $2);
+
+-- name: DeleteTopicSubscription :exec
+DELETE FROM topic_subscriptions WHERE id = $1;
+
+-- name: GetTsQueryNodesCount :one
+-- used to validate a topic query before subscribing to it.
+SELECT numnode(
+    CASE WHEN sqlc.arg(is_tsquery)::BOOLEAN
+    THEN to_tsquery('english', sqlc.arg(query)::VARCHAR)
+    ELSE plainto_tsquery('english', sqlc.arg(query)::VARCHAR)
+    END
+);
+
+-- name: GetAllUserTopicSubscriptions :many
+SELECT *
+FROM topic_subscriptions
+WHERE
+    -- filter
+    user_id = $1 AND
+    -- cursor
+    (is_zero_uuid(sqlc.arg(ID)::UUID) OR id <= sqlc.arg(ID)::UUID)
+ORDER BY id DESC
+LIMIT $2;
@@ -6,6 +6,9 @@ RETURNING *;
 -- name: GetUserByUsername :one
 SELECT * FROM users WHERE username = $1;
 
+-- name: GetUsersIDsByUsernames :many
+SELECT id FROM users WHERE username = ANY(sqlc.arg(usernames)::VARCHAR[]);
+
 -- name: GetUserByID :one
 SELECT * FROM users WHERE id = $1;
 
@@ -25,6 +28,11 @@ SET
 WHERE id = $5
 RETURNING *;
 
+-- name: UpdateUserTokensValidAfter :exec
+-- revokes the access tokens issued to the user so far. It's truncated to the second like the iat
+-- of the tokens, so the tokens issued right after, in the same second, are valid.
+UPDATE users SET tokens_valid_after = date_trunc('second', NOW()) WHERE id = $1;
+
 -- name: DeleteUser :exec
 DELETE FROM users WHERE id = $1;
 
@@ -0,0 +1,96 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks(user_id, url, secret, events)
+VALUES($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetAllUserWebhooks :many
+SELECT * FROM webhooks
+WHERE
+    -- filter
+    user_id = $1 AND
+    -- cursor
+    (is_zero_uuid(sqlc.arg(ID)::UUID) OR id <= sqlc.arg(ID)::UUID)
+ORDER BY id DESC
+LIMIT $2;
+
+-- name: CheckUserOwnsWebhook :one
+SELECT EXISTS(SELECT 1 FROM webhooks WHERE id = $1 AND user_id = $2);
+
+-- name: DeleteWebhook :exec
+DELETE FROM webhooks WHERE id = $1;
+
+-- name: CreateWebhookDeliveries :exec
+-- queues a delivery of the event to every webhook of the user subscribed to it.
+INSERT INTO webhook_deliveries(webhook_id, event, payload)
+SELECT id, sqlc.arg(event)::VARCHAR, sqlc.arg(payload)::JSONB
+FROM webhooks
+WHERE user_id = sqlc.arg(user_id)::UUID AND sqlc.arg(event)::VARCHAR = ANY(events);
+
+-- name: CreateWebhookRedelivery :one
+-- queues a new delivery with the same event and payload as the given one.
+INSERT INTO webhook_deliveries(webhook_id, event, payload)
+SELECT webhook_id, event, payload FROM webhook_deliveries WHERE id = $1
+RETURNING *;
+
+-- name: GetAllWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE
+    -- filter
+    webhook_id = $1 AND
+    -- cursor
+    (is_zero_uuid(sqlc.arg(ID)::UUID) OR id <= sqlc.arg(ID)::UUID)
+ORDER BY id DESC
+LIMIT $2;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries WHERE id = $1 AND webhook_id = $2;
+
+-- name: ClaimWebhookDelivery :one
+-- leases the next due delivery by moving its next attempt to lease_until, so it's not claimed again
+-- while it's being sent. If the result is never recorded, it's sent again after the lease.
+WITH due AS (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE webhook_deliveries d
+SET next_attempt_at = sqlc.arg(lease_until)
+FROM due, webhooks w
+WHERE d.id = due.id AND w.id = d.webhook_id
+RETURNING
+    d.id,
+    d.event,
+    d.payload,
+    d.attempts,
+    w.url,
+    w.secret;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET
+    status = 'succeeded',
+    attempts = attempts + 1,
+    last_status_code = $1,
+    last_error = NULL,
+    delivered_at = NOW()
+WHERE id = $2;
+
+-- name: RetryWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET
+    attempts = attempts + 1,
+    next_attempt_at = $1,
+    last_status_code = $2,
+    last_error = $3
+WHERE id = $4;
+
+-- name: FailWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET
+    status = 'failed',
+    attempts = attempts + 1,
+    last_status_code = $1,
+    last_error = $2
+WHERE id = $3;
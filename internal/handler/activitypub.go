@@ -0,0 +1,671 @@
+package handler
+
+import (
+	"context"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/activitypub"
+	"github.com/assaidy/blogging_app/internal/db/postgres_db"
+	"github.com/assaidy/blogging_app/internal/repo"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/assaidy/blogging_app/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	activityPubRequestTimeout   = 10 * time.Second
+	outboxPageSize              = 20
+	numActivityPubWorkers       = 2
+	activityPubPollInterval     = time.Second
+	activityDeliveryLease       = time.Minute // must be longer than activityPubRequestTimeout
+	maxActivityDeliveryAttempts = 8
+	activityRetryBaseDelay      = time.Minute
+	activityRetryMaxDelay       = 6 * time.Hour
+)
+
+var (
+	activityPubStopChan = make(chan struct{})
+	activityPubWg       sync.WaitGroup
+)
+
+var (
+	// the urls come from remote servers, so internal addresses are refused.
+	activityPubHTTPClient = utils.NewPublicHTTPClient(activityPubRequestTimeout)
+	// used instead when ACTIVITYPUB_ALLOW_PRIVATE_ADDRESSES is set, to federate with local instances.
+	activityPubLocalHTTPClient = &http.Client{Timeout: activityPubRequestTimeout}
+)
+
+func allowPrivateActivityPubAddresses() bool {
+	return os.Getenv("ACTIVITYPUB_ALLOW_PRIVATE_ADDRESSES") == "true"
+}
+
+// activityPubClient is created per use, as the env vars may be loaded after this package is initialized.
+func activityPubClient() *activitypub.Client {
+	httpClient := activityPubHTTPClient
+	if allowPrivateActivityPubAddresses() {
+		httpClient = activityPubLocalHTTPClient
+	}
+	return &activitypub.Client{
+		HTTP:      httpClient,
+		UserAgent: "blogging-app-activitypub",
+		AllowHTTP: os.Getenv("ACTIVITYPUB_ALLOW_HTTP") == "true",
+	}
+}
+
+// actors are identified by the user id, so they survive changing usernames.
+func actorURL(userID uuid.UUID) string {
+	return os.Getenv("APP_BASE_URL") + "/ap/users/" + userID.String()
+}
+
+func actorKeyID(userID uuid.UUID) string {
+	return actorURL(userID) + "#main-key"
+}
+
+func articleURL(postID uuid.UUID) string {
+	return os.Getenv("APP_BASE_URL") + "/ap/posts/" + postID.String()
+}
+
+// getActorKey returns the key of the actor of the user, generating it on first use.
+func getActorKey(userID uuid.UUID) (*postgres_repo.ActorKey, error) {
+	key, err := queries.GetActorKey(context.Background(), userID)
+	if err == nil {
+		return &key, nil
+	}
+	if !repo.IsNotFoundError(err) {
+		return nil, err
+	}
+
+	privateKeyPem, publicKeyPem, err := activitypub.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := queries.CreateActorKey(context.Background(), postgres_repo.CreateActorKeyParams{
+		UserID:        userID,
+		PublicKeyPem:  publicKeyPem,
+		PrivateKeyPem: privateKeyPem,
+	}); err != nil {
+		return nil, err
+	}
+	// read it back, in case a concurrent request created another one first
+	key, err = queries.GetActorKey(context.Background(), userID)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func sendActivityPub(c *fiber.Ctx, contentType string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error encoding response: %+v", err))
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	return c.Send(body)
+}
+
+// HandleWebFinger resolves `acct:username@host` to the actor of the user, which is how remote servers
+// find the user to follow.
+func HandleWebFinger(c *fiber.Ctx) error {
+	resource := c.Query("resource")
+	account, ok := strings.CutPrefix(resource, "acct:")
+	if !ok {
+		return fiber.NewError(fiber.StatusBadRequest, "resource must be an acct: uri")
+	}
+	username, host, ok := strings.Cut(account, "@")
+	if !ok || username == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "resource must be acct:username@host")
+	}
+	baseURL, err := url.Parse(os.Getenv("APP_BASE_URL"))
+	if err != nil || !strings.EqualFold(host, baseURL.Host) {
+		return fiber.NewError(fiber.StatusNotFound, "user not found")
+	}
+
+	user, err := queries.GetUserByUsername(context.Background(), username)
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusNotFound, "user not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting user: %+v", err))
+	}
+
+	return sendActivityPub(c, "application/jrd+json", activitypub.WebFinger{
+		Subject: "acct:" + user.Username + "@" + baseURL.Host,
+		Aliases: []string{actorURL(user.ID), userURL(user.Username)},
+		Links: []activitypub.WebFingerLink{
+			{Rel: "self", Type: activitypub.ContentType, Href: actorURL(user.ID)},
+			{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: userURL(user.Username)},
+		},
+	})
+}
+
+func HandleGetActor(c *fiber.Ctx) error {
+	user, err := getActivityPubUser(c)
+	if err != nil {
+		return err
+	}
+
+	key, err := getActorKey(user.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting actor key: %+v", err))
+	}
+
+	actor := activitypub.Actor{
+		Context:           activitypub.Context,
+		ID:                actorURL(user.ID),
+		Type:              "Person",
+		PreferredUsername: user.Username,
+		Name:              user.Name,
+		URL:               userURL(user.Username),
+		Inbox:             actorURL(user.ID) + "/inbox",
+		Outbox:            actorURL(user.ID) + "/outbox",
+		Followers:         actorURL(user.ID) + "/followers",
+		Published:         user.JoinedAt.UTC().Format(time.RFC3339),
+		PublicKey: activitypub.PublicKey{
+			ID:           actorKeyID(user.ID),
+			Owner:        actorURL(user.ID),
+			PublicKeyPem: key.PublicKeyPem,
+		},
+	}
+	if user.ProfileImageUrl.Valid && user.ProfileImageUrl.String != "" {
+		actor.Icon = &activitypub.Image{Type: "Image", URL: user.ProfileImageUrl.String}
+	}
+
+	return sendActivityPub(c, activitypub.ContentType, actor)
+}
+
+// HandleGetOutbox serves the published posts of the user as `Create` activities of `Article` objects,
+// newest first. Without ?page=true, it serves the collection, linking to its first page.
+func HandleGetOutbox(c *fiber.Ctx) error {
+	user, err := getActivityPubUser(c)
+	if err != nil {
+		return err
+	}
+	outboxURL := actorURL(user.ID) + "/outbox"
+
+	if !c.QueryBool("page") {
+		return sendActivityPub(c, activitypub.ContentType, activitypub.OrderedCollection{
+			Context:    activitypub.ActivityStreamsContext,
+			ID:         outboxURL,
+			Type:       "OrderedCollection",
+			TotalItems: int64(user.PostsCount),
+			First:      outboxURL + "?page=true",
+		})
+	}
+
+	// cursor
+	var cursor PostsCursor
+	if cursorParam := c.Query("cursor"); cursorParam != "" {
+		if cursor.ID, err = uuid.Parse(cursorParam); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid cursor format")
+		}
+	}
+
+	posts, err := queries.GetUserOutboxPosts(context.Background(), postgres_repo.GetUserOutboxPostsParams{
+		UserID: user.ID,
+		Limit:  outboxPageSize + 1,
+		ID:     cursor.ID,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting posts: %+v", err))
+	}
+
+	page := activitypub.OrderedCollectionPage{
+		Context:      activitypub.ActivityStreamsContext,
+		ID:           os.Getenv("APP_BASE_URL") + string(c.Request().URI().RequestURI()),
+		Type:         "OrderedCollectionPage",
+		PartOf:       outboxURL,
+		OrderedItems: make([]any, 0, outboxPageSize),
+	}
+	if len(posts) > outboxPageSize {
+		page.Next = outboxURL + "?page=true&cursor=" + posts[outboxPageSize].ID.String()
+		posts = posts[:outboxPageSize]
+	}
+	for _, post := range posts {
+		article := newArticle(&post, user.Username)
+		object, err := json.Marshal(article)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error encoding article: %+v", err))
+		}
+		page.OrderedItems = append(page.OrderedItems, activitypub.Activity{
+			ID:        article.ID + "/activity",
+			Type:      "Create",
+			Actor:     article.AttributedTo,
+			Object:    object,
+			Published: article.Published,
+			To:        article.To,
+			Cc:        article.Cc,
+		})
+	}
+
+	return sendActivityPub(c, activitypub.ContentType, page)
+}
+
+// HandleGetActorFollowers serves the count of local and remote followers of the user. The followers
+// themselves aren't listed.
+func HandleGetActorFollowers(c *fiber.Ctx) error {
+	user, err := getActivityPubUser(c)
+	if err != nil {
+		return err
+	}
+
+	remoteCount, err := queries.GetRemoteFollowersCount(context.Background(), user.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting remote followers count: %+v", err))
+	}
+
+	return sendActivityPub(c, activitypub.ContentType, activitypub.OrderedCollection{
+		Context:    activitypub.ActivityStreamsContext,
+		ID:         actorURL(user.ID) + "/followers",
+		Type:       "OrderedCollection",
+		TotalItems: int64(user.FollowersCount) + remoteCount,
+	})
+}
+
+func HandleGetArticle(c *fiber.Ctx) error {
+	postID, err := uuid.Parse(c.Params("post_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	post, err := queries.GetPost(context.Background(), postID)
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusNotFound, "post not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post: %+v", err))
+	}
+	if post.Status == repo.PostStatusDraft || post.Status == repo.PostStatusScheduled {
+		return fiber.NewError(fiber.StatusNotFound, "post not found")
+	}
+
+	author, err := queries.GetUserByID(context.Background(), post.UserID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting author: %+v", err))
+	}
+
+	article := newArticle(&post, author.Username)
+	article.Context = activitypub.ActivityStreamsContext
+	return sendActivityPub(c, activitypub.ContentType, article)
+}
+
+// HandleActorInbox receives activities from remote servers. Every activity must be signed (HTTP Signatures)
+// by its actor. `Follow` adds a remote follower, which is accepted right away (the `Accept` is delivered
+// by the ActivityPub workers), and `Undo` of a `Follow` removes it. Other activities are accepted and ignored.
+func HandleActorInbox(c *fiber.Ctx) error {
+	user, err := getActivityPubUser(c)
+	if err != nil {
+		return err
+	}
+
+	var activity activitypub.Activity
+	if err := json.Unmarshal(c.Body(), &activity); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid activity")
+	}
+
+	key, err := getActorKey(user.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting actor key: %+v", err))
+	}
+	privateKey, err := activitypub.ParsePrivateKey(key.PrivateKeyPem)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error parsing actor key: %+v", err))
+	}
+
+	remoteActor, err := verifyInboxRequest(c, user.ID, privateKey)
+	if err != nil {
+		return err
+	}
+	if activity.Actor != remoteActor.ID {
+		return fiber.NewError(fiber.StatusUnauthorized, "activity actor doesn't match the signature")
+	}
+
+	switch activity.Type {
+	case "Follow":
+		if activity.ObjectID() != actorURL(user.ID) {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, "follow object must be the inbox owner")
+		}
+		if err := checkFollowerInboxes(remoteActor); err != nil {
+			return err
+		}
+		if err := addRemoteFollower(user.ID, remoteActor, c.Body()); err != nil {
+			return err
+		}
+
+	case "Undo":
+		undone, err := activity.EmbeddedActivity()
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid undo object")
+		}
+		// a follow referenced by its id only is assumed to be a follow of the inbox owner
+		if (undone.Type == "Follow" || undone.Type == "") && (undone.Actor == "" || undone.Actor == remoteActor.ID) {
+			if _, err := queries.DeleteRemoteFollower(context.Background(), postgres_repo.DeleteRemoteFollowerParams{
+				UserID:  user.ID,
+				ActorID: remoteActor.ID,
+			}); err != nil {
+				return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting remote follower: %+v", err))
+			}
+		}
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// verifyInboxRequest checks the HTTP signature of an inbox request, fetching the public key from the
+// signing actor, and returns that actor. The fetch is signed by the inbox owner, for servers requiring
+// signed fetches. The date, digest and signed headers are checked before, so unsigned or replayed
+// requests don't make the server fetch anything.
+// The reason of a rejection is only logged, the response is a plain 401.
+func verifyInboxRequest(c *fiber.Ctx, userID uuid.UUID, privateKey *rsa.PrivateKey) (*activitypub.Actor, error) {
+	remoteActor, err := verifyInboxSignature(c, userID, privateKey)
+	if err != nil {
+		slog.Debug("rejected inbox request", "err", err, "userID", userID)
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid signature")
+	}
+	return remoteActor, nil
+}
+
+func verifyInboxSignature(c *fiber.Ctx, userID uuid.UUID, privateKey *rsa.PrivateKey) (*activitypub.Actor, error) {
+	signature, err := activitypub.ParseSignature(c.Get("Signature"))
+	if err != nil {
+		return nil, err
+	}
+
+	// fasthttp keeps the host, and an absolute request uri, apart from the headers
+	header := func(name string) string {
+		if strings.EqualFold(name, "host") {
+			return string(c.Request().Host())
+		}
+		return c.Get(name)
+	}
+	target := string(c.Request().URI().RequestURI())
+	if err := signature.Check(c.Method(), target, header, c.Body(), time.Now()); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), activityPubRequestTimeout)
+	defer cancel()
+	remoteActor, err := activityPubClient().FetchActor(ctx, signature.KeyID, actorKeyID(userID), privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching signer: %w", err)
+	}
+	if remoteActor.PublicKey.ID != signature.KeyID {
+		return nil, errors.New("signing key doesn't belong to the actor")
+	}
+	publicKey, err := activitypub.ParsePublicKey(remoteActor.PublicKey.PublicKeyPem)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signer key: %w", err)
+	}
+
+	if err := signature.Verify(c.Method(), target, header, c.Body(), publicKey, time.Now()); err != nil {
+		return nil, err
+	}
+	return remoteActor, nil
+}
+
+// checkFollowerInboxes checks that the inboxes of a remote follower are on public addresses, as activities
+// will be delivered to them.
+func checkFollowerInboxes(follower *activitypub.Actor) error {
+	if allowPrivateActivityPubAddresses() {
+		return nil
+	}
+	inboxes := []string{follower.Inbox}
+	if sharedInbox := sharedInboxURL(follower); sharedInbox.Valid {
+		inboxes = append(inboxes, sharedInbox.String)
+	}
+	for _, inbox := range inboxes {
+		ctx, cancel := context.WithTimeout(context.Background(), activityPubRequestTimeout)
+		err := utils.CheckPublicURL(ctx, inbox)
+		cancel()
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, "follower inbox must be a public url")
+		}
+	}
+	return nil
+}
+
+// addRemoteFollower adds the remote follower, and queues an `Accept` of the follow to its inbox.
+func addRemoteFollower(userID uuid.UUID, follower *activitypub.Actor, follow []byte) error {
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+
+	qtx := queries.WithTx(tx)
+
+	if err := qtx.CreateRemoteFollower(context.Background(), postgres_repo.CreateRemoteFollowerParams{
+		UserID:         userID,
+		ActorID:        follower.ID,
+		InboxUrl:       follower.Inbox,
+		SharedInboxUrl: sharedInboxURL(follower),
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating remote follower: %+v", err))
+	}
+
+	accept := activitypub.Activity{
+		Context: activitypub.ActivityStreamsContext,
+		ID:      actorURL(userID) + "#accepts/" + ulid.Make().String(),
+		Type:    "Accept",
+		Actor:   actorURL(userID),
+		Object:  follow,
+	}
+	if err := queueActivity(qtx, userID, follower.Inbox, accept); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error queueing follow accept: %+v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+	return nil
+}
+
+// queueActivity queues a delivery of the activity to a remote inbox, signed by the actor of the user.
+func queueActivity(q *postgres_repo.Queries, userID uuid.UUID, inbox string, activity any) error {
+	data, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	return q.CreateActivityDelivery(context.Background(), postgres_repo.CreateActivityDeliveryParams{
+		UserID:   userID,
+		InboxUrl: inbox,
+		Activity: data,
+	})
+}
+
+// queuePostActivity queues a `Create` or `Update` activity of the article of a published post, to the
+// remote followers of its author. q should be bound to the transaction publishing or updating the post.
+func queuePostActivity(q *postgres_repo.Queries, activityType string, post *postgres_repo.Post) error {
+	if post.Status != repo.PostStatusPublished {
+		return nil
+	}
+
+	author, err := q.GetUserByID(context.Background(), post.UserID)
+	if err != nil {
+		return fmt.Errorf("error getting author: %w", err)
+	}
+	article := newArticle(post, author.Username)
+	object, err := json.Marshal(article)
+	if err != nil {
+		return err
+	}
+
+	// the id of the `Create` is the one served in the outbox
+	id := article.ID + "/activity"
+	if activityType != "Create" {
+		id = article.ID + "#" + strings.ToLower(activityType) + "s/" + ulid.Make().String()
+	}
+	activity, err := json.Marshal(activitypub.Activity{
+		Context:   activitypub.ActivityStreamsContext,
+		ID:        id,
+		Type:      activityType,
+		Actor:     article.AttributedTo,
+		Object:    object,
+		Published: article.Published,
+		To:        article.To,
+		Cc:        article.Cc,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.CreateFollowersActivityDeliveries(context.Background(), postgres_repo.CreateFollowersActivityDeliveriesParams{
+		UserID:   post.UserID,
+		Activity: activity,
+	})
+}
+
+// StartActivityPubWorkers starts workers delivering the queued activities to remote inboxes.
+// NOTE: with prefork every child process runs its own workers. Deliveries are claimed with a lease
+// using `FOR UPDATE SKIP LOCKED`, so each delivery is sent by a single worker at a time.
+func StartActivityPubWorkers() {
+	for range numActivityPubWorkers {
+		activityPubWg.Add(1)
+
+		go func() {
+			defer activityPubWg.Done()
+
+			ticker := time.NewTicker(activityPubPollInterval)
+			defer ticker.Stop()
+
+			for {
+				// send due deliveries until none are left, then wait for the next poll
+				for {
+					sent, err := sendNextActivityDelivery()
+					if err != nil {
+						slog.Error("error sending activity delivery", "err", err)
+						break
+					}
+					if !sent {
+						break
+					}
+					select {
+					case <-activityPubStopChan:
+						return
+					default:
+					}
+				}
+
+				select {
+				case <-activityPubStopChan:
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+func StopActivityPubWorkers() {
+	close(activityPubStopChan)
+	activityPubWg.Wait()
+}
+
+// sendNextActivityDelivery claims the next due delivery and sends it. If sending fails, the delivery is retried
+// later with a backoff, until it's dropped after maxActivityDeliveryAttempts.
+// Returns false if there was no due delivery.
+func sendNextActivityDelivery() (bool, error) {
+	delivery, err := queries.ClaimActivityDelivery(context.Background(), time.Now().Add(activityDeliveryLease).UTC())
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error claiming activity delivery: %w", err)
+	}
+
+	key, err := getActorKey(delivery.UserID)
+	if err != nil {
+		return false, fmt.Errorf("error getting actor key: %w", err)
+	}
+	privateKey, err := activitypub.ParsePrivateKey(key.PrivateKeyPem)
+	if err != nil {
+		return false, fmt.Errorf("error parsing actor key: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), activityPubRequestTimeout)
+	defer cancel()
+	sendErr := activityPubClient().Deliver(ctx, delivery.InboxUrl, delivery.Activity, actorKeyID(delivery.UserID), privateKey)
+
+	if sendErr == nil {
+		err = queries.DeleteActivityDelivery(context.Background(), delivery.ID)
+	} else {
+		err = failActivityDelivery(&delivery, sendErr)
+	}
+	if err != nil {
+		return false, fmt.Errorf("error recording activity delivery result: %w", err)
+	}
+
+	return true, nil
+}
+
+func failActivityDelivery(delivery *postgres_repo.ClaimActivityDeliveryRow, sendErr error) error {
+	attempts := int(delivery.Attempts) + 1
+	if attempts >= maxActivityDeliveryAttempts {
+		slog.Warn("activity delivery failed", "err", sendErr, "deliveryID", delivery.ID, "inbox", delivery.InboxUrl, "attempts", attempts)
+		return queries.DeleteActivityDelivery(context.Background(), delivery.ID)
+	}
+
+	delay := utils.Backoff(attempts, activityRetryBaseDelay, activityRetryMaxDelay)
+	return queries.RetryActivityDelivery(context.Background(), postgres_repo.RetryActivityDeliveryParams{
+		// the column has no time zone, so store it as UTC like NOW() does.
+		NextAttemptAt: time.Now().Add(delay).UTC(),
+		ID:            delivery.ID,
+	})
+}
+
+// getActivityPubUser gets the user of the actor in the path.
+func getActivityPubUser(c *fiber.Ctx) (*postgres_repo.User, error) {
+	userID, err := uuid.Parse(c.Params("user_id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	user, err := queries.GetUserByID(context.Background(), userID)
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return nil, fiber.NewError(fiber.StatusNotFound, "user not found")
+		}
+		return nil, fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting user: %+v", err))
+	}
+	return &user, nil
+}
+
+// newArticle makes the `Article` object of a post, addressed to everyone and the followers of its author.
+func newArticle(post *postgres_repo.Post, authorUsername string) activitypub.Article {
+	published := post.CreatedAt
+	if post.PublishedAt.Valid {
+		published = post.PublishedAt.Time
+	}
+	return activitypub.Article{
+		ID:           articleURL(post.ID),
+		Type:         "Article",
+		AttributedTo: actorURL(post.UserID),
+		Name:         post.Title,
+		Content:      postContentHTML(post),
+		MediaType:    "text/html",
+		URL:          postURL(authorUsername, post.Slug),
+		Published:    published.UTC().Format(time.RFC3339),
+		Updated:      post.UpdatedAt.UTC().Format(time.RFC3339),
+		To:           []string{activitypub.Public},
+		Cc:           []string{actorURL(post.UserID) + "/followers"},
+	}
+}
+
+func sharedInboxURL(actor *activitypub.Actor) sql.NullString {
+	if actor.Endpoints == nil || actor.Endpoints.SharedInbox == "" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: actor.Endpoints.SharedInbox, Valid: true}
+}
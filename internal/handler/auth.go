@@ -2,15 +2,23 @@ package handler
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/assaidy/blogging_app/internal/db/postgres_db"
+	"github.com/assaidy/blogging_app/internal/middleware"
 	"github.com/assaidy/blogging_app/internal/repo"
 	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
 	"github.com/assaidy/blogging_app/internal/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
+const maxSessionUserAgentLength = 512
+
 func HandleRegister(c *fiber.Ctx) error {
 	req := UserRegisterRequest{}
 	if err := parseAndValidateJsonBody(c, &req); err != nil {
@@ -37,21 +45,9 @@ func HandleRegister(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error storing user: %+v", err))
 	}
 
-	accessToken, err := utils.GenerateJWTAccessToken(user.ID)
-	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating access token: %+v", err))
-	}
-	refreshToken, err := utils.GenerateRefreshToken()
+	accessToken, refreshToken, err := startSession(c, user.ID)
 	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating refresh token: %+v", err))
-	}
-
-	if err := queries.CreateRefreshToken(context.Background(), postgres_repo.CreateRefreshTokenParams{
-		Token:     refreshToken.Token,
-		UserID:    user.ID,
-		ExpiresAt: refreshToken.ExpiresAt,
-	}); err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error storing refresh token: %+v", err))
+		return err
 	}
 
 	var userPayload UserPayload
@@ -60,7 +56,7 @@ func HandleRegister(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(ApiResponse{
 		Payload:      userPayload,
 		AccessToken:  accessToken,
-		RefreshToken: refreshToken.Token,
+		RefreshToken: refreshToken,
 	})
 }
 
@@ -82,7 +78,122 @@ func HandleLogin(c *fiber.Ctx) error {
 		return fiber.ErrUnauthorized
 	}
 
-	accessToken, err := utils.GenerateJWTAccessToken(user.ID)
+	// with two-factor authentication, the tokens are issued by HandleVerifyMfa
+	if enabled, err := queries.CheckMfaEnabled(context.Background(), user.ID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking mfa: %+v", err))
+	} else if enabled {
+		mfaToken, err := createMfaChallenge(user.ID)
+		if err != nil {
+			return err
+		}
+		return c.Status(fiber.StatusOK).JSON(ApiResponse{
+			MfaToken: mfaToken,
+		})
+	}
+
+	accessToken, refreshToken, err := startSession(c, user.ID)
+	if err != nil {
+		return err
+	}
+
+	var userPayload UserPayload
+	fillUserPayload(&userPayload, &user)
+
+	return c.Status(fiber.StatusCreated).JSON(ApiResponse{
+		Payload:      userPayload,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	})
+}
+
+// startSession logs the user in on the device of the request: it creates a session, recording the
+// device's user agent and IP address, and issues the first refresh token of the session with an access token.
+func startSession(c *fiber.Ctx, userID uuid.UUID) (string, string, error) {
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return "", "", fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	session, err := qtx.CreateSession(context.Background(), postgres_repo.CreateSessionParams{
+		UserID:    userID,
+		UserAgent: truncateRunes(c.Get(fiber.HeaderUserAgent), maxSessionUserAgentLength),
+		IpAddress: c.IP(),
+	})
+	if err != nil {
+		return "", "", fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating session: %+v", err))
+	}
+
+	accessToken, err := utils.GenerateJWTAccessToken(userID, session.ID)
+	if err != nil {
+		return "", "", fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating access token: %+v", err))
+	}
+	refreshToken, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return "", "", fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating refresh token: %+v", err))
+	}
+
+	if err := qtx.CreateRefreshToken(context.Background(), postgres_repo.CreateRefreshTokenParams{
+		Token:     refreshToken.Token,
+		UserID:    userID,
+		ExpiresAt: refreshToken.ExpiresAt,
+		FamilyID:  session.ID,
+	}); err != nil {
+		return "", "", fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error storing refresh token: %+v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", "", fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+
+	return accessToken, refreshToken.Token, nil
+}
+
+// HandleRefreshAccessToken exchanges a refresh token for a new access token and a new refresh token
+// in the same family. The used refresh token can't be used again: presenting it again means it was
+// leaked (either the attacker or the user already used it), so the whole family is revoked, and the
+// holder of the latest token has to log in again.
+func HandleRefreshAccessToken(c *fiber.Ctx) error {
+	req := RefreshTokenRequest{}
+	if err := parseAndValidateJsonBody(c, &req); err != nil {
+		return err
+	}
+
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	usedToken, err := qtx.UseRefreshToken(context.Background(), req.RefreshToken)
+	if err != nil {
+		if !repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error using refresh token: %+v", err))
+		}
+		return revokeReusedRefreshToken(qtx, tx, req.RefreshToken)
+	}
+
+	// an expired refresh token ends its session
+	if usedToken.ExpiresAt.Before(time.Now()) {
+		if _, err := qtx.DeleteSession(context.Background(), postgres_repo.DeleteSessionParams{
+			ID:     usedToken.FamilyID,
+			UserID: usedToken.UserID,
+		}); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting session: %+v", err))
+		}
+		if err := tx.Commit(); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+		}
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "refresh token expired")
+	}
+
+	if err := qtx.TouchSession(context.Background(), usedToken.FamilyID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error updating session: %+v", err))
+	}
+
+	accessToken, err := utils.GenerateJWTAccessToken(usedToken.UserID, usedToken.FamilyID)
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating access token: %+v", err))
 	}
@@ -91,28 +202,29 @@ func HandleLogin(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating refresh token: %+v", err))
 	}
 
-	if err := queries.CreateRefreshToken(context.Background(), postgres_repo.CreateRefreshTokenParams{
+	if err := qtx.CreateRefreshToken(context.Background(), postgres_repo.CreateRefreshTokenParams{
 		Token:     refreshToken.Token,
-		UserID:    user.ID,
+		UserID:    usedToken.UserID,
 		ExpiresAt: refreshToken.ExpiresAt,
+		FamilyID:  usedToken.FamilyID,
 	}); err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error storing refresh token: %+v", err))
 	}
 
-	var userPayload UserPayload
-	fillUserPayload(&userPayload, &user)
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
 
-	return c.Status(fiber.StatusCreated).JSON(ApiResponse{
-		Payload:      userPayload,
+	return c.Status(fiber.StatusOK).JSON(ApiResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken.Token,
 	})
 }
 
-func HandleGetAccessToken(c *fiber.Ctx) error {
-	refreshTokenQuery := c.Query("refreshToken")
-
-	refreshToken, err := queries.GetRefreshToken(context.Background(), refreshTokenQuery)
+// revokeReusedRefreshToken handles a refresh token that couldn't be used: either it doesn't exist,
+// or it's already used, in which case its session is revoked.
+func revokeReusedRefreshToken(qtx *postgres_repo.Queries, tx *sql.Tx, token string) error {
+	refreshToken, err := qtx.GetRefreshToken(context.Background(), token)
 	if err != nil {
 		if repo.IsNotFoundError(err) {
 			return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
@@ -120,19 +232,147 @@ func HandleGetAccessToken(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting refresh token: %+v", err))
 	}
 
-	if refreshToken.ExpiresAt.Sub(time.Now()) < 0 {
-		if err := queries.DeleteRefreshToken(context.Background(), refreshToken.Token); err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting refresh token: %+v", err))
+	if _, err := qtx.DeleteSession(context.Background(), postgres_repo.DeleteSessionParams{
+		ID:     refreshToken.FamilyID,
+		UserID: refreshToken.UserID,
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error revoking session: %+v", err))
+	}
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+
+	middleware.InvalidateAccessTokens(refreshToken.UserID)
+
+	slog.Warn("reused refresh token, revoked its session", "userID", refreshToken.UserID, "sessionID", refreshToken.FamilyID)
+	return fiber.NewError(fiber.StatusUnauthorized, "refresh token already used, log in again")
+}
+
+// HandleLogout ends the session of the refresh token, so none of its tokens can be used anymore.
+// The access token sent with the request, if any, is revoked too.
+func HandleLogout(c *fiber.Ctx) error {
+	req := RefreshTokenRequest{}
+	if err := parseAndValidateJsonBody(c, &req); err != nil {
+		return err
+	}
+
+	refreshToken, err := queries.GetRefreshToken(context.Background(), req.RefreshToken)
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
 		}
-		return fiber.NewError(fiber.StatusUnprocessableEntity, fmt.Sprintf("refresh token expired: %+v", err))
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting refresh token: %+v", err))
+	}
+
+	if _, err := queries.DeleteSession(context.Background(), postgres_repo.DeleteSessionParams{
+		ID:     refreshToken.FamilyID,
+		UserID: refreshToken.UserID,
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting session: %+v", err))
 	}
 
-	accessToken, err := utils.GenerateJWTAccessToken(refreshToken.UserID)
+	if err := revokeRequestAccessToken(c, refreshToken.UserID); err != nil {
+		return err
+	}
+	middleware.InvalidateAccessTokens(refreshToken.UserID)
+
+	return c.Status(fiber.StatusOK).SendString("logged out successfully")
+}
+
+// revokeRequestAccessToken adds the unexpired access token of the Authorization header, if it's
+// the user's, to the revoked tokens.
+func revokeRequestAccessToken(c *fiber.Ctx, userID uuid.UUID) error {
+	claims, err := utils.ParseJWTTokenString(strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "))
+	if err != nil || claims.UserID != userID || claims.ID == "" || claims.ExpiresAt.Before(time.Now()) {
+		return nil
+	}
+
+	if err := queries.RevokeAccessToken(context.Background(), postgres_repo.RevokeAccessTokenParams{
+		Jti:       claims.ID,
+		UserID:    userID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error revoking access token: %+v", err))
+	}
+	if err := queries.DeleteExpiredRevokedAccessTokens(context.Background()); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting expired revoked access tokens: %+v", err))
+	}
+
+	return nil
+}
+
+// HandleLogoutAll ends all the sessions of the user, including the current one,
+// and revokes all the access tokens issued to the user so far.
+func HandleLogoutAll(c *fiber.Ctx) error {
+	userID := getUserIDFromContext(c)
+
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
 	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating access token: %+v", err))
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	if err := qtx.DeleteAllUserSessions(context.Background(), userID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting sessions: %+v", err))
+	}
+	if err := qtx.UpdateUserTokensValidAfter(context.Background(), userID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error revoking access tokens: %+v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+	middleware.InvalidateAccessTokens(userID)
+
+	return c.Status(fiber.StatusOK).SendString("logged out of all sessions successfully")
+}
+
+func HandleGetAllSessions(c *fiber.Ctx) error {
+	sessions, err := queries.GetAllUserSessions(context.Background(), getUserIDFromContext(c))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting sessions: %+v", err))
+	}
+
+	currentSessionID := getSessionIDFromContext(c)
+	payload := make([]SessionPayload, len(sessions))
+	for i, session := range sessions {
+		fillSessionPayload(&payload[i], &session, currentSessionID)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(ApiResponse{
-		AccessToken: accessToken,
+		Payload: payload,
+	})
+}
+
+// HandleRevokeSession ends a session of the user, e.g. on a lost device.
+func HandleRevokeSession(c *fiber.Ctx) error {
+	sessionID, err := uuid.Parse(c.Params("session_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	userID := getUserIDFromContext(c)
+
+	deleted, err := queries.DeleteSession(context.Background(), postgres_repo.DeleteSessionParams{
+		ID:     sessionID,
+		UserID: userID,
 	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting session: %+v", err))
+	}
+	if deleted == 0 {
+		return fiber.NewError(fiber.StatusNotFound, "session not found")
+	}
+	middleware.InvalidateAccessTokens(userID)
+
+	return c.Status(fiber.StatusOK).SendString("session revoked successfully")
+}
+
+// truncateRunes cuts s to at most n runes.
+func truncateRunes(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/assaidy/blogging_app/internal/db/postgres_db"
 	"github.com/assaidy/blogging_app/internal/middleware"
+	"github.com/assaidy/blogging_app/internal/repo"
 	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
 	"github.com/assaidy/blogging_app/internal/utils"
 	"github.com/gofiber/fiber/v2"
@@ -20,6 +21,7 @@ type ApiResponse struct {
 	Payload      any    `json:"payload,omitempty"`
 	AccessToken  string `json:"accessToken,omitempty"`
 	RefreshToken string `json:"refreshToken,omitempty"`
+	MfaToken     string `json:"mfaToken,omitempty"` // returned by the login instead of the tokens when MFA is enabled
 }
 
 type CursoredApiResponse struct {
@@ -49,6 +51,22 @@ type PostsCursor struct {
 	ID         uuid.UUID `json:"id" validate:"uuid"`
 }
 
+type FeedCursor struct {
+	ID uuid.UUID `json:"id" validate:"uuid"`
+}
+
+type DraftsCursor struct {
+	ID uuid.UUID `json:"id" validate:"uuid"`
+}
+
+type RevisionsCursor struct {
+	ID uuid.UUID `json:"id" validate:"uuid"`
+}
+
+type TagPostsCursor struct {
+	ID uuid.UUID `json:"id" validate:"uuid"`
+}
+
 type CommentsCursor struct {
 	ID uuid.UUID `json:"id" validate:"uuid"`
 }
@@ -61,6 +79,22 @@ type NotificationsCursor struct {
 	ID uuid.UUID `json:"id" validate:"uuid"`
 }
 
+type TopicsCursor struct {
+	ID uuid.UUID `json:"id" validate:"uuid"`
+}
+
+type WebhooksCursor struct {
+	ID uuid.UUID `json:"id" validate:"uuid"`
+}
+
+type WebhookDeliveriesCursor struct {
+	ID uuid.UUID `json:"id" validate:"uuid"`
+}
+
+type NotificationMutesCursor struct {
+	ID uuid.UUID `json:"id" validate:"uuid"`
+}
+
 // marshalJsonAndEncodeBase64 marshals the provided source struct into JSON bytes and then encodes those bytes
 // into a base64-encoded string. Returns the base64-encoded string or an error if the marshaling fails.
 func marshalJsonAndEncodeBase64(src any) (string, error) {
@@ -113,6 +147,10 @@ type UserLoginRequest struct {
 	Password string `json:"password" validate:"required,customNoOuterSpaces,min=8,max=50"`
 }
 
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required,max=255"`
+}
+
 type UserUpdateRequest struct {
 	Name            string `json:"name" validate:"required,customNoOuterSpaces"`
 	Username        string `json:"username" validate:"required,customUsername"`
@@ -121,22 +159,44 @@ type UserUpdateRequest struct {
 	ProfileImageUrl string `json:"profileImageUrl" validate:"customNoOuterSpaces"`
 }
 
+type EmailUpdateRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type DigestFrequencyUpdateRequest struct {
+	Frequency string `json:"frequency" validate:"required,oneof=never daily weekly"`
+}
+
 type PostCreateOrUpdateRequest struct {
-	Title            string `json:"title" validate:"required,customNoOuterSpaces"`
-	Content          string `json:"content" validate:"required,customNoOuterSpaces"`
-	FeaturedImageUrl string `json:"featuredImageUrl" validate:"customNoOuterSpaces"`
+	Title            string     `json:"title" validate:"required,customNoOuterSpaces"`
+	Content          string     `json:"content" validate:"required,customNoOuterSpaces"`
+	FeaturedImageUrl string     `json:"featuredImageUrl" validate:"customNoOuterSpaces"`
+	PublishAt        *time.Time `json:"publishAt"` // optional, only used on creation to schedule the post
+	Tags             []string   `json:"tags" validate:"max=10,dive,required,customNoOuterSpaces,max=50"`
 }
 
 type PostPayload struct {
 	ID               uuid.UUID             `json:"id"`
 	UserID           uuid.UUID             `json:"userID"`
 	Title            string                `json:"title"`
-	Content          string                `json:"content"`
+	Slug             string                `json:"slug"`
+	Content          string                `json:"content"`     // markdown source
+	ContentHtml      string                `json:"contentHtml"` // sanitized html rendering of the content
+	Toc              json.RawMessage       `json:"toc"`         // headings of the content: [{level, id, text}]
 	CreatedAt        time.Time             `json:"createdAt"`
 	ViewsCount       int32                 `json:"viewsCount"`
 	Reactions        []PostPayloadReaction `json:"reactions"`
 	CommentsCount    int32                 `json:"commentsCount"`
 	FeaturedImageUrl string                `json:"featuredImageUrl,omitempty"`
+	Status           string                `json:"status"`
+	PublishedAt      *time.Time            `json:"publishedAt,omitempty"`
+	PublishAt        *time.Time            `json:"publishAt,omitempty"`
+	Tags             []PostPayloadTag      `json:"tags"`
+}
+
+type PostPayloadTag struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
 }
 
 type PostPayloadReaction struct {
@@ -144,25 +204,182 @@ type PostPayloadReaction struct {
 	Count int64  `json:"count"`
 }
 
+type RevisionPayload struct {
+	ID               uuid.UUID `json:"id"`
+	PostID           uuid.UUID `json:"postID"`
+	Title            string    `json:"title"`
+	Content          string    `json:"content"`
+	FeaturedImageUrl string    `json:"featuredImageUrl,omitempty"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+type RevisionDiffPayload struct {
+	FromID           uuid.UUID         `json:"fromID"`
+	ToID             uuid.UUID         `json:"toID"`
+	Title            []DiffLinePayload `json:"title"`
+	Content          []DiffLinePayload `json:"content"`
+	FeaturedImageUrl []DiffLinePayload `json:"featuredImageUrl"`
+}
+
+type DiffLinePayload struct {
+	Op   string `json:"op"` // equal, insert or delete
+	Text string `json:"text"`
+}
+
+type TagPayload struct {
+	Slug       string    `json:"slug"`
+	Name       string    `json:"name"`
+	PostsCount int32     `json:"postsCount"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
 type CommentCreateOrUpdateRequest struct {
 	Content string `json:"content" validate:"required,customNoOuterSpaces"`
 }
 
 type CommentPayload struct {
+	ID           uuid.UUID  `json:"id"`
+	PostID       uuid.UUID  `json:"postID"`
+	UserID       uuid.UUID  `json:"userID"`
+	ParentID     *uuid.UUID `json:"parentID,omitempty"` // only for replies
+	Content      string     `json:"content"`
+	ContentHtml  string     `json:"contentHtml"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	RepliesCount int32      `json:"repliesCount"`
+}
+
+// grouped notifications have the latest actor as the sender, and the latest actors (up to 10) in ActorIDs.
+type NotificationPayload struct {
+	ID          uuid.UUID   `json:"id"`
+	Kind        string      `json:"kind"`
+	UserID      uuid.UUID   `json:"userID"`
+	SenderID    uuid.UUID   `json:"senderID,omitempty"`
+	PostID      uuid.UUID   `json:"postID,omitempty"`
+	CommentID   uuid.UUID   `json:"commentID,omitempty"`
+	ActorIDs    []uuid.UUID `json:"actorIDs"`
+	ActorsCount int32       `json:"actorsCount"`
+	IsRead      bool        `json:"isRead"`
+	CreatedAt   time.Time   `json:"createdAt"`
+}
+
+type NotificationsMarkAsReadRequest struct {
+	IDs []uuid.UUID `json:"ids" validate:"required,min=1,max=100"`
+}
+
+type NotificationPreferenceUpdateRequest struct {
+	InApp  *bool `json:"inApp" validate:"required"`
+	Digest *bool `json:"digest" validate:"required"`
+}
+
+type NotificationPreferencePayload struct {
+	Kind   string `json:"kind"`
+	InApp  bool   `json:"inApp"`
+	Digest bool   `json:"digest"`
+}
+
+// exactly one of UserID and PostID must be set.
+type NotificationMuteRequest struct {
+	UserID *uuid.UUID `json:"userID"`
+	PostID *uuid.UUID `json:"postID"`
+}
+
+type NotificationMutePayload struct {
+	ID          uuid.UUID `json:"id"`
+	MutedUserID uuid.UUID `json:"mutedUserID,omitempty"`
+	MutedPostID uuid.UUID `json:"mutedPostID,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type SessionPayload struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	Current    bool      `json:"current"` // whether it's the session of the access token of the request
+}
+
+type MfaCodeRequest struct {
+	Code string `json:"code" validate:"required,max=20"` // a TOTP code, or a recovery code where allowed
+}
+
+type MfaVerifyRequest struct {
+	MfaToken string `json:"mfaToken" validate:"required,max=255"`
+	Code     string `json:"code" validate:"required,max=20"`
+}
+
+type MfaDisableRequest struct {
+	Password string `json:"password" validate:"required,customNoOuterSpaces,max=50"`
+	Code     string `json:"code" validate:"required,max=20"`
+}
+
+type TotpEnrollmentPayload struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"` // the otpauth:// URI to show as a QR code
+}
+
+type RecoveryCodesPayload struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type WebhookCreateRequest struct {
+	URL    string   `json:"url" validate:"required,http_url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,max=4,unique,dive,oneof=post.published post.updated comment.created user.followed"`
+}
+
+type WebhookPayload struct {
 	ID        uuid.UUID `json:"id"`
-	PostID    uuid.UUID `json:"postID"`
-	UserID    uuid.UUID `json:"userID"`
-	Content   string    `json:"content"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"` // only returned on creation
 	CreatedAt time.Time `json:"createdAt"`
 }
 
-type NotificationPayload struct {
+type WebhookDeliveryPayload struct {
+	ID             uuid.UUID       `json:"id"`
+	WebhookID      uuid.UUID       `json:"webhookID"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"` // pending, succeeded or failed
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt,omitempty"` // only for pending deliveries
+	LastStatusCode int32           `json:"lastStatusCode,omitempty"`
+	LastError      string          `json:"lastError,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+}
+
+// the body sent to webhooks.
+type WebhookEventPayload struct {
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+}
+
+type WebhookPostData struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"userID"`
+	Title       string     `json:"title"`
+	Slug        string     `json:"slug"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	PublishedAt *time.Time `json:"publishedAt,omitempty"`
+}
+
+type WebhookFollowData struct {
+	FollowerID uuid.UUID `json:"followerID"`
+	FollowedID uuid.UUID `json:"followedID"`
+}
+
+type TopicSubscribeRequest struct {
+	Query     string `json:"query" validate:"required,customNoOuterSpaces,max=255"`
+	IsTsQuery bool   `json:"isTsQuery"`
+}
+
+type TopicPayload struct {
 	ID        uuid.UUID `json:"id"`
-	Kind      string    `json:"kind"`
-	UserID    uuid.UUID `json:"userID"`
-	SenderID  uuid.UUID `json:"senderID,omitempty"`
-	PostID    uuid.UUID `json:"postID,omitempty"`
-	IsRead    bool      `json:"isRead"`
+	Query     string    `json:"query"`
+	IsTsQuery bool      `json:"isTsQuery"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
@@ -181,11 +398,21 @@ func fillPostPayload(postPayload *PostPayload, repoPost *postgres_repo.Post) {
 	postPayload.ID = repoPost.ID
 	postPayload.UserID = repoPost.UserID
 	postPayload.Title = repoPost.Title
+	postPayload.Slug = repoPost.Slug
 	postPayload.Content = repoPost.Content
+	postPayload.ContentHtml = repoPost.ContentHtml.String
+	postPayload.Toc = repoPost.Toc
 	postPayload.CreatedAt = repoPost.CreatedAt
 	postPayload.ViewsCount = repoPost.ViewsCount
 	postPayload.CommentsCount = repoPost.CommentsCount
 	postPayload.FeaturedImageUrl = repoPost.FeaturedImageUrl.String
+	postPayload.Status = repoPost.Status
+	if repoPost.PublishedAt.Valid {
+		postPayload.PublishedAt = &repoPost.PublishedAt.Time
+	}
+	if repoPost.PublishAt.Valid {
+		postPayload.PublishAt = &repoPost.PublishAt.Time
+	}
 }
 
 func fillPostReactions(postPayload *PostPayload, repoReactions []postgres_repo.GetPostReactionsRow) {
@@ -201,12 +428,53 @@ func fillPostReactions(postPayload *PostPayload, repoReactions []postgres_repo.G
 	}
 }
 
+func fillPostTags(postPayload *PostPayload, repoTags []postgres_repo.Tag) {
+	postPayload.Tags = make([]PostPayloadTag, 0, len(repoTags))
+	for _, tag := range repoTags {
+		postPayload.Tags = append(postPayload.Tags, PostPayloadTag{
+			Slug: tag.Slug,
+			Name: tag.Name,
+		})
+	}
+}
+
+func fillTagPayload(tagPayload *TagPayload, repoTag *postgres_repo.Tag) {
+	tagPayload.Slug = repoTag.Slug
+	tagPayload.Name = repoTag.Name
+	tagPayload.PostsCount = repoTag.PostsCount
+	tagPayload.CreatedAt = repoTag.CreatedAt
+}
+
+func fillRevisionPayload(revisionPayload *RevisionPayload, repoRevision *postgres_repo.PostRevision) {
+	revisionPayload.ID = repoRevision.ID
+	revisionPayload.PostID = repoRevision.PostID
+	revisionPayload.Title = repoRevision.Title
+	revisionPayload.Content = repoRevision.Content
+	revisionPayload.FeaturedImageUrl = repoRevision.FeaturedImageUrl.String
+	revisionPayload.CreatedAt = repoRevision.CreatedAt
+}
+
+func fillDiffLinesPayload(diffLinesPayload *[]DiffLinePayload, diff []utils.DiffLine) {
+	*diffLinesPayload = make([]DiffLinePayload, 0, len(diff))
+	for _, line := range diff {
+		*diffLinesPayload = append(*diffLinesPayload, DiffLinePayload{
+			Op:   string(line.Op),
+			Text: line.Text,
+		})
+	}
+}
+
 func fillCommentPayload(commentPayload *CommentPayload, repoComment *postgres_repo.PostComment) {
 	commentPayload.ID = repoComment.ID
 	commentPayload.PostID = repoComment.PostID
 	commentPayload.UserID = repoComment.UserID
+	if repoComment.ParentID.Valid {
+		commentPayload.ParentID = &repoComment.ParentID.UUID
+	}
 	commentPayload.Content = repoComment.Content
+	commentPayload.ContentHtml = repoComment.ContentHtml.String
 	commentPayload.CreatedAt = repoComment.CreatedAt
+	commentPayload.RepliesCount = repoComment.RepliesCount
 }
 
 func fillNotificationPayload(notificationPayload *NotificationPayload, repoNotification *postgres_repo.GetAllNotificationsRow) {
@@ -215,16 +483,92 @@ func fillNotificationPayload(notificationPayload *NotificationPayload, repoNotif
 	notificationPayload.UserID = repoNotification.UserID
 	notificationPayload.SenderID = repoNotification.SenderID.UUID
 	notificationPayload.PostID = repoNotification.PostID.UUID
+	notificationPayload.CommentID = repoNotification.CommentID.UUID
+	notificationPayload.ActorIDs = repoNotification.ActorIds
+	notificationPayload.ActorsCount = repoNotification.ActorsCount
 	notificationPayload.IsRead = repoNotification.IsRead
 	notificationPayload.CreatedAt = repoNotification.CreatedAt
 }
 
+func fillStreamedNotificationPayload(notificationPayload *NotificationPayload, repoNotification *postgres_repo.GetAllNotificationsAfterRow) {
+	notificationPayload.ID = repoNotification.ID
+	notificationPayload.Kind = repoNotification.Kind
+	notificationPayload.UserID = repoNotification.UserID
+	notificationPayload.SenderID = repoNotification.SenderID.UUID
+	notificationPayload.PostID = repoNotification.PostID.UUID
+	notificationPayload.CommentID = repoNotification.CommentID.UUID
+	notificationPayload.ActorIDs = repoNotification.ActorIds
+	notificationPayload.ActorsCount = repoNotification.ActorsCount
+	notificationPayload.IsRead = repoNotification.IsRead
+	notificationPayload.CreatedAt = repoNotification.CreatedAt
+}
+
+func fillNotificationPreferencePayload(preferencePayload *NotificationPreferencePayload, repoPreference *postgres_repo.GetNotificationPreferencesRow) {
+	preferencePayload.Kind = repoPreference.Kind
+	preferencePayload.InApp = repoPreference.InApp
+	preferencePayload.Digest = repoPreference.Digest
+}
+
+func fillNotificationMutePayload(mutePayload *NotificationMutePayload, repoMute *postgres_repo.NotificationMute) {
+	mutePayload.ID = repoMute.ID
+	mutePayload.MutedUserID = repoMute.MutedUserID.UUID
+	mutePayload.MutedPostID = repoMute.MutedPostID.UUID
+	mutePayload.CreatedAt = repoMute.CreatedAt
+}
+
+func fillWebhookPayload(webhookPayload *WebhookPayload, repoWebhook *postgres_repo.Webhook) {
+	webhookPayload.ID = repoWebhook.ID
+	webhookPayload.URL = repoWebhook.Url
+	webhookPayload.Events = repoWebhook.Events
+	webhookPayload.CreatedAt = repoWebhook.CreatedAt
+}
+
+func fillWebhookDeliveryPayload(deliveryPayload *WebhookDeliveryPayload, repoDelivery *postgres_repo.WebhookDelivery) {
+	deliveryPayload.ID = repoDelivery.ID
+	deliveryPayload.WebhookID = repoDelivery.WebhookID
+	deliveryPayload.Event = repoDelivery.Event
+	deliveryPayload.Payload = repoDelivery.Payload
+	deliveryPayload.Status = repoDelivery.Status
+	deliveryPayload.Attempts = repoDelivery.Attempts
+	if repoDelivery.Status == repo.WebhookDeliveryStatusPending {
+		deliveryPayload.NextAttemptAt = &repoDelivery.NextAttemptAt
+	}
+	deliveryPayload.LastStatusCode = repoDelivery.LastStatusCode.Int32
+	deliveryPayload.LastError = repoDelivery.LastError.String
+	deliveryPayload.CreatedAt = repoDelivery.CreatedAt
+	if repoDelivery.DeliveredAt.Valid {
+		deliveryPayload.DeliveredAt = &repoDelivery.DeliveredAt.Time
+	}
+}
+
+func fillTopicPayload(topicPayload *TopicPayload, repoTopic *postgres_repo.TopicSubscription) {
+	topicPayload.ID = repoTopic.ID
+	topicPayload.Query = repoTopic.Query
+	topicPayload.IsTsQuery = repoTopic.IsTsquery
+	topicPayload.CreatedAt = repoTopic.CreatedAt
+}
+
+func fillSessionPayload(sessionPayload *SessionPayload, repoSession *postgres_repo.Session, currentSessionID uuid.UUID) {
+	sessionPayload.ID = repoSession.ID
+	sessionPayload.UserAgent = repoSession.UserAgent
+	sessionPayload.IPAddress = repoSession.IpAddress
+	sessionPayload.CreatedAt = repoSession.CreatedAt
+	sessionPayload.LastUsedAt = repoSession.LastUsedAt
+	sessionPayload.Current = repoSession.ID == currentSessionID
+}
+
 // getUserIDFromContext retrieves the user ID from the context, which is set by the authentication middleware.
 // The user ID is stored in the context under the key "userID" and is expected to be a string.
 func getUserIDFromContext(c *fiber.Ctx) uuid.UUID {
 	return c.Locals(middleware.AuthUserID).(uuid.UUID)
 }
 
+// getSessionIDFromContext retrieves the ID of the session of the access token, which is set by the authentication middleware.
+// It's uuid.Nil for access tokens issued before sessions were introduced.
+func getSessionIDFromContext(c *fiber.Ctx) uuid.UUID {
+	return c.Locals(middleware.AuthSessionID).(uuid.UUID)
+}
+
 // parseAndValidateJsonBody parses the JSON request body into `out` and validates it.
 // Returns an error if parsing or validation fails.
 func parseAndValidateJsonBody(c *fiber.Ctx, out any) error {
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/db/postgres_db"
+	"github.com/assaidy/blogging_app/internal/mailer"
+	"github.com/assaidy/blogging_app/internal/repo"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+)
+
+var (
+	digestStopChan = make(chan struct{})
+	digestWg       sync.WaitGroup
+)
+
+const (
+	digestInterval         = 5 * time.Minute
+	digestSendTimeout      = 30 * time.Second
+	dailyDigestPeriod      = 24 * time.Hour
+	weeklyDigestPeriod     = 7 * 24 * time.Hour
+	maxDigestNotifications = 50
+	maxDigestPosts         = 5
+	maxDigestTitleLength   = 100
+)
+
+// StartDigestSender starts a background loop that emails each user with a verified email a daily or weekly
+// digest of their unread notifications (of the kinds they included in the digest) and the top posts of the
+// authors they follow.
+// NOTE: with prefork every child process runs its own loop. Due users are claimed using
+// `FOR UPDATE SKIP LOCKED`, so each digest is sent once.
+func StartDigestSender() {
+	digestWg.Add(1)
+
+	go func() {
+		defer digestWg.Done()
+
+		ticker := time.NewTicker(digestInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-digestStopChan:
+				return
+			case <-ticker.C:
+				if err := sendDueDigests(); err != nil {
+					slog.Error("error sending digests", "err", err)
+				}
+			}
+		}
+	}()
+}
+
+func StopDigestSender() {
+	close(digestStopChan)
+	digestWg.Wait()
+}
+
+// sendDueDigests sends due digests one by one until none are left.
+func sendDueDigests() error {
+	for {
+		sent, err := sendNextDigest()
+		if err != nil {
+			return err
+		}
+		if !sent {
+			return nil
+		}
+		select {
+		case <-digestStopChan:
+			return nil
+		default:
+		}
+	}
+}
+
+type digestData struct {
+	Username      string
+	Frequency     string
+	Notifications []digestNotification
+	Posts         []digestPost
+}
+
+type digestNotification struct {
+	Text string
+}
+
+type digestPost struct {
+	Title          string
+	AuthorUsername string
+	URL            string
+}
+
+// sendNextDigest claims the next user with a due digest, and emails it. Users with nothing to include
+// don't get an email. Returns false if there was no due digest.
+// The digest is marked as sent before emailing it, so the transaction isn't held open during the
+// SMTP exchange, and a digest is never sent twice.
+func sendNextDigest() (bool, error) {
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := queries.WithTx(tx)
+
+	user, err := qtx.ClaimDueDigestUser(context.Background())
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error claiming digest user: %w", err)
+	}
+
+	period := dailyDigestPeriod
+	if user.DigestFrequency == repo.DigestFrequencyWeekly {
+		period = weeklyDigestPeriod
+	}
+	since := time.Now().Add(-period).UTC()
+	if user.LastDigestSentAt.Valid {
+		since = user.LastDigestSentAt.Time
+	}
+
+	notifications, err := qtx.GetDigestNotifications(context.Background(), postgres_repo.GetDigestNotificationsParams{
+		UserID: user.ID,
+		Limit:  maxDigestNotifications,
+		Since:  since,
+	})
+	if err != nil {
+		return false, fmt.Errorf("error getting digest notifications: %w", err)
+	}
+
+	posts, err := qtx.GetDigestTopPosts(context.Background(), postgres_repo.GetDigestTopPostsParams{
+		FollowerID: user.ID,
+		Limit:      maxDigestPosts,
+		Since:      since,
+	})
+	if err != nil {
+		return false, fmt.Errorf("error getting digest posts: %w", err)
+	}
+
+	var html string
+	if len(notifications) > 0 || len(posts) > 0 {
+		data := digestData{
+			Username:      user.Username,
+			Frequency:     user.DigestFrequency,
+			Notifications: make([]digestNotification, 0, len(notifications)),
+			Posts:         make([]digestPost, 0, len(posts)),
+		}
+		for _, notification := range notifications {
+			data.Notifications = append(data.Notifications, digestNotification{Text: describeDigestNotification(&notification)})
+		}
+		for _, post := range posts {
+			data.Posts = append(data.Posts, digestPost{
+				Title:          post.Title,
+				AuthorUsername: post.AuthorUsername,
+				URL:            postURL(post.AuthorUsername, post.Slug),
+			})
+		}
+
+		html, err = mailer.Render("digest.html", data)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if len(notifications) > 0 {
+		// the notifications are sorted newest first
+		if err := qtx.MarkDigestOnlyNotificationsAsRead(context.Background(), postgres_repo.MarkDigestOnlyNotificationsAsReadParams{
+			UserID:  user.ID,
+			UntilID: notifications[0].ID,
+		}); err != nil {
+			return false, fmt.Errorf("error marking digest notifications as read: %w", err)
+		}
+	}
+
+	if err := qtx.MarkDigestSent(context.Background(), user.ID); err != nil {
+		return false, fmt.Errorf("error marking digest as sent: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	if html == "" {
+		return true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), digestSendTimeout)
+	defer cancel()
+
+	// a failed digest is skipped rather than retried, so an unreachable address doesn't block the other users.
+	if err := mailer.Default.Send(ctx, mailer.Message{
+		To:      user.Email.String,
+		Subject: fmt.Sprintf("Your %s digest", user.DigestFrequency),
+		HTML:    html,
+	}); err != nil {
+		slog.Error("error sending digest", "err", err, "userID", user.ID)
+	}
+
+	return true, nil
+}
+
+// describeDigestNotification describes the notification in a sentence, like `alice and 3 others reacted to "my post"`.
+func describeDigestNotification(notification *postgres_repo.GetDigestNotificationsRow) string {
+	actors := notification.SenderUsername.String
+	switch others := notification.ActorsCount - 1; {
+	case others == 1:
+		actors += " and 1 other"
+	case others > 1:
+		actors += fmt.Sprintf(" and %d others", others)
+	}
+
+	title := []rune(notification.PostTitle.String)
+	if len(title) > maxDigestTitleLength {
+		title = append(title[:maxDigestTitleLength], '…')
+	}
+	post := `"` + string(title) + `"`
+
+	switch notification.Kind {
+	case "new_follower":
+		return actors + " followed you"
+	case "new_post":
+		return actors + " published " + post
+	case "topic_match":
+		return post + " by " + actors + " matches one of your topics"
+	case "new_comment":
+		return actors + " commented on " + post
+	case "new_reply":
+		return actors + " replied to your comment on " + post
+	case "new_reaction":
+		return actors + " reacted to " + post
+	case "mention":
+		return actors + " mentioned you in " + post
+	default:
+		return "new " + notification.Kind + " notification"
+	}
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/db/postgres_db"
+	"github.com/assaidy/blogging_app/internal/mailer"
+	"github.com/assaidy/blogging_app/internal/repo"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/assaidy/blogging_app/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// HandleUpdateEmail sets a new unverified email for the user, and sends a verification link to it.
+// The email is only used (e.g. for digests) once verified.
+func HandleUpdateEmail(c *fiber.Ctx) error {
+	req := EmailUpdateRequest{}
+	if err := parseAndValidateJsonBody(c, &req); err != nil {
+		return err
+	}
+	email := sql.NullString{Valid: true, String: strings.ToLower(req.Email)}
+
+	userID := getUserIDFromContext(c)
+
+	user, err := queries.GetUserByID(context.Background(), userID)
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusNotFound, "user not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting user: %+v", err))
+	}
+
+	if exists, err := queries.CheckVerifiedEmail(context.Background(), postgres_repo.CheckVerifiedEmailParams{
+		Email: email,
+		ID:    userID,
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking email: %+v", err))
+	} else if exists {
+		return fiber.NewError(fiber.StatusConflict, "email already exists")
+	}
+
+	token, err := utils.GenerateEmailVerificationToken()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating verification token: %+v", err))
+	}
+
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+
+	qtx := queries.WithTx(tx)
+
+	if err := qtx.UpdateUserEmail(context.Background(), postgres_repo.UpdateUserEmailParams{
+		Email: email,
+		ID:    userID,
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error updating email: %+v", err))
+	}
+
+	// tokens sent to a previous email are no longer valid
+	if err := qtx.DeleteUserEmailVerificationTokens(context.Background(), userID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting verification tokens: %+v", err))
+	}
+
+	if err := qtx.CreateEmailVerificationToken(context.Background(), postgres_repo.CreateEmailVerificationTokenParams{
+		Token:     token.Token,
+		UserID:    userID,
+		Email:     email.String,
+		ExpiresAt: token.ExpiresAt,
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error storing verification token: %+v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+
+	// the email is sent after committing, so the link never points to a token that doesn't exist.
+	// If sending fails, the user can set the email again to get a new link.
+	html, err := mailer.Render("verify_email.html", verifyEmailData{
+		Username:  user.Username,
+		URL:       os.Getenv("APP_BASE_URL") + "/api/v1/users/email/verify?token=" + url.QueryEscape(token.Token),
+		ExpiresIn: "24 hours",
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error rendering verification email: %+v", err))
+	}
+	if err := mailer.Default.Send(context.Background(), mailer.Message{
+		To:      email.String,
+		Subject: "Verify your email",
+		HTML:    html,
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error sending verification email: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).SendString("verification email sent successfully")
+}
+
+type verifyEmailData struct {
+	Username  string
+	URL       string
+	ExpiresIn string
+}
+
+// HandleVerifyEmail verifies the email of the user using the token sent to it.
+// It's opened from the email, so it's a GET request authenticated by the token only.
+func HandleVerifyEmail(c *fiber.Ctx) error {
+	token, err := queries.GetEmailVerificationToken(context.Background(), c.Query("token"))
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting verification token: %+v", err))
+	}
+
+	if token.ExpiresAt.Before(time.Now()) {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "verification token expired")
+	}
+
+	email := sql.NullString{Valid: true, String: token.Email}
+
+	if exists, err := queries.CheckVerifiedEmail(context.Background(), postgres_repo.CheckVerifiedEmailParams{
+		Email: email,
+		ID:    token.UserID,
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking email: %+v", err))
+	} else if exists {
+		return fiber.NewError(fiber.StatusConflict, "email already exists")
+	}
+
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+
+	qtx := queries.WithTx(tx)
+
+	verified, err := qtx.VerifyUserEmail(context.Background(), postgres_repo.VerifyUserEmailParams{
+		ID:    token.UserID,
+		Email: email,
+	})
+	if err != nil {
+		if repo.IsUniqueViolationError(err) {
+			return fiber.NewError(fiber.StatusConflict, "email already exists")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error verifying email: %+v", err))
+	}
+	if verified == 0 {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "email changed since the verification email was sent")
+	}
+
+	if err := qtx.DeleteUserEmailVerificationTokens(context.Background(), token.UserID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting verification tokens: %+v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).SendString("email verified successfully")
+}
+
+// HandleUpdateDigestFrequency sets how often the user gets the email digest. Digests are only sent
+// once the email is verified.
+func HandleUpdateDigestFrequency(c *fiber.Ctx) error {
+	req := DigestFrequencyUpdateRequest{}
+	if err := parseAndValidateJsonBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := queries.UpdateUserDigestFrequency(context.Background(), postgres_repo.UpdateUserDigestFrequencyParams{
+		DigestFrequency: req.Frequency,
+		ID:              getUserIDFromContext(c),
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error updating digest frequency: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).SendString("digest frequency updated successfully")
+}
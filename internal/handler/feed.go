@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/feeds"
+	"github.com/assaidy/blogging_app/internal/repo"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	feedPostsLimit   = 20
+	feedCacheControl = "public, max-age=300"
+)
+
+// HandleGetUserFeed serves the latest published posts of a user as a feed.
+// The format is the extension of the path: rss, atom or json.
+func HandleGetUserFeed(c *fiber.Ctx) error {
+	format := feeds.Format(c.Params("format"))
+	if !format.IsValid() {
+		return fiber.NewError(fiber.StatusNotFound, "feed not found")
+	}
+
+	user, err := queries.GetUserByUsername(context.Background(), c.Params("username"))
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusNotFound, "user not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting user: %+v", err))
+	}
+
+	state, err := queries.GetUserFeedState(context.Background(), user.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting feed state: %+v", err))
+	}
+	if setFeedCacheHeaders(c, format, state.PostsCount, state.LastUpdatedAt) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	posts, err := queries.GetUserFeedPosts(context.Background(), postgres_repo.GetUserFeedPostsParams{
+		UserID: user.ID,
+		Limit:  feedPostsLimit,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting posts: %+v", err))
+	}
+
+	feed := feeds.Feed{
+		Title:       user.Name + " (@" + user.Username + ")",
+		Link:        userURL(user.Username),
+		FeedLink:    os.Getenv("APP_BASE_URL") + c.Path(),
+		Description: "Latest posts by " + user.Name,
+		Updated:     state.LastUpdatedAt,
+		Items:       make([]feeds.Item, 0, len(posts)),
+	}
+	for _, post := range posts {
+		feed.Items = append(feed.Items, newFeedItem(&post, user.Username, user.Name))
+	}
+
+	return sendFeed(c, &feed, format)
+}
+
+// HandleGetSiteFeed serves the latest published posts of all users as a feed.
+// The format is the extension of the path: rss, atom or json.
+func HandleGetSiteFeed(c *fiber.Ctx) error {
+	format := feeds.Format(c.Params("format"))
+	if !format.IsValid() {
+		return fiber.NewError(fiber.StatusNotFound, "feed not found")
+	}
+
+	state, err := queries.GetSiteFeedState(context.Background())
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting feed state: %+v", err))
+	}
+	if setFeedCacheHeaders(c, format, state.PostsCount, state.LastUpdatedAt) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	posts, err := queries.GetSiteFeedPosts(context.Background(), feedPostsLimit)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting posts: %+v", err))
+	}
+
+	feed := feeds.Feed{
+		Title:       "Latest posts",
+		Link:        os.Getenv("APP_BASE_URL"),
+		FeedLink:    os.Getenv("APP_BASE_URL") + c.Path(),
+		Description: "Latest posts from all authors",
+		Updated:     state.LastUpdatedAt,
+		Items:       make([]feeds.Item, 0, len(posts)),
+	}
+	for _, post := range posts {
+		feed.Items = append(feed.Items, newFeedItem(&post.Post, post.AuthorUsername, post.AuthorName))
+	}
+
+	return sendFeed(c, &feed, format)
+}
+
+// newFeedItem makes a feed item of a published post.
+func newFeedItem(post *postgres_repo.Post, authorUsername, authorName string) feeds.Item {
+	published := post.CreatedAt
+	if post.PublishedAt.Valid {
+		published = post.PublishedAt.Time
+	}
+	return feeds.Item{
+		ID:          "urn:uuid:" + post.ID.String(), // unlike the link, it doesn't change with the slug
+		Title:       post.Title,
+		Link:        postURL(authorUsername, post.Slug),
+		Author:      authorName,
+		ContentHTML: postContentHTML(post),
+		Published:   published,
+		Updated:     post.UpdatedAt,
+	}
+}
+
+// postContentHTML is the rendered content of a post for syndication. Posts are rendered asynchronously,
+// so a post that's not rendered yet gets its markdown source as preformatted text.
+func postContentHTML(post *postgres_repo.Post) string {
+	if post.ContentHtml.Valid {
+		return post.ContentHtml.String
+	}
+	return "<pre>" + html.EscapeString(post.Content) + "</pre>"
+}
+
+// userURL is the public url of the profile of a user.
+func userURL(username string) string {
+	return os.Getenv("APP_BASE_URL") + "/api/v1/users/username/" + url.PathEscape(username)
+}
+
+// postURL is the public url of a published post.
+func postURL(username, slug string) string {
+	return userURL(username) + "/posts/" + url.PathEscape(slug)
+}
+
+// setFeedCacheHeaders sets the validators of a feed from the number of its posts and the time of
+// the latest change to them, and reports whether the client's cached copy is still fresh.
+// Deleting or unlisting a post changes the count, and any other change moves the time forward.
+func setFeedCacheHeaders(c *fiber.Ctx, format feeds.Format, postsCount int64, lastUpdatedAt time.Time) bool {
+	c.Set(fiber.HeaderETag, fmt.Sprintf(`W/"%s-%d-%d"`, format, postsCount, lastUpdatedAt.UnixNano()))
+	c.Set(fiber.HeaderLastModified, lastUpdatedAt.UTC().Format(http.TimeFormat))
+	c.Set(fiber.HeaderCacheControl, feedCacheControl)
+	return c.Fresh()
+}
+
+func sendFeed(c *fiber.Ctx, feed *feeds.Feed, format feeds.Format) error {
+	body, err := feed.Encode(format)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error encoding feed: %+v", err))
+	}
+	c.Set(fiber.HeaderContentType, format.ContentType())
+	return c.Send(body)
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/assaidy/blogging_app/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// HandleGetJWKS serves the public keys of the access tokens as a JWK set, so other services
+// can verify the tokens. The keys are found by the kid header of the tokens.
+func HandleGetJWKS(c *fiber.Ctx) error {
+	jwks, err := utils.GetJWKS()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting JWT keys: %+v", err))
+	}
+
+	// a short max-age, so a new key is picked up soon after it's added
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(jwks)
+}
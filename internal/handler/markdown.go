@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/assaidy/blogging_app/internal/db/postgres_db"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/assaidy/blogging_app/internal/utils"
+)
+
+const renderBatchSize = 100
+
+// renderPostContent renders the markdown content of a post into the html and toc to be cached with it.
+func renderPostContent(content string) (sql.NullString, json.RawMessage, error) {
+	html, toc, err := utils.RenderMarkdown(content)
+	if err != nil {
+		return sql.NullString{}, nil, err
+	}
+	tocJson, err := json.Marshal(toc)
+	if err != nil {
+		return sql.NullString{}, nil, err
+	}
+	return sql.NullString{Valid: true, String: html}, tocJson, nil
+}
+
+// renderCommentContent renders the markdown content of a comment into the html to be cached with it.
+func renderCommentContent(content string) (sql.NullString, error) {
+	html, _, err := utils.RenderMarkdown(content)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{Valid: true, String: html}, nil
+}
+
+// RenderUnrenderedContent renders and caches the html of posts and comments that were created
+// before content was rendered on write. It's meant to run once on startup, in the background.
+// NOTE: with prefork every child process runs it. Rows are claimed using `FOR UPDATE SKIP LOCKED`,
+// so the processes share the work instead of repeating it.
+func RenderUnrenderedContent() {
+	for {
+		n, err := renderPostsBatch()
+		if err != nil {
+			slog.Error("error rendering posts content", "err", err)
+			break
+		}
+		if n < renderBatchSize {
+			break
+		}
+	}
+	for {
+		n, err := renderCommentsBatch()
+		if err != nil {
+			slog.Error("error rendering comments content", "err", err)
+			break
+		}
+		if n < renderBatchSize {
+			break
+		}
+	}
+}
+
+func renderPostsBatch() (int, error) {
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := queries.WithTx(tx)
+
+	posts, err := qtx.GetUnrenderedPosts(context.Background(), renderBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("error getting unrendered posts: %w", err)
+	}
+
+	for _, post := range posts {
+		html, toc, err := renderPostContent(post.Content)
+		if err != nil {
+			return 0, fmt.Errorf("error rendering post content: %w", err)
+		}
+		if err := qtx.UpdatePostRendering(context.Background(), postgres_repo.UpdatePostRenderingParams{
+			ContentHtml: html,
+			Toc:         toc,
+			ID:          post.ID,
+		}); err != nil {
+			return 0, fmt.Errorf("error updating post rendering: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return len(posts), nil
+}
+
+func renderCommentsBatch() (int, error) {
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := queries.WithTx(tx)
+
+	comments, err := qtx.GetUnrenderedComments(context.Background(), renderBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("error getting unrendered comments: %w", err)
+	}
+
+	for _, comment := range comments {
+		html, err := renderCommentContent(comment.Content)
+		if err != nil {
+			return 0, fmt.Errorf("error rendering comment content: %w", err)
+		}
+		if err := qtx.UpdateCommentRendering(context.Background(), postgres_repo.UpdateCommentRenderingParams{
+			ContentHtml: html,
+			ID:          comment.ID,
+		}); err != nil {
+			return 0, fmt.Errorf("error updating comment rendering: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return len(comments), nil
+}
@@ -0,0 +1,420 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/db/postgres_db"
+	"github.com/assaidy/blogging_app/internal/repo"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/assaidy/blogging_app/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer = "blogging app"
+	// the wrong codes allowed per login before the mfa token is revoked.
+	maxMfaAttempts = 5
+	// the wrong codes allowed per user, across logins, before two-factor authentication is locked.
+	maxMfaFailedAttempts = 10
+	mfaLockoutDuration   = 15 * time.Minute
+)
+
+// HandleEnrollTotp generates a new TOTP secret for the user to add to an authenticator app.
+// Two-factor authentication is enabled once it's confirmed with a code by HandleConfirmTotp.
+func HandleEnrollTotp(c *fiber.Ctx) error {
+	userID := getUserIDFromContext(c)
+
+	user, err := queries.GetUserByID(context.Background(), userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting user: %+v", err))
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error generating TOTP secret: %+v", err))
+	}
+
+	if created, err := queries.CreateTotpAuthenticator(context.Background(), postgres_repo.CreateTotpAuthenticatorParams{
+		UserID: userID,
+		Secret: secret,
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error storing TOTP authenticator: %+v", err))
+	} else if created == 0 {
+		return fiber.NewError(fiber.StatusConflict, "two-factor authentication is already enabled")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(ApiResponse{
+		Payload: TotpEnrollmentPayload{
+			Secret: secret,
+			URI:    utils.TOTPURI(totpIssuer, user.Username, secret),
+		},
+	})
+}
+
+// HandleConfirmTotp enables two-factor authentication with the code of the enrolled authenticator,
+// and returns the recovery codes. They're only shown once.
+func HandleConfirmTotp(c *fiber.Ctx) error {
+	req := MfaCodeRequest{}
+	if err := parseAndValidateJsonBody(c, &req); err != nil {
+		return err
+	}
+
+	userID := getUserIDFromContext(c)
+
+	authenticator, err := queries.GetTotpAuthenticator(context.Background(), userID)
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusNotFound, "no TOTP authenticator enrolled")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting TOTP authenticator: %+v", err))
+	}
+	if authenticator.ConfirmedAt.Valid {
+		return fiber.NewError(fiber.StatusConflict, "two-factor authentication is already enabled")
+	}
+
+	step, ok := utils.ValidateTOTP(authenticator.Secret, req.Code, time.Now())
+	if !ok {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "invalid code")
+	}
+
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	if confirmed, err := qtx.ConfirmTotpAuthenticator(context.Background(), postgres_repo.ConfirmTotpAuthenticatorParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error confirming TOTP authenticator: %+v", err))
+	} else if confirmed == 0 {
+		return fiber.NewError(fiber.StatusConflict, "two-factor authentication is already enabled")
+	}
+
+	recoveryCodes, err := replaceRecoveryCodes(qtx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ApiResponse{
+		Payload: RecoveryCodesPayload{RecoveryCodes: recoveryCodes},
+	})
+}
+
+// HandleRegenerateRecoveryCodes replaces the recovery codes of the user, e.g. when they run out.
+func HandleRegenerateRecoveryCodes(c *fiber.Ctx) error {
+	req := MfaCodeRequest{}
+	if err := parseAndValidateJsonBody(c, &req); err != nil {
+		return err
+	}
+
+	userID := getUserIDFromContext(c)
+
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	// a recovery code can't be used to get new ones
+	if valid, err := verifyMfaAttempt(qtx, userID, req.Code, verifyTotpCode); err != nil {
+		return err
+	} else if !valid {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "invalid code")
+	}
+
+	recoveryCodes, err := replaceRecoveryCodes(qtx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ApiResponse{
+		Payload: RecoveryCodesPayload{RecoveryCodes: recoveryCodes},
+	})
+}
+
+// HandleDisableTotp disables two-factor authentication, which needs the password and a code
+// (TOTP or recovery code).
+func HandleDisableTotp(c *fiber.Ctx) error {
+	req := MfaDisableRequest{}
+	if err := parseAndValidateJsonBody(c, &req); err != nil {
+		return err
+	}
+
+	userID := getUserIDFromContext(c)
+
+	user, err := queries.GetUserByID(context.Background(), userID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting user: %+v", err))
+	}
+	if !utils.VerifyPassword(req.Password, user.HashedPassword) {
+		return fiber.NewError(fiber.StatusForbidden, "invalid password")
+	}
+
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	if valid, err := verifyMfaAttempt(qtx, userID, req.Code, verifyMfaCode); err != nil {
+		return err
+	} else if !valid {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "invalid code")
+	}
+
+	if err := qtx.DeleteTotpAuthenticator(context.Background(), userID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting TOTP authenticator: %+v", err))
+	}
+	if err := qtx.DeleteAllUserRecoveryCodes(context.Background(), userID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting recovery codes: %+v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).SendString("two-factor authentication disabled successfully")
+}
+
+// HandleVerifyMfa completes the login of a user with two-factor authentication: it exchanges the mfa token
+// returned by HandleLogin and a TOTP or recovery code for the access and refresh tokens.
+func HandleVerifyMfa(c *fiber.Ctx) error {
+	req := MfaVerifyRequest{}
+	if err := parseAndValidateJsonBody(c, &req); err != nil {
+		return err
+	}
+
+	challenge, err := queries.AttemptMfaChallenge(context.Background(), postgres_repo.AttemptMfaChallengeParams{
+		Token:       req.MfaToken,
+		MaxAttempts: maxMfaAttempts,
+	})
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting mfa challenge: %+v", err))
+	}
+
+	if challenge.ExpiresAt.Before(time.Now()) {
+		if _, err := queries.DeleteMfaChallenge(context.Background(), challenge.Token); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting mfa challenge: %+v", err))
+		}
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "mfa token expired, log in again")
+	}
+
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	if valid, err := verifyMfaAttempt(qtx, challenge.UserID, req.Code, verifyMfaCode); err != nil {
+		return err
+	} else if !valid {
+		if challenge.Attempts >= maxMfaAttempts {
+			if _, err := queries.DeleteMfaChallenge(context.Background(), challenge.Token); err != nil {
+				return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting mfa challenge: %+v", err))
+			}
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid code, too many attempts, log in again")
+		}
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid code")
+	}
+
+	// concurrent requests may complete the same challenge with different codes, only the one deleting it
+	// logs in. The others are rolled back, so their codes aren't used up.
+	if deleted, err := qtx.DeleteMfaChallenge(context.Background(), challenge.Token); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting mfa challenge: %+v", err))
+	} else if deleted != 1 {
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+
+	user, err := queries.GetUserByID(context.Background(), challenge.UserID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting user: %+v", err))
+	}
+
+	accessToken, refreshToken, err := startSession(c, user.ID)
+	if err != nil {
+		return err
+	}
+
+	var userPayload UserPayload
+	fillUserPayload(&userPayload, &user)
+
+	return c.Status(fiber.StatusCreated).JSON(ApiResponse{
+		Payload:      userPayload,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	})
+}
+
+// createMfaChallenge starts the second step of the login of a user with two-factor authentication,
+// returning the mfa token to complete it with.
+func createMfaChallenge(userID uuid.UUID) (string, error) {
+	if err := queries.DeleteExpiredMfaChallenges(context.Background()); err != nil {
+		return "", fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting expired mfa challenges: %+v", err))
+	}
+
+	mfaToken, err := utils.GenerateMFAToken()
+	if err != nil {
+		return "", fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating mfa token: %+v", err))
+	}
+
+	if err := queries.CreateMfaChallenge(context.Background(), postgres_repo.CreateMfaChallengeParams{
+		Token:     mfaToken.Token,
+		UserID:    userID,
+		ExpiresAt: mfaToken.ExpiresAt,
+	}); err != nil {
+		return "", fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error storing mfa challenge: %+v", err))
+	}
+
+	return mfaToken.Token, nil
+}
+
+// mfaCodeVerifier checks a code of the user, like verifyMfaCode and verifyTotpCode.
+type mfaCodeVerifier func(q *postgres_repo.Queries, userID uuid.UUID, code string) (bool, error)
+
+// verifyMfaAttempt checks the code with verify, counting the wrong codes of the user across logins,
+// so they can't be guessed by logging in again once a challenge runs out of attempts. Too many lock
+// two-factor authentication for mfaLockoutDuration. The wrong codes are counted outside of q's transaction,
+// so rolling it back doesn't undo them.
+func verifyMfaAttempt(q *postgres_repo.Queries, userID uuid.UUID, code string, verify mfaCodeVerifier) (bool, error) {
+	authenticator, err := q.GetTotpAuthenticator(context.Background(), userID)
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting TOTP authenticator: %+v", err))
+	}
+	if authenticator.LockedUntil.Valid && authenticator.LockedUntil.Time.After(time.Now()) {
+		return false, fiber.NewError(fiber.StatusTooManyRequests, "too many wrong codes, try again later")
+	}
+
+	valid, err := verify(q, userID, code)
+	if err != nil {
+		return false, err
+	}
+	if !valid {
+		lockedUntil, err := queries.RecordMfaFailure(context.Background(), postgres_repo.RecordMfaFailureParams{
+			MaxFailedAttempts: maxMfaFailedAttempts,
+			LockedUntil:       time.Now().Add(mfaLockoutDuration).UTC(),
+			UserID:            userID,
+		})
+		if err != nil && !repo.IsNotFoundError(err) {
+			return false, fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error recording wrong code: %+v", err))
+		}
+		if lockedUntil.Valid && lockedUntil.Time.After(time.Now()) {
+			return false, fiber.NewError(fiber.StatusTooManyRequests, "too many wrong codes, try again later")
+		}
+		return false, nil
+	}
+
+	if authenticator.FailedAttempts > 0 || authenticator.LockedUntil.Valid {
+		if err := q.ResetMfaFailures(context.Background(), userID); err != nil {
+			return false, fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error resetting wrong codes: %+v", err))
+		}
+	}
+	return true, nil
+}
+
+// verifyMfaCode checks a TOTP code, or uses a recovery code. Either can only be used once.
+func verifyMfaCode(q *postgres_repo.Queries, userID uuid.UUID, code string) (bool, error) {
+	if isTotpCode(code) {
+		return verifyTotpCode(q, userID, code)
+	}
+
+	used, err := q.UseRecoveryCode(context.Background(), postgres_repo.UseRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: utils.HashRecoveryCode(code),
+	})
+	if err != nil {
+		return false, fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error using recovery code: %+v", err))
+	}
+	return used == 1, nil
+}
+
+// verifyTotpCode checks the code against the confirmed authenticator of the user, and records its step
+// so it can't be used again.
+func verifyTotpCode(q *postgres_repo.Queries, userID uuid.UUID, code string) (bool, error) {
+	authenticator, err := q.GetTotpAuthenticator(context.Background(), userID)
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting TOTP authenticator: %+v", err))
+	}
+	if !authenticator.ConfirmedAt.Valid {
+		return false, nil
+	}
+
+	step, ok := utils.ValidateTOTP(authenticator.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	used, err := q.UseTotpStep(context.Background(), postgres_repo.UseTotpStepParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		return false, fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error using TOTP code: %+v", err))
+	}
+	return used == 1, nil
+}
+
+func isTotpCode(code string) bool {
+	if len(code) != utils.TOTPDigits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// replaceRecoveryCodes generates new recovery codes for the user, invalidating the old ones.
+func replaceRecoveryCodes(qtx *postgres_repo.Queries, userID uuid.UUID) ([]string, error) {
+	recoveryCodes, err := utils.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error generating recovery codes: %+v", err))
+	}
+
+	codeHashes := make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		codeHashes[i] = utils.HashRecoveryCode(code)
+	}
+
+	if err := qtx.DeleteAllUserRecoveryCodes(context.Background(), userID); err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting recovery codes: %+v", err))
+	}
+	if err := qtx.CreateRecoveryCodes(context.Background(), postgres_repo.CreateRecoveryCodesParams{
+		UserID:     userID,
+		CodeHashes: codeHashes,
+	}); err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error storing recovery codes: %+v", err))
+	}
+
+	return recoveryCodes, nil
+}
@@ -2,38 +2,72 @@ package handler
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
+	"time"
 
+	"github.com/assaidy/blogging_app/internal/db/postgres_db"
+	"github.com/assaidy/blogging_app/internal/repo"
 	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/assaidy/blogging_app/internal/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
 var (
-	notificationChan = make(chan postgres_repo.Notification, 1000)
-	stopChan         = make(chan struct{}, 1)
-	wg               sync.WaitGroup
+	stopChan = make(chan struct{})
+	wg       sync.WaitGroup
 )
 
-const numWorkers = 10
+const (
+	numWorkers                   = 10
+	notificationPollInterval     = time.Second
+	maxNotificationEventAttempts = 8
+	notificationRetryBaseDelay   = 5 * time.Second
+	notificationRetryMaxDelay    = time.Hour
+	maxMentionsPerContent        = 20
+	notificationGroupWindow      = 24 * time.Hour
+	maxNotificationGroupActors   = 10
+)
 
+// StartNotificationWorkers starts workers turning the events of the notifications outbox into notifications.
+// NOTE: with prefork every child process runs its own workers. Events are claimed using
+// `FOR UPDATE SKIP LOCKED`, so each event is processed by a single worker at a time.
 func StartNotificationWorkers() {
 	for range numWorkers {
 		wg.Add(1)
 
 		go func() {
 			defer wg.Done()
-			for notification := range notificationChan {
-				if _, err := queries.CreateNotification(context.Background(), postgres_repo.CreateNotificationParams{
-					KindID:   notification.KindID,
-					UserID:   notification.UserID,
-					SenderID: notification.SenderID,
-					PostID:   notification.PostID,
-					IsRead:   notification.IsRead,
-				}); err != nil {
-					slog.Error("error creating notification", "err", err)
+
+			ticker := time.NewTicker(notificationPollInterval)
+			defer ticker.Stop()
+
+			for {
+				// process due events until none are left, then wait for the next poll
+				for {
+					processed, err := processNextNotificationEvent()
+					if err != nil {
+						slog.Error("error processing notification event", "err", err)
+						break
+					}
+					if !processed {
+						break
+					}
+					select {
+					case <-stopChan:
+						return
+					default:
+					}
+				}
+
+				select {
+				case <-stopChan:
+					return
+				case <-ticker.C:
 				}
 			}
 		}()
@@ -41,10 +75,242 @@ func StartNotificationWorkers() {
 }
 
 func StopNotificationWorker() {
-	close(notificationChan)
+	close(stopChan)
 	wg.Wait()
 }
 
+// queueFollowNotification records a 'new_follower' event in the notifications outbox.
+// q should be bound to the transaction creating the follow.
+func queueFollowNotification(q *postgres_repo.Queries, followerID, followedID uuid.UUID) error {
+	return q.CreateNotificationEvent(context.Background(), postgres_repo.CreateNotificationEventParams{
+		KindID:   repo.NotificationKindNewFollower,
+		UserID:   uuid.NullUUID{Valid: true, UUID: followedID},
+		SenderID: uuid.NullUUID{Valid: true, UUID: followerID},
+	})
+}
+
+// queueNewPostNotifications records a 'new_post' event in the notifications outbox, along with
+// 'mention' events for the users mentioned in the post.
+// q should be bound to the transaction publishing the post.
+func queueNewPostNotifications(q *postgres_repo.Queries, post *postgres_repo.Post) error {
+	if err := q.CreateNotificationEvent(context.Background(), postgres_repo.CreateNotificationEventParams{
+		KindID:   repo.NotificationKindNewPost,
+		SenderID: uuid.NullUUID{Valid: true, UUID: post.UserID},
+		PostID:   uuid.NullUUID{Valid: true, UUID: post.ID},
+	}); err != nil {
+		return err
+	}
+	return queueMentionNotifications(q, post.UserID, post.ID, uuid.NullUUID{}, post.Content)
+}
+
+// queueCommentNotifications records a 'new_comment' event for the author of the post, unless they wrote
+// the comment, along with 'mention' events for the other users mentioned in the comment.
+// q should be bound to the transaction creating the comment.
+func queueCommentNotifications(q *postgres_repo.Queries, postAuthorID uuid.UUID, comment *postgres_repo.PostComment) error {
+	if postAuthorID != comment.UserID {
+		if err := q.CreateNotificationEvent(context.Background(), postgres_repo.CreateNotificationEventParams{
+			KindID:    repo.NotificationKindNewComment,
+			UserID:    uuid.NullUUID{Valid: true, UUID: postAuthorID},
+			SenderID:  uuid.NullUUID{Valid: true, UUID: comment.UserID},
+			PostID:    uuid.NullUUID{Valid: true, UUID: comment.PostID},
+			CommentID: uuid.NullUUID{Valid: true, UUID: comment.ID},
+		}); err != nil {
+			return err
+		}
+	}
+	return queueMentionNotifications(q, comment.UserID, comment.PostID, uuid.NullUUID{Valid: true, UUID: comment.ID}, comment.Content, postAuthorID)
+}
+
+// queueReplyNotifications records a 'new_reply' event for the author of the parent comment, unless they
+// wrote the reply, along with 'mention' events for the other users mentioned in the reply.
+// q should be bound to the transaction creating the reply.
+func queueReplyNotifications(q *postgres_repo.Queries, parent *postgres_repo.PostComment, reply *postgres_repo.PostComment) error {
+	if parent.UserID != reply.UserID {
+		if err := q.CreateNotificationEvent(context.Background(), postgres_repo.CreateNotificationEventParams{
+			KindID:    repo.NotificationKindNewReply,
+			UserID:    uuid.NullUUID{Valid: true, UUID: parent.UserID},
+			SenderID:  uuid.NullUUID{Valid: true, UUID: reply.UserID},
+			PostID:    uuid.NullUUID{Valid: true, UUID: reply.PostID},
+			CommentID: uuid.NullUUID{Valid: true, UUID: reply.ID},
+		}); err != nil {
+			return err
+		}
+	}
+	return queueMentionNotifications(q, reply.UserID, reply.PostID, uuid.NullUUID{Valid: true, UUID: reply.ID}, reply.Content, parent.UserID)
+}
+
+// queueReactionNotification records a 'new_reaction' event for the author of the post, unless they reacted
+// to their own post. q should be bound to the transaction creating the reaction.
+func queueReactionNotification(q *postgres_repo.Queries, postAuthorID, userID, postID uuid.UUID) error {
+	if postAuthorID == userID {
+		return nil
+	}
+	return q.CreateNotificationEvent(context.Background(), postgres_repo.CreateNotificationEventParams{
+		KindID:   repo.NotificationKindNewReaction,
+		UserID:   uuid.NullUUID{Valid: true, UUID: postAuthorID},
+		SenderID: uuid.NullUUID{Valid: true, UUID: userID},
+		PostID:   uuid.NullUUID{Valid: true, UUID: postID},
+	})
+}
+
+// queueMentionNotifications records a 'mention' event for every user mentioned as @username in the content
+// of a post, or a comment if commentID is valid. Only the first maxMentionsPerContent mentions are considered.
+// The sender and the excluded users, who are already notified about the content, aren't notified.
+func queueMentionNotifications(q *postgres_repo.Queries, senderID, postID uuid.UUID, commentID uuid.NullUUID, content string, excluded ...uuid.UUID) error {
+	usernames := utils.ExtractMentions(content)
+	if len(usernames) == 0 {
+		return nil
+	}
+	if len(usernames) > maxMentionsPerContent {
+		usernames = usernames[:maxMentionsPerContent]
+	}
+
+	mentionedIDs, err := q.GetUsersIDsByUsernames(context.Background(), usernames)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range mentionedIDs {
+		if id == senderID || slices.Contains(excluded, id) {
+			continue
+		}
+		if err := q.CreateNotificationEvent(context.Background(), postgres_repo.CreateNotificationEventParams{
+			KindID:    repo.NotificationKindMention,
+			UserID:    uuid.NullUUID{Valid: true, UUID: id},
+			SenderID:  uuid.NullUUID{Valid: true, UUID: senderID},
+			PostID:    uuid.NullUUID{Valid: true, UUID: postID},
+			CommentID: commentID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// processNextNotificationEvent claims the next due event of the outbox and creates its notifications,
+// deleting the event in the same transaction. If creating the notifications fails, the event is retried
+// later with a backoff, until it's dead after maxNotificationEventAttempts.
+// Returns false if there was no due event.
+func processNextNotificationEvent() (bool, error) {
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return false, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := queries.WithTx(tx)
+
+	event, err := qtx.ClaimNotificationEvent(context.Background())
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error claiming notification event: %w", err)
+	}
+
+	// a failed statement aborts the whole transaction, so failures are rolled back to here
+	// to keep the event locked while recording the failure.
+	if _, err := tx.ExecContext(context.Background(), "SAVEPOINT notification_event"); err != nil {
+		return false, fmt.Errorf("error creating savepoint: %w", err)
+	}
+
+	if err := createEventNotifications(qtx, &event); err != nil {
+		if _, err := tx.ExecContext(context.Background(), "ROLLBACK TO SAVEPOINT notification_event"); err != nil {
+			return false, fmt.Errorf("error rolling back to savepoint: %w", err)
+		}
+		if err := failNotificationEvent(qtx, &event, err); err != nil {
+			return false, fmt.Errorf("error recording notification event failure: %w", err)
+		}
+	} else if err := qtx.DeleteNotificationEvent(context.Background(), event.ID); err != nil {
+		return false, fmt.Errorf("error deleting notification event: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// createEventNotifications creates the notifications of the event for all of its receivers,
+// skipping the receivers who opted out of them through their preferences or mutes.
+func createEventNotifications(q *postgres_repo.Queries, event *postgres_repo.NotificationEvent) error {
+	switch event.KindID {
+	case repo.NotificationKindNewFollower,
+		repo.NotificationKindNewComment,
+		repo.NotificationKindNewReaction:
+		return createGroupedNotification(q, event)
+	case repo.NotificationKindNewReply,
+		repo.NotificationKindMention:
+		return q.CreateNotificationIfWanted(context.Background(), postgres_repo.CreateNotificationIfWantedParams{
+			KindID:    event.KindID,
+			UserID:    event.UserID.UUID,
+			SenderID:  event.SenderID,
+			PostID:    event.PostID,
+			CommentID: event.CommentID,
+		})
+	case repo.NotificationKindNewPost:
+		if err := q.CreateFollowersNotifications(context.Background(), postgres_repo.CreateFollowersNotificationsParams{
+			KindID:   repo.NotificationKindNewPost,
+			SenderID: event.SenderID.UUID,
+			PostID:   event.PostID,
+		}); err != nil {
+			return err
+		}
+		return q.CreateTopicSubscribersNotifications(context.Background(), postgres_repo.CreateTopicSubscribersNotificationsParams{
+			KindID: repo.NotificationKindTopicMatch,
+			PostID: event.PostID.UUID,
+		})
+	default:
+		return fmt.Errorf("unknown notification event kind: %d", event.KindID)
+	}
+}
+
+// createGroupedNotification creates the notification of the event, grouped with the receiver's unread notification
+// of the same kind and post created within notificationGroupWindow, if any. So a popular post ends up with a single
+// "alice and 23 others reacted to your post" notification, instead of one per reaction.
+func createGroupedNotification(q *postgres_repo.Queries, event *postgres_repo.NotificationEvent) error {
+	// without the lock, concurrent workers may both miss the open group and start new ones.
+	if err := q.LockNotificationGroup(context.Background(), postgres_repo.LockNotificationGroupParams{
+		UserID: event.UserID.UUID,
+		KindID: event.KindID,
+		PostID: event.PostID,
+	}); err != nil {
+		return err
+	}
+	return q.CreateGroupedNotificationIfWanted(context.Background(), postgres_repo.CreateGroupedNotificationIfWantedParams{
+		UserID:    event.UserID.UUID,
+		KindID:    event.KindID,
+		PostID:    event.PostID,
+		Since:     time.Now().Add(-notificationGroupWindow).UTC(),
+		SenderID:  event.SenderID.UUID,
+		CommentID: event.CommentID,
+		MaxActors: maxNotificationGroupActors,
+	})
+}
+
+func failNotificationEvent(q *postgres_repo.Queries, event *postgres_repo.NotificationEvent, eventErr error) error {
+	attempts := int(event.Attempts) + 1
+	lastError := sql.NullString{Valid: true, String: eventErr.Error()}
+
+	if attempts >= maxNotificationEventAttempts {
+		slog.Error("notification event is dead", "err", eventErr, "eventID", event.ID, "attempts", attempts)
+		return q.KillNotificationEvent(context.Background(), postgres_repo.KillNotificationEventParams{
+			LastError: lastError,
+			ID:        event.ID,
+		})
+	}
+
+	slog.Warn("notification event failed, retrying later", "err", eventErr, "eventID", event.ID, "attempts", attempts)
+	delay := utils.Backoff(attempts, notificationRetryBaseDelay, notificationRetryMaxDelay)
+	return q.RetryNotificationEvent(context.Background(), postgres_repo.RetryNotificationEventParams{
+		// the column has no time zone, so store it as UTC like NOW() does.
+		NextAttemptAt: time.Now().Add(delay).UTC(),
+		LastError:     lastError,
+		ID:            event.ID,
+	})
+}
+
 func HandleGetUnreadNotificationsCount(c *fiber.Ctx) error {
 	userID := getUserIDFromContext(c)
 
@@ -81,6 +347,85 @@ func HandleMarkNotificationAsRead(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).SendString("notification marked as read successfully")
 }
 
+func HandleMarkNotificationsAsRead(c *fiber.Ctx) error {
+	req := NotificationsMarkAsReadRequest{}
+	if err := parseAndValidateJsonBody(c, &req); err != nil {
+		return err
+	}
+
+	// IDs of notifications of other users, or already read, are ignored.
+	markedCount, err := queries.MarkNotificationsAsRead(context.Background(), postgres_repo.MarkNotificationsAsReadParams{
+		UserID: getUserIDFromContext(c),
+		Ids:    req.IDs,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error marking notifications as read: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ApiResponse{
+		Payload: markedCount,
+	})
+}
+
+// HandleMarkAllNotificationsAsRead marks all notifications of the user as read. With the `until` query param,
+// only the notifications up to (and including) that notification are marked, so notifications received after
+// the client fetched its list stay unread.
+func HandleMarkAllNotificationsAsRead(c *fiber.Ctx) error {
+	var untilID uuid.UUID
+	if until := c.Query("until"); until != "" {
+		id, err := uuid.Parse(until)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+		}
+		untilID = id
+	}
+
+	markedCount, err := queries.MarkAllNotificationsAsRead(context.Background(), postgres_repo.MarkAllNotificationsAsReadParams{
+		UserID:  getUserIDFromContext(c),
+		UntilID: untilID,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error marking notifications as read: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ApiResponse{
+		Payload: markedCount,
+	})
+}
+
+func HandleDeleteNotification(c *fiber.Ctx) error {
+	notificationID, err := uuid.Parse(c.Params("notification_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	if exists, err := queries.CheckNotificationForUser(context.Background(), postgres_repo.CheckNotificationForUserParams{
+		ID:     notificationID,
+		UserID: getUserIDFromContext(c),
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking notification: %+v", err))
+	} else if !exists {
+		return fiber.NewError(fiber.StatusNotFound, "notification not found")
+	}
+
+	if err := queries.DeleteNotification(context.Background(), notificationID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting notification: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).SendString("notification deleted successfully")
+}
+
+func HandleDeleteAllReadNotifications(c *fiber.Ctx) error {
+	deletedCount, err := queries.DeleteAllReadNotifications(context.Background(), getUserIDFromContext(c))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting read notifications: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ApiResponse{
+		Payload: deletedCount,
+	})
+}
+
 func HandleGetAllNotifications(c *fiber.Ctx) error {
 	limit := c.QueryInt("limit")
 	if limit < 10 || limit > 100 {
@@ -94,7 +439,8 @@ func HandleGetAllNotifications(c *fiber.Ctx) error {
 
 	notifications, err := queries.GetAllNotifications(context.Background(), postgres_repo.GetAllNotificationsParams{
 		// filter
-		UserID: getUserIDFromContext(c),
+		UserID:     getUserIDFromContext(c),
+		UnreadOnly: c.QueryBool("unread"),
 		// cursor
 		ID: requestCursor.ID,
 		// limit
@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/assaidy/blogging_app/internal/repo"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func HandleGetNotificationPreferences(c *fiber.Ctx) error {
+	preferences, err := queries.GetNotificationPreferences(context.Background(), getUserIDFromContext(c))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting notification preferences: %+v", err))
+	}
+
+	payload := make([]NotificationPreferencePayload, 0, len(preferences))
+	for _, preference := range preferences {
+		var preferencePayload NotificationPreferencePayload
+		fillNotificationPreferencePayload(&preferencePayload, &preference)
+		payload = append(payload, preferencePayload)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ApiResponse{
+		Payload: payload,
+	})
+}
+
+func HandleUpdateNotificationPreference(c *fiber.Ctx) error {
+	req := NotificationPreferenceUpdateRequest{}
+	if err := parseAndValidateJsonBody(c, &req); err != nil {
+		return err
+	}
+
+	kind := c.Params("kind")
+
+	kindID, err := queries.GetNotificationKindIDByName(context.Background(), kind)
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusNotFound, "notification kind not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting notification kind id: %+v", err))
+	}
+
+	if err := queries.UpsertNotificationPreference(context.Background(), postgres_repo.UpsertNotificationPreferenceParams{
+		UserID: getUserIDFromContext(c),
+		KindID: kindID,
+		InApp:  *req.InApp,
+		Digest: *req.Digest,
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error updating notification preference: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ApiResponse{
+		Payload: NotificationPreferencePayload{
+			Kind:   kind,
+			InApp:  *req.InApp,
+			Digest: *req.Digest,
+		},
+	})
+}
+
+func HandleMuteNotifications(c *fiber.Ctx) error {
+	req := NotificationMuteRequest{}
+	if err := parseAndValidateJsonBody(c, &req); err != nil {
+		return err
+	}
+	if (req.UserID == nil) == (req.PostID == nil) {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "invalid request data: exactly one of userID and postID is required")
+	}
+
+	userID := getUserIDFromContext(c)
+
+	var mutedUserID, mutedPostID uuid.NullUUID
+	if req.UserID != nil {
+		if *req.UserID == userID {
+			return fiber.NewError(fiber.StatusForbidden, "user can't mute himself")
+		}
+		if exists, err := queries.CheckUserID(context.Background(), *req.UserID); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking user ID: %+v", err))
+		} else if !exists {
+			return fiber.NewError(fiber.StatusNotFound, "user not found")
+		}
+		mutedUserID = uuid.NullUUID{Valid: true, UUID: *req.UserID}
+	} else {
+		if exists, err := queries.CheckPost(context.Background(), *req.PostID); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking post: %+v", err))
+		} else if !exists {
+			return fiber.NewError(fiber.StatusNotFound, "post not found")
+		}
+		mutedPostID = uuid.NullUUID{Valid: true, UUID: *req.PostID}
+	}
+
+	if exists, err := queries.CheckNotificationMute(context.Background(), postgres_repo.CheckNotificationMuteParams{
+		UserID:      userID,
+		MutedUserID: mutedUserID,
+		MutedPostID: mutedPostID,
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking notification mute: %+v", err))
+	} else if exists {
+		return fiber.NewError(fiber.StatusConflict, "already muted")
+	}
+
+	mute, err := queries.CreateNotificationMute(context.Background(), postgres_repo.CreateNotificationMuteParams{
+		UserID:      userID,
+		MutedUserID: mutedUserID,
+		MutedPostID: mutedPostID,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating notification mute: %+v", err))
+	}
+
+	var payload NotificationMutePayload
+	fillNotificationMutePayload(&payload, &mute)
+
+	return c.Status(fiber.StatusCreated).JSON(ApiResponse{
+		Payload: payload,
+	})
+}
+
+func HandleGetAllNotificationMutes(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit")
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	var requestCursor NotificationMutesCursor
+	if err := decodeBase64AndUnmarshalJson(&requestCursor, c.Query("cursor")); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid cursor format")
+	}
+
+	mutes, err := queries.GetAllNotificationMutes(context.Background(), postgres_repo.GetAllNotificationMutesParams{
+		// filter
+		UserID: getUserIDFromContext(c),
+		// cursor
+		ID: requestCursor.ID,
+		// limit
+		Limit: int32(limit) + 1,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting notification mutes: %+v", err))
+	}
+
+	var encodedResponseCursor string
+	hasMore := limit < len(mutes)
+	if hasMore {
+		responseCursor := NotificationMutesCursor{
+			ID: mutes[limit].ID,
+		}
+		encodedResponseCursor, err = marshalJsonAndEncodeBase64(responseCursor)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error encoding cursor: %+v", err))
+		}
+		mutes = mutes[:limit]
+	}
+
+	payload := make([]NotificationMutePayload, 0, len(mutes))
+	for _, mute := range mutes {
+		var mutePayload NotificationMutePayload
+		fillNotificationMutePayload(&mutePayload, &mute)
+		payload = append(payload, mutePayload)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(CursoredApiResponse{
+		Payload:    payload,
+		Cursor:     encodedResponseCursor,
+		HasMore:    hasMore,
+		TotalCount: len(payload),
+	})
+}
+
+func HandleUnmuteNotifications(c *fiber.Ctx) error {
+	muteID, err := uuid.Parse(c.Params("mute_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	if owns, err := queries.CheckUserOwnsNotificationMute(context.Background(), postgres_repo.CheckUserOwnsNotificationMuteParams{
+		ID:     muteID,
+		UserID: getUserIDFromContext(c),
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking notification mute: %+v", err))
+	} else if !owns {
+		return fiber.NewError(fiber.StatusNotFound, "notification mute not found")
+	}
+
+	if err := queries.DeleteNotificationMute(context.Background(), muteID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting notification mute: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).SendString("notifications unmuted successfully")
+}
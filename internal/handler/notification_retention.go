@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+)
+
+var (
+	retentionStopChan = make(chan struct{})
+	retentionWg       sync.WaitGroup
+)
+
+const (
+	retentionInterval  = time.Hour
+	retentionBatchSize = 1000
+)
+
+// StartNotificationRetention starts a background loop that purges read notifications older than
+// NOTIFICATION_RETENTION_DAYS. Unread notifications are never purged. If the env var is unset or 0,
+// notifications are kept forever. It also purges the actors of closed notification groups.
+// NOTE: with prefork every child process runs its own loop. Batches are claimed using
+// `FOR UPDATE SKIP LOCKED`, so processes don't wait on each other.
+func StartNotificationRetention() {
+	days := 0
+	if value := os.Getenv("NOTIFICATION_RETENTION_DAYS"); value != "" {
+		var err error
+		days, err = strconv.Atoi(value)
+		if err != nil || days < 0 {
+			log.Fatal("invalid env value for NOTIFICATION_RETENTION_DAYS:", value)
+		}
+	}
+
+	retentionWg.Add(1)
+
+	go func() {
+		defer retentionWg.Done()
+
+		retention := time.Duration(days) * 24 * time.Hour
+
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+
+		for {
+			if days > 0 {
+				if err := purgeOldReadNotifications(retention); err != nil {
+					slog.Error("error purging old read notifications", "err", err)
+				}
+			}
+			if err := purgeClosedNotificationGroupActors(); err != nil {
+				slog.Error("error purging closed notification group actors", "err", err)
+			}
+
+			select {
+			case <-retentionStopChan:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func StopNotificationRetention() {
+	close(retentionStopChan)
+	retentionWg.Wait()
+}
+
+// purgeOldReadNotifications deletes read notifications older than retention in batches until none are left.
+func purgeOldReadNotifications(retention time.Duration) error {
+	before := time.Now().Add(-retention).UTC()
+	for {
+		deleted, err := queries.DeleteOldReadNotifications(context.Background(), postgres_repo.DeleteOldReadNotificationsParams{
+			Limit:  retentionBatchSize,
+			Before: before,
+		})
+		if err != nil {
+			return fmt.Errorf("error deleting old read notifications: %w", err)
+		}
+		if deleted < retentionBatchSize {
+			return nil
+		}
+		select {
+		case <-retentionStopChan:
+			return nil
+		default:
+		}
+	}
+}
+
+// purgeClosedNotificationGroupActors deletes the actors of closed notification groups in batches until none are left.
+func purgeClosedNotificationGroupActors() error {
+	// twice the window, so a group a worker is merging into right now isn't seen as closed.
+	since := time.Now().Add(-2 * notificationGroupWindow).UTC()
+	for {
+		deleted, err := queries.DeleteClosedNotificationGroupActors(context.Background(), postgres_repo.DeleteClosedNotificationGroupActorsParams{
+			Limit: retentionBatchSize,
+			Since: since,
+		})
+		if err != nil {
+			return fmt.Errorf("error deleting closed notification group actors: %w", err)
+		}
+		if deleted < retentionBatchSize {
+			return nil
+		}
+		select {
+		case <-retentionStopChan:
+			return nil
+		default:
+		}
+	}
+}
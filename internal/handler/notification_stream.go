@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/repo"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	newNotificationChannel   = "new_notification" // postgres channel notified by the notifications insert trigger
+	streamBatchSize          = 100
+	streamHeartbeatInterval  = 30 * time.Second
+	listenerMinReconnectWait = 10 * time.Second
+	listenerMaxReconnectWait = time.Minute
+)
+
+// notificationStreams keeps track of the open notification streams of each user in this process.
+// Each stream has a wake channel, which is signaled when the user may have new notifications.
+type notificationStreams struct {
+	mu      sync.Mutex
+	streams map[uuid.UUID]map[chan struct{}]struct{}
+}
+
+var (
+	openStreams            = notificationStreams{streams: make(map[uuid.UUID]map[chan struct{}]struct{})}
+	notificationListener   *pq.Listener
+	notificationListenerWg sync.WaitGroup
+
+	// closed to end all streams, which would otherwise keep the server from shutting down.
+	closeStreamsChan = make(chan struct{})
+	closeStreamsOnce sync.Once
+
+	errStreamsClosed = errors.New("notification streams closed")
+)
+
+func (s *notificationStreams) add(userID uuid.UUID) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wake := make(chan struct{}, 1)
+	if s.streams[userID] == nil {
+		s.streams[userID] = make(map[chan struct{}]struct{})
+	}
+	s.streams[userID][wake] = struct{}{}
+	return wake
+}
+
+func (s *notificationStreams) remove(userID uuid.UUID, wake chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.streams[userID], wake)
+	if len(s.streams[userID]) == 0 {
+		delete(s.streams, userID)
+	}
+}
+
+// wakeUser signals all streams of the user. It never blocks: a stream that's already signaled
+// will fetch all new notifications anyway.
+func (s *notificationStreams) wakeUser(userID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for wake := range s.streams[userID] {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *notificationStreams) wakeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, userStreams := range s.streams {
+		for wake := range userStreams {
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// StartNotificationListener listens for new notifications using postgres LISTEN/NOTIFY, and wakes up
+// the streams of their receivers.
+// NOTE: with prefork, a notification may be created by any process, while the receiver's stream
+// may be open in another one. So every process listens to all new notifications.
+func StartNotificationListener() error {
+	notificationListener = pq.NewListener(os.Getenv("PG_URL"), listenerMinReconnectWait, listenerMaxReconnectWait,
+		func(event pq.ListenerEventType, err error) {
+			if err != nil {
+				slog.Error("notification listener error", "err", err, "event", event)
+			}
+		},
+	)
+	if err := notificationListener.Listen(newNotificationChannel); err != nil {
+		notificationListener.Close()
+		return fmt.Errorf("error listening for new notifications: %w", err)
+	}
+
+	notificationListenerWg.Add(1)
+
+	go func() {
+		defer notificationListenerWg.Done()
+		// the channel is closed when the listener is closed
+		for notification := range notificationListener.Notify {
+			// nil is sent after reconnecting, when notifications might have been missed
+			if notification == nil {
+				openStreams.wakeAll()
+				continue
+			}
+			userID, err := uuid.Parse(notification.Extra)
+			if err != nil {
+				slog.Error("invalid new notification payload", "err", err, "payload", notification.Extra)
+				continue
+			}
+			openStreams.wakeUser(userID)
+		}
+	}()
+
+	return nil
+}
+
+func StopNotificationListener() {
+	if err := notificationListener.Close(); err != nil {
+		slog.Error("error closing notification listener", "err", err)
+	}
+	notificationListenerWg.Wait()
+}
+
+// CloseNotificationStreams ends all open notification streams, and the ones opened after it.
+// It's called before shutting down the server, which waits for the open connections to finish.
+func CloseNotificationStreams() {
+	closeStreamsOnce.Do(func() { close(closeStreamsChan) })
+}
+
+// HandleStreamNotifications streams the new notifications of the user as server-sent events.
+// Each event has the notification id as its id, so a client reconnecting with the `Last-Event-ID`
+// header gets every notification created after that one. Otherwise, the stream starts from now.
+func HandleStreamNotifications(c *fiber.Ctx) error {
+	userID := getUserIDFromContext(c)
+
+	var lastID uuid.UUID
+	if lastEventID := c.Get("Last-Event-ID"); lastEventID != "" {
+		id, err := uuid.Parse(lastEventID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid Last-Event-ID format")
+		}
+		lastID = id
+	}
+
+	// start listening before reading the latest notification, so nothing created in between is missed
+	wake := openStreams.add(userID)
+
+	if lastID == uuid.Nil {
+		id, err := queries.GetLatestNotificationID(context.Background(), userID)
+		if err != nil && !repo.IsNotFoundError(err) {
+			openStreams.remove(userID, wake)
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting latest notification: %+v", err))
+		}
+		lastID = id
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer openStreams.remove(userID, wake)
+
+		heartbeat := time.NewTicker(streamHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			n, err := writeNewNotifications(w, userID, &lastID)
+			if err != nil {
+				// mostly a write error, meaning the client is gone
+				slog.Debug("notification stream closed", "err", err, "userID", userID)
+				return
+			}
+			if n == streamBatchSize {
+				continue
+			}
+
+			if err := waitForNotifications(w, wake, heartbeat); err != nil {
+				slog.Debug("notification stream closed", "err", err, "userID", userID)
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+// writeNewNotifications writes the notifications of the user created after lastID as events,
+// then updates lastID to the last written one. Returns the number of written notifications.
+func writeNewNotifications(w *bufio.Writer, userID uuid.UUID, lastID *uuid.UUID) (int, error) {
+	notifications, err := queries.GetAllNotificationsAfter(context.Background(), postgres_repo.GetAllNotificationsAfterParams{
+		UserID: userID,
+		ID:     *lastID,
+		Limit:  streamBatchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error getting notifications: %w", err)
+	}
+
+	for _, notification := range notifications {
+		var payload NotificationPayload
+		fillStreamedNotificationPayload(&payload, &notification)
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return 0, fmt.Errorf("error encoding notification: %w", err)
+		}
+		if _, err := fmt.Fprintf(w, "id: %s\nevent: notification\ndata: %s\n\n", notification.ID, data); err != nil {
+			return 0, err
+		}
+		*lastID = notification.ID
+	}
+
+	return len(notifications), w.Flush()
+}
+
+// waitForNotifications blocks until the stream is woken up, sending a heartbeat comment meanwhile
+// to keep the connection alive, and to detect a closed connection.
+func waitForNotifications(w *bufio.Writer, wake chan struct{}, heartbeat *time.Ticker) error {
+	for {
+		select {
+		case <-wake:
+			return nil
+		case <-closeStreamsChan:
+			return errStreamsClosed
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return err
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
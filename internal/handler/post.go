@@ -4,9 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/assaidy/blogging_app/internal/db/postgres_db"
 	"github.com/assaidy/blogging_app/internal/repo"
 	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/assaidy/blogging_app/internal/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
@@ -17,34 +21,79 @@ func HandleCreatePost(c *fiber.Ctx) error {
 		return err
 	}
 
+	tags, err := parseTags(req.Tags)
+	if err != nil {
+		return err
+	}
+
 	userID := getUserIDFromContext(c)
 
-	post, err := queries.CreatePost(context.Background(), postgres_repo.CreatePostParams{
-		UserID:           userID,
-		Title:            req.Title,
-		Content:          req.Content,
-		FeaturedImageUrl: sql.NullString{Valid: true, String: req.FeaturedImageUrl},
-	})
-	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating post: %+v", err))
+	status := repo.PostStatusDraft
+	var publishAt sql.NullTime
+	if req.PublishAt != nil {
+		if !req.PublishAt.After(time.Now()) {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, "publishAt must be in the future")
+		}
+		status = repo.PostStatusScheduled
+		// the column has no time zone, so store it as UTC like NOW() does.
+		publishAt = sql.NullTime{Valid: true, Time: req.PublishAt.UTC()}
 	}
 
-	followersIDs, err := queries.GetAllFollowersIDs(context.Background(), userID)
+	contentHtml, toc, err := renderPostContent(req.Content)
 	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting followers IDs: %+v", err))
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error rendering post content: %+v", err))
 	}
-	for _, id := range followersIDs {
-		notificationChan <- postgres_repo.Notification{
-			KindID:   repo.NotificationKindNewPost,
-			UserID:   id,
-			SenderID: uuid.NullUUID{Valid: true, UUID: post.UserID},
-			PostID:   uuid.NullUUID{Valid: true, UUID: post.ID},
+
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+
+	qtx := queries.WithTx(tx)
+
+	var post postgres_repo.Post
+	if err := withPostSlug(tx, userID, uuid.Nil, req.Title, func(slug string) error {
+		post, err = qtx.CreatePost(context.Background(), postgres_repo.CreatePostParams{
+			UserID:           userID,
+			Title:            req.Title,
+			Slug:             slug,
+			Content:          req.Content,
+			ContentHtml:      contentHtml,
+			Toc:              toc,
+			FeaturedImageUrl: sql.NullString{Valid: true, String: req.FeaturedImageUrl},
+			Status:           status,
+			PublishAt:        publishAt,
+		})
+		if err != nil {
+			return err
 		}
+		return qtx.CreatePostSlug(context.Background(), postgres_repo.CreatePostSlugParams{
+			PostID: post.ID,
+			UserID: userID,
+			Slug:   slug,
+		})
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating post: %+v", err))
+	}
+
+	if err := setPostTags(qtx, post.ID, tags); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error setting post tags: %+v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+
+	repoTags, err := queries.GetPostTags(context.Background(), post.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post tags: %+v", err))
 	}
 
 	var payload PostPayload
 	fillPostPayload(&payload, &post)
 	payload.Reactions = []PostPayloadReaction{}
+	fillPostTags(&payload, repoTags)
 
 	return c.Status(fiber.StatusCreated).JSON(ApiResponse{
 		Payload: payload,
@@ -64,15 +113,25 @@ func HandleGetPost(c *fiber.Ctx) error {
 		}
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post: %+v", err))
 	}
+	// unpublished posts are only visible to their author through the drafts listing
+	if post.Status == repo.PostStatusDraft || post.Status == repo.PostStatusScheduled {
+		return fiber.NewError(fiber.StatusNotFound, "post not found")
+	}
 
 	reactions, err := queries.GetPostReactions(context.Background(), postID)
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post reactions: %+v", err))
 	}
 
+	repoTags, err := queries.GetPostTags(context.Background(), postID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post tags: %+v", err))
+	}
+
 	var payload PostPayload
 	fillPostPayload(&payload, &post)
 	fillPostReactions(&payload, reactions)
+	fillPostTags(&payload, repoTags)
 
 	return c.Status(fiber.StatusOK).JSON(ApiResponse{
 		Payload: payload,
@@ -85,6 +144,11 @@ func HandleUpdatePost(c *fiber.Ctx) error {
 		return err
 	}
 
+	tags, err := parseTags(req.Tags)
+	if err != nil {
+		return err
+	}
+
 	postID, err := uuid.Parse(c.Params("post_id"))
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
@@ -107,24 +171,217 @@ func HandleUpdatePost(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusUnauthorized, "you don't own this post")
 	}
 
-	newPost, err := queries.UpdatePost(context.Background(), postgres_repo.UpdatePostParams{
+	contentHtml, toc, err := renderPostContent(req.Content)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error rendering post content: %+v", err))
+	}
+
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+
+	qtx := queries.WithTx(tx)
+
+	newPost, err := qtx.UpdatePost(context.Background(), postgres_repo.UpdatePostParams{
 		ID:               postID,
 		Title:            req.Title,
 		Content:          req.Content,
+		ContentHtml:      contentHtml,
+		Toc:              toc,
 		FeaturedImageUrl: sql.NullString{Valid: true, String: req.FeaturedImageUrl},
 	})
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error updating post: %+v", err))
 	}
 
+	if err := updatePostSlug(tx, &newPost); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error updating post slug: %+v", err))
+	}
+
+	if err := setPostTags(qtx, postID, tags); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error setting post tags: %+v", err))
+	}
+
+	if err := queuePostWebhookEvent(qtx, repo.WebhookEventPostUpdated, &newPost); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error queueing post webhook event: %+v", err))
+	}
+
+	if err := queuePostActivity(qtx, "Update", &newPost); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error queueing post activity: %+v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+
+	reactions, err := queries.GetPostReactions(context.Background(), postID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post reactions: %+v", err))
+	}
+
+	repoTags, err := queries.GetPostTags(context.Background(), postID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post tags: %+v", err))
+	}
+
+	var payload PostPayload
+	fillPostPayload(&payload, &newPost)
+	fillPostReactions(&payload, reactions)
+	fillPostTags(&payload, repoTags)
+
+	return c.Status(fiber.StatusOK).JSON(ApiResponse{
+		Payload: payload,
+	})
+}
+
+func HandlePublishPost(c *fiber.Ctx) error {
+	postID, err := uuid.Parse(c.Params("post_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	if exists, err := queries.CheckPost(context.Background(), postID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking post: %+v", err))
+	} else if !exists {
+		return fiber.NewError(fiber.StatusNotFound, "post not found")
+	}
+
+	userID := getUserIDFromContext(c)
+
+	if ok, err := queries.CheckUserOwnsPost(context.Background(), postgres_repo.CheckUserOwnsPostParams{
+		ID:     postID,
+		UserID: userID,
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking user owns post: %+v", err))
+	} else if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "you don't own this post")
+	}
+
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+
+	qtx := queries.WithTx(tx)
+
+	// the post is locked, so a concurrent publish (or the scheduler) publishing it first is seen here,
+	// and followers are notified once.
+	oldPost, err := qtx.GetPostForUpdate(context.Background(), postID)
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusNotFound, "post not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post: %+v", err))
+	}
+	if oldPost.Status == repo.PostStatusPublished {
+		return fiber.NewError(fiber.StatusConflict, "post is already published")
+	}
+
+	newPost, err := qtx.PublishPost(context.Background(), postID)
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusConflict, "post is already published")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error publishing post: %+v", err))
+	}
+
+	// followers are only notified the first time a post gets published
+	if !oldPost.PublishedAt.Valid {
+		if err := queueNewPostNotifications(qtx, &newPost); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error queueing new post notifications: %+v", err))
+		}
+	}
+
+	if err := queuePostWebhookEvent(qtx, repo.WebhookEventPostPublished, &newPost); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error queueing post webhook event: %+v", err))
+	}
+
+	// remote followers already have the post if it was published before
+	activityType := "Create"
+	if oldPost.PublishedAt.Valid {
+		activityType = "Update"
+	}
+	if err := queuePostActivity(qtx, activityType, &newPost); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error queueing post activity: %+v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+
+	reactions, err := queries.GetPostReactions(context.Background(), postID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post reactions: %+v", err))
+	}
+
+	repoTags, err := queries.GetPostTags(context.Background(), postID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post tags: %+v", err))
+	}
+
+	var payload PostPayload
+	fillPostPayload(&payload, &newPost)
+	fillPostReactions(&payload, reactions)
+	fillPostTags(&payload, repoTags)
+
+	return c.Status(fiber.StatusOK).JSON(ApiResponse{
+		Payload: payload,
+	})
+}
+
+func HandleUnlistPost(c *fiber.Ctx) error {
+	postID, err := uuid.Parse(c.Params("post_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	if exists, err := queries.CheckPost(context.Background(), postID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking post: %+v", err))
+	} else if !exists {
+		return fiber.NewError(fiber.StatusNotFound, "post not found")
+	}
+
+	userID := getUserIDFromContext(c)
+
+	if ok, err := queries.CheckUserOwnsPost(context.Background(), postgres_repo.CheckUserOwnsPostParams{
+		ID:     postID,
+		UserID: userID,
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking user owns post: %+v", err))
+	} else if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "you don't own this post")
+	}
+
+	oldPost, err := queries.GetPost(context.Background(), postID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post: %+v", err))
+	}
+	if oldPost.Status == repo.PostStatusUnlisted {
+		return fiber.NewError(fiber.StatusConflict, "post is already unlisted")
+	}
+
+	newPost, err := queries.UnlistPost(context.Background(), postID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error unlisting post: %+v", err))
+	}
+
 	reactions, err := queries.GetPostReactions(context.Background(), postID)
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post reactions: %+v", err))
 	}
 
+	repoTags, err := queries.GetPostTags(context.Background(), postID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post tags: %+v", err))
+	}
+
 	var payload PostPayload
 	fillPostPayload(&payload, &newPost)
 	fillPostReactions(&payload, reactions)
+	fillPostTags(&payload, repoTags)
 
 	return c.Status(fiber.StatusOK).JSON(ApiResponse{
 		Payload: payload,
@@ -167,14 +424,17 @@ func HandleViewPost(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
 	}
 
-	if exists, err := queries.CheckPost(context.Background(), postID); err != nil {
+	userID := getUserIDFromContext(c)
+
+	if visible, err := queries.CheckPostVisible(context.Background(), postgres_repo.CheckPostVisibleParams{
+		ID:     postID,
+		UserID: userID,
+	}); err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking post: %+v", err))
-	} else if !exists {
+	} else if !visible {
 		return fiber.NewError(fiber.StatusNotFound, "post not found")
 	}
 
-	userID := getUserIDFromContext(c)
-
 	if ok, err := queries.CheckUserOwnsPost(context.Background(), postgres_repo.CheckUserOwnsPostParams{
 		ID:     postID,
 		UserID: userID,
@@ -204,23 +464,55 @@ func HandleCreateComment(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
 	}
 
-	if exists, err := queries.CheckPost(context.Background(), postID); err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking post: %+v", err))
-	} else if !exists {
-		return fiber.NewError(fiber.StatusNotFound, "post not found")
+	post, err := queries.GetPost(context.Background(), postID)
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusNotFound, "post not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post: %+v", err))
 	}
 
 	userID := getUserIDFromContext(c)
 
-	comment, err := queries.CreateComment(context.Background(), postgres_repo.CreateCommentParams{
-		PostID:  postID,
-		UserID:  userID,
-		Content: req.Content,
+	if !isPostVisibleTo(&post, userID) {
+		return fiber.NewError(fiber.StatusNotFound, "post not found")
+	}
+
+	contentHtml, err := renderCommentContent(req.Content)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error rendering comment content: %+v", err))
+	}
+
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+
+	qtx := queries.WithTx(tx)
+
+	comment, err := qtx.CreateComment(context.Background(), postgres_repo.CreateCommentParams{
+		PostID:      postID,
+		UserID:      userID,
+		Content:     req.Content,
+		ContentHtml: contentHtml,
 	})
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating error: %+v", err))
 	}
 
+	if err := queueCommentNotifications(qtx, post.UserID, &comment); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error queueing comment notifications: %+v", err))
+	}
+
+	if err := queueCommentWebhookEvent(qtx, post.UserID, &comment); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error queueing comment webhook event: %+v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+
 	var payload CommentPayload
 	fillCommentPayload(&payload, &comment)
 
@@ -229,6 +521,80 @@ func HandleCreateComment(c *fiber.Ctx) error {
 	})
 }
 
+func HandleCreateReply(c *fiber.Ctx) error {
+	req := CommentCreateOrUpdateRequest{}
+	if err := parseAndValidateJsonBody(c, &req); err != nil {
+		return err
+	}
+
+	parentID, err := uuid.Parse(c.Params("comment_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	parent, err := queries.GetComment(context.Background(), parentID)
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusNotFound, "comment not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting comment: %+v", err))
+	}
+
+	post, err := queries.GetPost(context.Background(), parent.PostID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post: %+v", err))
+	}
+
+	userID := getUserIDFromContext(c)
+
+	if !isPostVisibleTo(&post, userID) {
+		return fiber.NewError(fiber.StatusNotFound, "comment not found")
+	}
+
+	contentHtml, err := renderCommentContent(req.Content)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error rendering reply content: %+v", err))
+	}
+
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+
+	qtx := queries.WithTx(tx)
+
+	reply, err := qtx.CreateReply(context.Background(), postgres_repo.CreateReplyParams{
+		PostID:      parent.PostID,
+		UserID:      userID,
+		ParentID:    uuid.NullUUID{Valid: true, UUID: parent.ID},
+		Content:     req.Content,
+		ContentHtml: contentHtml,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating reply: %+v", err))
+	}
+
+	if err := queueReplyNotifications(qtx, &parent, &reply); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error queueing reply notifications: %+v", err))
+	}
+
+	if err := queueCommentWebhookEvent(qtx, post.UserID, &reply); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error queueing comment webhook event: %+v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+
+	var payload CommentPayload
+	fillCommentPayload(&payload, &reply)
+
+	return c.Status(fiber.StatusCreated).JSON(ApiResponse{
+		Payload: payload,
+	})
+}
+
 func HandleUpdateComment(c *fiber.Ctx) error {
 	req := CommentCreateOrUpdateRequest{}
 	if err := parseAndValidateJsonBody(c, &req); err != nil {
@@ -257,9 +623,15 @@ func HandleUpdateComment(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusUnauthorized, "you don't own this comment")
 	}
 
+	contentHtml, err := renderCommentContent(req.Content)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error rendering comment content: %+v", err))
+	}
+
 	newComment, err := queries.UpdateComment(context.Background(), postgres_repo.UpdateCommentParams{
-		ID:      commentID,
-		Content: req.Content,
+		ID:          commentID,
+		Content:     req.Content,
+		ContentHtml: contentHtml,
 	})
 	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error updating comment: %+v", err))
@@ -310,9 +682,17 @@ func HandleReact(c *fiber.Ctx) error {
 	}
 	reactionKindName := c.Query("reaction_kind")
 
-	if exists, err := queries.CheckPost(context.Background(), postID); err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking post: %+v", err))
-	} else if !exists {
+	post, err := queries.GetPost(context.Background(), postID)
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusNotFound, "post not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post: %+v", err))
+	}
+
+	userID := getUserIDFromContext(c)
+
+	if !isPostVisibleTo(&post, userID) {
 		return fiber.NewError(fiber.StatusNotFound, "post not found")
 	}
 
@@ -324,9 +704,24 @@ func HandleReact(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting reaction kind id: %+v", err))
 	}
 
-	userID := getUserIDFromContext(c)
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
 
-	if err := queries.CreateReaction(context.Background(), postgres_repo.CreateReactionParams{
+	qtx := queries.WithTx(tx)
+
+	// the author is only notified of the first reaction of a user, not when they change it
+	alreadyReacted, err := qtx.CheckReaction(context.Background(), postgres_repo.CheckReactionParams{
+		PostID: postID,
+		UserID: userID,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking reaction: %+v", err))
+	}
+
+	if err := qtx.CreateReaction(context.Background(), postgres_repo.CreateReactionParams{
 		PostID: postID,
 		UserID: userID,
 		KindID: kindID,
@@ -334,6 +729,16 @@ func HandleReact(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating reaction: %+v", err))
 	}
 
+	if !alreadyReacted {
+		if err := queueReactionNotification(qtx, post.UserID, userID, postID); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error queueing reaction notification: %+v", err))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+
 	return c.Status(fiber.StatusCreated).SendString("reaction added successfully")
 }
 
@@ -369,14 +774,17 @@ func HandleAddToBookmarks(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
 	}
 
-	if exists, err := queries.CheckPost(context.Background(), postID); err != nil {
+	userID := getUserIDFromContext(c)
+
+	if visible, err := queries.CheckPostVisible(context.Background(), postgres_repo.CheckPostVisibleParams{
+		ID:     postID,
+		UserID: userID,
+	}); err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking post: %+v", err))
-	} else if !exists {
+	} else if !visible {
 		return fiber.NewError(fiber.StatusNotFound, "post not found")
 	}
 
-	userID := getUserIDFromContext(c)
-
 	if exists, err := queries.CheckBookmark(context.Background(), postgres_repo.CheckBookmarkParams{
 		PostID: postID,
 		UserID: userID,
@@ -475,6 +883,63 @@ func HandleGetAllUserPosts(c *fiber.Ctx) error {
 		fillPostPayload(&postPayload, &post)
 		payload = append(payload, postPayload)
 	}
+	if err := fillPostsTags(payload); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post tags: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(CursoredApiResponse{
+		Payload:    payload,
+		Cursor:     encodedResponseCursor,
+		HasMore:    hasMore,
+		TotalCount: len(payload),
+	})
+}
+
+func HandleGetAllDrafts(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit")
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	var requestCursor DraftsCursor
+	if err := decodeBase64AndUnmarshalJson(&requestCursor, c.Query("cursor")); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid cursor format")
+	}
+
+	drafts, err := queries.GetAllUserDrafts(context.Background(), postgres_repo.GetAllUserDraftsParams{
+		// filter
+		UserID: getUserIDFromContext(c),
+		// cursor
+		ID: requestCursor.ID,
+		// limit
+		Limit: int32(limit) + 1,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting drafts: %+v", err))
+	}
+
+	var encodedResponseCursor string
+	hasMore := limit < len(drafts)
+	if hasMore {
+		responseCursor := DraftsCursor{
+			ID: drafts[limit].ID,
+		}
+		encodedResponseCursor, err = marshalJsonAndEncodeBase64(responseCursor)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error encoding cursor: %+v", err))
+		}
+		drafts = drafts[:limit]
+	}
+
+	payload := make([]PostPayload, 0, len(drafts))
+	for _, draft := range drafts {
+		var postPayload PostPayload
+		fillPostPayload(&postPayload, &draft)
+		payload = append(payload, postPayload)
+	}
+	if err := fillPostsTags(payload); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post tags: %+v", err))
+	}
 
 	return c.Status(fiber.StatusOK).JSON(CursoredApiResponse{
 		Payload:    payload,
@@ -495,9 +960,18 @@ func HandleGetAllPosts(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid cursor format")
 	}
 
+	// comma separated tags, posts having any of them are matched
+	tagSlugs := []string{}
+	for _, tag := range strings.Split(c.Query("tags"), ",") {
+		if slug := utils.Slugify(tag); slug != "" {
+			tagSlugs = append(tagSlugs, slug)
+		}
+	}
+
 	posts, err := queries.GetAllPosts(context.Background(), postgres_repo.GetAllPostsParams{
 		// filter
 		SearchQuery: c.Query("search_query"),
+		Tags:        tagSlugs,
 		// cursor
 		ViewsCount: int32(requestCursor.ViewsCount),
 		ID:         requestCursor.ID,
@@ -528,6 +1002,63 @@ func HandleGetAllPosts(c *fiber.Ctx) error {
 		fillPostPayload(&postPayload, &post)
 		payload = append(payload, postPayload)
 	}
+	if err := fillPostsTags(payload); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post tags: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(CursoredApiResponse{
+		Payload:    payload,
+		Cursor:     encodedResponseCursor,
+		HasMore:    hasMore,
+		TotalCount: len(payload),
+	})
+}
+
+func HandleGetFeed(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit")
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	var requestCursor FeedCursor
+	if err := decodeBase64AndUnmarshalJson(&requestCursor, c.Query("cursor")); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid cursor format")
+	}
+
+	posts, err := queries.GetAllFeedPosts(context.Background(), postgres_repo.GetAllFeedPostsParams{
+		// filter
+		FollowerID: getUserIDFromContext(c),
+		// cursor
+		ID: requestCursor.ID,
+		// limit
+		Limit: int32(limit) + 1,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting feed posts: %+v", err))
+	}
+
+	var encodedResponseCursor string
+	hasMore := limit < len(posts)
+	if hasMore {
+		responseCursor := FeedCursor{
+			ID: posts[limit].ID,
+		}
+		encodedResponseCursor, err = marshalJsonAndEncodeBase64(responseCursor)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error encoding cursor: %+v", err))
+		}
+		posts = posts[:limit]
+	}
+
+	payload := make([]PostPayload, 0, len(posts))
+	for _, post := range posts {
+		var postPayload PostPayload
+		fillPostPayload(&postPayload, &post)
+		payload = append(payload, postPayload)
+	}
+	if err := fillPostsTags(payload); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post tags: %+v", err))
+	}
 
 	return c.Status(fiber.StatusOK).JSON(CursoredApiResponse{
 		Payload:    payload,
@@ -543,9 +1074,14 @@ func HandleGetAllPostComments(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
 	}
 
-	if exists, err := queries.CheckPost(context.Background(), postID); err != nil {
+	userID := getUserIDFromContext(c)
+
+	if visible, err := queries.CheckPostVisible(context.Background(), postgres_repo.CheckPostVisibleParams{
+		ID:     postID,
+		UserID: userID,
+	}); err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking post: %+v", err))
-	} else if !exists {
+	} else if !visible {
 		return fiber.NewError(fiber.StatusNotFound, "post not found")
 	}
 
@@ -599,6 +1135,79 @@ func HandleGetAllPostComments(c *fiber.Ctx) error {
 	})
 }
 
+func HandleGetAllCommentReplies(c *fiber.Ctx) error {
+	commentID, err := uuid.Parse(c.Params("comment_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	comment, err := queries.GetComment(context.Background(), commentID)
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusNotFound, "comment not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting comment: %+v", err))
+	}
+
+	post, err := queries.GetPost(context.Background(), comment.PostID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post: %+v", err))
+	}
+
+	if !isPostVisibleTo(&post, getUserIDFromContext(c)) {
+		return fiber.NewError(fiber.StatusNotFound, "comment not found")
+	}
+
+	limit := c.QueryInt("limit")
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	var requestCursor CommentsCursor
+	if err := decodeBase64AndUnmarshalJson(&requestCursor, c.Query("cursor")); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid cursor format")
+	}
+
+	replies, err := queries.GetAllCommentReplies(context.Background(), postgres_repo.GetAllCommentRepliesParams{
+		// filter
+		ParentID: uuid.NullUUID{Valid: true, UUID: commentID},
+		// cursor
+		ID: requestCursor.ID,
+		// limit
+		Limit: int32(limit) + 1,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting comment replies: %+v", err))
+	}
+
+	var encodedResponseCursor string
+	hasMore := limit < len(replies)
+	if hasMore {
+		responseCursor := CommentsCursor{
+			ID: replies[limit].ID,
+		}
+		encodedResponseCursor, err = marshalJsonAndEncodeBase64(responseCursor)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error encoding cursor: %+v", err))
+		}
+		replies = replies[:limit]
+	}
+
+	payload := make([]CommentPayload, 0, len(replies))
+	for _, reply := range replies {
+		var commentPayload CommentPayload
+		fillCommentPayload(&commentPayload, &reply)
+		payload = append(payload, commentPayload)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(CursoredApiResponse{
+		Payload:    payload,
+		Cursor:     encodedResponseCursor,
+		HasMore:    hasMore,
+		TotalCount: len(payload),
+	})
+}
+
 func HandleGetAllBookmarks(c *fiber.Ctx) error {
 	limit := c.QueryInt("limit")
 	if limit < 10 || limit > 100 {
@@ -641,6 +1250,9 @@ func HandleGetAllBookmarks(c *fiber.Ctx) error {
 		fillPostPayload(&postPayload, &bookmark)
 		payload = append(payload, postPayload)
 	}
+	if err := fillPostsTags(payload); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post tags: %+v", err))
+	}
 
 	return c.Status(fiber.StatusOK).JSON(CursoredApiResponse{
 		Payload:    payload,
@@ -649,3 +1261,9 @@ func HandleGetAllBookmarks(c *fiber.Ctx) error {
 		TotalCount: len(payload),
 	})
 }
+
+// isPostVisibleTo reports whether the user can interact with the post: drafts and scheduled posts
+// are only visible to their author.
+func isPostVisibleTo(post *postgres_repo.Post, userID uuid.UUID) bool {
+	return post.Status == repo.PostStatusPublished || post.Status == repo.PostStatusUnlisted || post.UserID == userID
+}
@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/assaidy/blogging_app/internal/db/postgres_db"
+	"github.com/assaidy/blogging_app/internal/repo"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/assaidy/blogging_app/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// parsePostIDAndCheckOwnership parses the `post_id` route param and ensures that the post exists
+// and is owned by the authenticated user. Returns the post ID or a fiber error.
+func parsePostIDAndCheckOwnership(c *fiber.Ctx) (uuid.UUID, error) {
+	postID, err := uuid.Parse(c.Params("post_id"))
+	if err != nil {
+		return uuid.Nil, fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	if exists, err := queries.CheckPost(context.Background(), postID); err != nil {
+		return uuid.Nil, fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking post: %+v", err))
+	} else if !exists {
+		return uuid.Nil, fiber.NewError(fiber.StatusNotFound, "post not found")
+	}
+
+	if ok, err := queries.CheckUserOwnsPost(context.Background(), postgres_repo.CheckUserOwnsPostParams{
+		ID:     postID,
+		UserID: getUserIDFromContext(c),
+	}); err != nil {
+		return uuid.Nil, fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking user owns post: %+v", err))
+	} else if !ok {
+		return uuid.Nil, fiber.NewError(fiber.StatusUnauthorized, "you don't own this post")
+	}
+
+	return postID, nil
+}
+
+// getPostRevision gets the revision with the given ID, making sure it belongs to the given post.
+func getPostRevision(postID, revisionID uuid.UUID) (postgres_repo.PostRevision, error) {
+	revision, err := queries.GetPostRevision(context.Background(), postgres_repo.GetPostRevisionParams{
+		ID:     revisionID,
+		PostID: postID,
+	})
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return revision, fiber.NewError(fiber.StatusNotFound, "revision not found")
+		}
+		return revision, fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting revision: %+v", err))
+	}
+	return revision, nil
+}
+
+func HandleGetAllPostRevisions(c *fiber.Ctx) error {
+	postID, err := parsePostIDAndCheckOwnership(c)
+	if err != nil {
+		return err
+	}
+
+	limit := c.QueryInt("limit")
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	var requestCursor RevisionsCursor
+	if err := decodeBase64AndUnmarshalJson(&requestCursor, c.Query("cursor")); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid cursor format")
+	}
+
+	revisions, err := queries.GetAllPostRevisions(context.Background(), postgres_repo.GetAllPostRevisionsParams{
+		// filter
+		PostID: postID,
+		// cursor
+		ID: requestCursor.ID,
+		// limit
+		Limit: int32(limit) + 1,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post revisions: %+v", err))
+	}
+
+	var encodedResponseCursor string
+	hasMore := limit < len(revisions)
+	if hasMore {
+		responseCursor := RevisionsCursor{
+			ID: revisions[limit].ID,
+		}
+		encodedResponseCursor, err = marshalJsonAndEncodeBase64(responseCursor)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error encoding cursor: %+v", err))
+		}
+		revisions = revisions[:limit]
+	}
+
+	payload := make([]RevisionPayload, 0, len(revisions))
+	for _, revision := range revisions {
+		var revisionPayload RevisionPayload
+		fillRevisionPayload(&revisionPayload, &revision)
+		payload = append(payload, revisionPayload)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(CursoredApiResponse{
+		Payload:    payload,
+		Cursor:     encodedResponseCursor,
+		HasMore:    hasMore,
+		TotalCount: len(payload),
+	})
+}
+
+func HandleGetPostRevision(c *fiber.Ctx) error {
+	postID, err := parsePostIDAndCheckOwnership(c)
+	if err != nil {
+		return err
+	}
+
+	revisionID, err := uuid.Parse(c.Params("revision_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	revision, err := getPostRevision(postID, revisionID)
+	if err != nil {
+		return err
+	}
+
+	var payload RevisionPayload
+	fillRevisionPayload(&payload, &revision)
+
+	return c.Status(fiber.StatusOK).JSON(ApiResponse{
+		Payload: payload,
+	})
+}
+
+// HandleGetPostRevisionsDiff returns a line-level diff from the revision in the route to the one in the `to` query.
+func HandleGetPostRevisionsDiff(c *fiber.Ctx) error {
+	postID, err := parsePostIDAndCheckOwnership(c)
+	if err != nil {
+		return err
+	}
+
+	fromID, err := uuid.Parse(c.Params("revision_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+	toID, err := uuid.Parse(c.Query("to"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	from, err := getPostRevision(postID, fromID)
+	if err != nil {
+		return err
+	}
+	to, err := getPostRevision(postID, toID)
+	if err != nil {
+		return err
+	}
+
+	if utils.CountLines(from.Content) > utils.MaxDiffLines || utils.CountLines(to.Content) > utils.MaxDiffLines {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, fmt.Sprintf("can't diff revisions of more than %d lines", utils.MaxDiffLines))
+	}
+
+	payload := RevisionDiffPayload{
+		FromID: from.ID,
+		ToID:   to.ID,
+	}
+	fillDiffLinesPayload(&payload.Title, utils.DiffLines(from.Title, to.Title))
+	fillDiffLinesPayload(&payload.Content, utils.DiffLines(from.Content, to.Content))
+	fillDiffLinesPayload(&payload.FeaturedImageUrl, utils.DiffLines(from.FeaturedImageUrl.String, to.FeaturedImageUrl.String))
+
+	return c.Status(fiber.StatusOK).JSON(ApiResponse{
+		Payload: payload,
+	})
+}
+
+// HandleRestorePostRevision sets the post content back to an older revision.
+// NOTE: restoring is an update like any other, so it's recorded as a new revision.
+func HandleRestorePostRevision(c *fiber.Ctx) error {
+	postID, err := parsePostIDAndCheckOwnership(c)
+	if err != nil {
+		return err
+	}
+
+	revisionID, err := uuid.Parse(c.Params("revision_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	revision, err := getPostRevision(postID, revisionID)
+	if err != nil {
+		return err
+	}
+
+	contentHtml, toc, err := renderPostContent(revision.Content)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error rendering post content: %+v", err))
+	}
+
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	newPost, err := qtx.UpdatePost(context.Background(), postgres_repo.UpdatePostParams{
+		ID:               postID,
+		Title:            revision.Title,
+		Content:          revision.Content,
+		ContentHtml:      contentHtml,
+		Toc:              toc,
+		FeaturedImageUrl: revision.FeaturedImageUrl,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error restoring post revision: %+v", err))
+	}
+
+	if err := updatePostSlug(tx, &newPost); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error updating post slug: %+v", err))
+	}
+
+	if err := queuePostWebhookEvent(qtx, repo.WebhookEventPostUpdated, &newPost); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error queueing post webhook event: %+v", err))
+	}
+
+	if err := queuePostActivity(qtx, "Update", &newPost); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error queueing post activity: %+v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+
+	reactions, err := queries.GetPostReactions(context.Background(), postID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post reactions: %+v", err))
+	}
+
+	repoTags, err := queries.GetPostTags(context.Background(), postID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post tags: %+v", err))
+	}
+
+	var payload PostPayload
+	fillPostPayload(&payload, &newPost)
+	fillPostReactions(&payload, reactions)
+	fillPostTags(&payload, repoTags)
+
+	return c.Status(fiber.StatusOK).JSON(ApiResponse{
+		Payload: payload,
+	})
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/db/postgres_db"
+	"github.com/assaidy/blogging_app/internal/repo"
+)
+
+var (
+	schedulerStopChan = make(chan struct{})
+	schedulerWg       sync.WaitGroup
+)
+
+const (
+	schedulerInterval  = 30 * time.Second
+	schedulerBatchSize = 100
+)
+
+// StartPostScheduler starts a background loop that publishes scheduled posts once their publish time is due.
+// NOTE: with prefork every child process runs its own scheduler. Due posts are claimed using
+// `FOR UPDATE SKIP LOCKED`, so each post is published (and its followers notified) exactly once.
+func StartPostScheduler() {
+	schedulerWg.Add(1)
+
+	go func() {
+		defer schedulerWg.Done()
+
+		ticker := time.NewTicker(schedulerInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-schedulerStopChan:
+				return
+			case <-ticker.C:
+				if err := publishDuePosts(); err != nil {
+					slog.Error("error publishing scheduled posts", "err", err)
+				}
+			}
+		}
+	}()
+}
+
+func StopPostScheduler() {
+	close(schedulerStopChan)
+	schedulerWg.Wait()
+}
+
+// publishDuePosts publishes due scheduled posts in batches until none are left.
+func publishDuePosts() error {
+	for {
+		published, err := publishDuePostsBatch()
+		if err != nil {
+			return err
+		}
+		if published < schedulerBatchSize {
+			return nil
+		}
+	}
+}
+
+// publishDuePostsBatch claims and publishes up to schedulerBatchSize due posts in a single transaction,
+// queueing their new post notifications, webhook events and activities. Returns the number of published posts.
+// Rows locked by other processes are skipped, and will no longer be due once those processes commit.
+func publishDuePostsBatch() (int, error) {
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := queries.WithTx(tx)
+
+	duePosts, err := qtx.GetDueScheduledPosts(context.Background(), schedulerBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("error getting due posts: %w", err)
+	}
+
+	for _, post := range duePosts {
+		newPost, err := qtx.PublishPost(context.Background(), post.ID)
+		if err != nil {
+			return 0, fmt.Errorf("error publishing post: %w", err)
+		}
+		if err := queueNewPostNotifications(qtx, &newPost); err != nil {
+			return 0, fmt.Errorf("error queueing new post notifications: %w", err)
+		}
+		if err := queuePostWebhookEvent(qtx, repo.WebhookEventPostPublished, &newPost); err != nil {
+			return 0, fmt.Errorf("error queueing post webhook event: %w", err)
+		}
+		if err := queuePostActivity(qtx, "Create", &newPost); err != nil {
+			return 0, fmt.Errorf("error queueing post activity: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return len(duePosts), nil
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/db/postgres_db"
+	"github.com/assaidy/blogging_app/internal/repo"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/assaidy/blogging_app/internal/sitemap"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	sitemapStopChan = make(chan struct{})
+	sitemapWg       sync.WaitGroup
+)
+
+const (
+	sitemapSyncInterval = 10 * time.Minute
+	// posts updated by transactions that started before the last run, but committed after it, have
+	// an updated_at older than the last run. Going back a bit picks them up. Upserts are idempotent.
+	sitemapSyncOverlap  = time.Minute
+	sitemapCacheControl = "public, max-age=3600"
+)
+
+// StartSitemapGenerator starts a background loop that keeps the sitemap urls up to date, applying
+// the changes to posts and users since its last run.
+// NOTE: with prefork every child process runs its own loop. A run locks the sync state using
+// `FOR UPDATE SKIP LOCKED`, so only one process runs at a time, and the others skip.
+func StartSitemapGenerator() {
+	sitemapWg.Add(1)
+
+	go func() {
+		defer sitemapWg.Done()
+
+		ticker := time.NewTicker(sitemapSyncInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := syncSitemap(); err != nil {
+				slog.Error("error syncing sitemap", "err", err)
+			}
+
+			select {
+			case <-sitemapStopChan:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func StopSitemapGenerator() {
+	close(sitemapStopChan)
+	sitemapWg.Wait()
+}
+
+func syncSitemap() error {
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	syncedAt, err := qtx.LockSitemapSync(context.Background())
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return nil // another process is syncing
+		}
+		return fmt.Errorf("error locking sitemap sync: %w", err)
+	}
+	since := syncedAt.Add(-sitemapSyncOverlap)
+
+	upsertedPosts, err := qtx.UpsertSitemapPostURLs(context.Background(), since)
+	if err != nil {
+		return fmt.Errorf("error upserting post urls: %w", err)
+	}
+	deletedPosts, err := qtx.DeleteSitemapUnpublishedPostURLs(context.Background(), since)
+	if err != nil {
+		return fmt.Errorf("error deleting unpublished post urls: %w", err)
+	}
+	upsertedUsers, err := qtx.UpsertSitemapUserURLs(context.Background(), since)
+	if err != nil {
+		return fmt.Errorf("error upserting user urls: %w", err)
+	}
+	if err := qtx.UpdateSitemapSync(context.Background()); err != nil {
+		return fmt.Errorf("error updating sitemap sync: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	slog.Debug("sitemap synced", "upsertedPosts", upsertedPosts, "deletedPosts", deletedPosts, "upsertedUsers", upsertedUsers)
+	return nil
+}
+
+// HandleGetSitemap serves the sitemap of all public posts and user profiles. Past sitemap.MaxURLs
+// urls, it serves a sitemap index instead, listing the pages at /sitemaps/<page>.xml.
+func HandleGetSitemap(c *fiber.Ctx) error {
+	fresh, err := setSitemapCacheHeaders(c)
+	if err != nil {
+		return err
+	}
+	if fresh {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	count, err := queries.GetSitemapURLsCount(context.Background())
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting sitemap urls count: %+v", err))
+	}
+	if count <= sitemap.MaxURLs {
+		return sendSitemapPage(c, 1)
+	}
+
+	pages, err := queries.GetSitemapPages(context.Background(), sitemap.MaxURLs)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting sitemap pages: %+v", err))
+	}
+	sitemaps := make([]sitemap.URL, 0, len(pages))
+	for _, page := range pages {
+		sitemaps = append(sitemaps, sitemap.URL{
+			Loc:     os.Getenv("APP_BASE_URL") + "/sitemaps/" + strconv.FormatInt(page.Page+1, 10) + ".xml",
+			LastMod: page.Lastmod,
+		})
+	}
+
+	body, err := sitemap.EncodeIndex(sitemaps)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error encoding sitemap index: %+v", err))
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationXMLCharsetUTF8)
+	return c.Send(body)
+}
+
+// HandleGetSitemapPage serves a page of the sitemap, as listed by the sitemap index. Pages start at 1.
+func HandleGetSitemapPage(c *fiber.Ctx) error {
+	page, err := c.ParamsInt("page")
+	if err != nil || page < 1 {
+		return fiber.NewError(fiber.StatusNotFound, "sitemap not found")
+	}
+
+	fresh, err := setSitemapCacheHeaders(c)
+	if err != nil {
+		return err
+	}
+	if fresh {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	return sendSitemapPage(c, page)
+}
+
+// HandleGetRobots serves the robots.txt of the site, pointing crawlers to the sitemap.
+func HandleGetRobots(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, sitemapCacheControl)
+	return c.SendString("User-agent: *\n" +
+		"Disallow: /api/v1/auth/\n" +
+		"\n" +
+		"Sitemap: " + os.Getenv("APP_BASE_URL") + "/sitemap.xml\n")
+}
+
+// setSitemapCacheHeaders sets the time of the last sync as the last modification time of the sitemap,
+// and reports whether the client's cached copy is still fresh.
+func setSitemapCacheHeaders(c *fiber.Ctx) (bool, error) {
+	syncedAt, err := queries.GetSitemapSyncedAt(context.Background())
+	if err != nil {
+		return false, fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting sitemap sync time: %+v", err))
+	}
+	c.Set(fiber.HeaderLastModified, syncedAt.UTC().Format(http.TimeFormat))
+	c.Set(fiber.HeaderCacheControl, sitemapCacheControl)
+	return c.Fresh(), nil
+}
+
+func sendSitemapPage(c *fiber.Ctx, page int) error {
+	rows, err := queries.GetSitemapURLs(context.Background(), postgres_repo.GetSitemapURLsParams{
+		Limit:  sitemap.MaxURLs,
+		Offset: int32((page - 1) * sitemap.MaxURLs),
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting sitemap urls: %+v", err))
+	}
+	if len(rows) == 0 && page > 1 {
+		return fiber.NewError(fiber.StatusNotFound, "sitemap not found")
+	}
+
+	urls := make([]sitemap.URL, 0, len(rows))
+	for _, row := range rows {
+		urls = append(urls, sitemap.URL{
+			Loc:     sitemapURLLoc(row.Username, row.Slug),
+			LastMod: row.Lastmod,
+		})
+	}
+
+	body, err := sitemap.EncodeURLSet(urls)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error encoding sitemap: %+v", err))
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationXMLCharsetUTF8)
+	return c.Send(body)
+}
+
+// sitemapURLLoc is the url of a post, or of the profile of the user for a url without a post.
+func sitemapURLLoc(username string, slug sql.NullString) string {
+	if slug.Valid {
+		return postURL(username, slug.String)
+	}
+	return userURL(username)
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/assaidy/blogging_app/internal/repo"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/assaidy/blogging_app/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const maxSlugLength = 200
+
+// newPostSlug generates a slug for a post from its title, that's not used by any other post of the author.
+// postID is the post the slug is for, or uuid.Nil for a post that's not created yet.
+func newPostSlug(q *postgres_repo.Queries, userID, postID uuid.UUID, title string) (string, error) {
+	baseSlug := utils.Slugify(title)
+	if runes := []rune(baseSlug); len(runes) > maxSlugLength {
+		baseSlug = strings.TrimRight(string(runes[:maxSlugLength]), "-")
+	}
+	if baseSlug == "" {
+		baseSlug = "post"
+	}
+
+	takenSlugs, err := q.GetTakenSlugs(context.Background(), postgres_repo.GetTakenSlugsParams{
+		UserID:   userID,
+		PostID:   postID,
+		BaseSlug: baseSlug,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return utils.UniqueSlug(baseSlug, takenSlugs), nil
+}
+
+// maxSlugAttempts is how many slugs are tried for a post before giving up, when concurrent requests
+// keep taking them.
+const maxSlugAttempts = 5
+
+// withPostSlug generates a slug for a post with newPostSlug, and calls save to store it in tx.
+// A concurrent request may take the slug after it's generated, so when save fails with a unique violation,
+// what it did is rolled back and it's called again with the next free slug.
+func withPostSlug(tx *sql.Tx, userID, postID uuid.UUID, title string, save func(slug string) error) error {
+	qtx := queries.WithTx(tx)
+	for attempt := 1; ; attempt++ {
+		slug, err := newPostSlug(qtx, userID, postID, title)
+		if err != nil {
+			return fmt.Errorf("error generating post slug: %w", err)
+		}
+
+		// a failed statement aborts the whole transaction, so failures are rolled back to here.
+		if _, err := tx.ExecContext(context.Background(), "SAVEPOINT post_slug"); err != nil {
+			return fmt.Errorf("error creating savepoint: %w", err)
+		}
+		err = save(slug)
+		if err == nil || !repo.IsUniqueViolationError(err) || attempt == maxSlugAttempts {
+			return err
+		}
+		if _, err := tx.ExecContext(context.Background(), "ROLLBACK TO SAVEPOINT post_slug"); err != nil {
+			return fmt.Errorf("error rolling back to savepoint: %w", err)
+		}
+	}
+}
+
+// updatePostSlug regenerates the slug of the post from its title, keeping the old one in its slugs history.
+// It updates post.Slug in place.
+func updatePostSlug(tx *sql.Tx, post *postgres_repo.Post) error {
+	qtx := queries.WithTx(tx)
+	return withPostSlug(tx, post.UserID, post.ID, post.Title, func(slug string) error {
+		if slug == post.Slug {
+			return nil
+		}
+
+		if err := qtx.CreatePostSlug(context.Background(), postgres_repo.CreatePostSlugParams{
+			PostID: post.ID,
+			UserID: post.UserID,
+			Slug:   slug,
+		}); err != nil {
+			return err
+		}
+		if err := qtx.UpdatePostSlug(context.Background(), postgres_repo.UpdatePostSlugParams{
+			Slug: slug,
+			ID:   post.ID,
+		}); err != nil {
+			return err
+		}
+
+		post.Slug = slug
+		return nil
+	})
+}
+
+// HandleGetPostBySlug gets a post by its author's username and its slug.
+// If the slug is an old one, it responds with 301 and the current post url in the Location header,
+// along with the post itself.
+func HandleGetPostBySlug(c *fiber.Ctx) error {
+	username := c.Params("username")
+	// slugs may have non ASCII letters, which clients send percent-encoded
+	slug, err := url.PathUnescape(c.Params("slug"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid slug format")
+	}
+
+	post, err := queries.GetPostBySlug(context.Background(), postgres_repo.GetPostBySlugParams{
+		Username: username,
+		Slug:     slug,
+	})
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusNotFound, "post not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post: %+v", err))
+	}
+	// unpublished posts are only visible to their author through the drafts listing
+	if post.Status == repo.PostStatusDraft || post.Status == repo.PostStatusScheduled {
+		return fiber.NewError(fiber.StatusNotFound, "post not found")
+	}
+
+	reactions, err := queries.GetPostReactions(context.Background(), post.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post reactions: %+v", err))
+	}
+
+	repoTags, err := queries.GetPostTags(context.Background(), post.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post tags: %+v", err))
+	}
+
+	var payload PostPayload
+	fillPostPayload(&payload, &post)
+	fillPostReactions(&payload, reactions)
+	fillPostTags(&payload, repoTags)
+
+	status := fiber.StatusOK
+	if post.Slug != slug {
+		status = fiber.StatusMovedPermanently
+		c.Location(fmt.Sprintf("/api/v1/users/username/%s/posts/%s", url.PathEscape(username), url.PathEscape(post.Slug)))
+	}
+
+	return c.Status(status).JSON(ApiResponse{
+		Payload: payload,
+	})
+}
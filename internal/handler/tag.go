@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/assaidy/blogging_app/internal/repo"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/assaidy/blogging_app/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// parseTags turns the given tag names into tags to be stored, slugifying them and dropping duplicates.
+// Returns a fiber error if a tag name has no letters or digits to build a slug from.
+func parseTags(names []string) ([]postgres_repo.UpsertTagParams, error) {
+	tags := make([]postgres_repo.UpsertTagParams, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		slug := utils.Slugify(name)
+		if slug == "" {
+			return nil, fiber.NewError(fiber.StatusUnprocessableEntity, fmt.Sprintf("invalid tag: %q", name))
+		}
+		if seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		tags = append(tags, postgres_repo.UpsertTagParams{Slug: slug, Name: name})
+	}
+	return tags, nil
+}
+
+// setPostTags replaces the tags of a post with the given ones, creating the tags that don't exist yet.
+func setPostTags(q *postgres_repo.Queries, postID uuid.UUID, tags []postgres_repo.UpsertTagParams) error {
+	if err := q.DeletePostTags(context.Background(), postID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		tagID, err := q.UpsertTag(context.Background(), tag)
+		if err != nil {
+			return err
+		}
+		if err := q.CreatePostTag(context.Background(), postgres_repo.CreatePostTagParams{
+			PostID: postID,
+			TagID:  tagID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillPostsTags fills the tags of listed posts, getting them with a single query.
+func fillPostsTags(postPayloads []PostPayload) error {
+	postIDs := make([]uuid.UUID, 0, len(postPayloads))
+	postIndexes := make(map[uuid.UUID]int, len(postPayloads))
+	for i := range postPayloads {
+		postPayloads[i].Tags = []PostPayloadTag{}
+		postIDs = append(postIDs, postPayloads[i].ID)
+		postIndexes[postPayloads[i].ID] = i
+	}
+
+	repoTags, err := queries.GetTagsForPosts(context.Background(), postIDs)
+	if err != nil {
+		return err
+	}
+	for _, tag := range repoTags {
+		i := postIndexes[tag.PostID]
+		postPayloads[i].Tags = append(postPayloads[i].Tags, PostPayloadTag{
+			Slug: tag.Slug,
+			Name: tag.Name,
+		})
+	}
+	return nil
+}
+
+func HandleGetPopularTags(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit")
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	tags, err := queries.GetPopularTags(context.Background(), int32(limit))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting popular tags: %+v", err))
+	}
+
+	payload := make([]TagPayload, 0, len(tags))
+	for _, tag := range tags {
+		var tagPayload TagPayload
+		fillTagPayload(&tagPayload, &tag)
+		payload = append(payload, tagPayload)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ApiResponse{
+		Payload: payload,
+	})
+}
+
+func HandleGetAllTagPosts(c *fiber.Ctx) error {
+	tag, err := queries.GetTagBySlug(context.Background(), c.Params("slug"))
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusNotFound, "tag not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting tag: %+v", err))
+	}
+
+	limit := c.QueryInt("limit")
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	var requestCursor TagPostsCursor
+	if err := decodeBase64AndUnmarshalJson(&requestCursor, c.Query("cursor")); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid cursor format")
+	}
+
+	posts, err := queries.GetAllTagPosts(context.Background(), postgres_repo.GetAllTagPostsParams{
+		// filter
+		TagID: tag.ID,
+		// cursor
+		ID: requestCursor.ID,
+		// limit
+		Limit: int32(limit) + 1,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting tag posts: %+v", err))
+	}
+
+	var encodedResponseCursor string
+	hasMore := limit < len(posts)
+	if hasMore {
+		responseCursor := TagPostsCursor{
+			ID: posts[limit].ID,
+		}
+		encodedResponseCursor, err = marshalJsonAndEncodeBase64(responseCursor)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error encoding cursor: %+v", err))
+		}
+		posts = posts[:limit]
+	}
+
+	payload := make([]PostPayload, 0, len(posts))
+	for _, post := range posts {
+		var postPayload PostPayload
+		fillPostPayload(&postPayload, &post)
+		payload = append(payload, postPayload)
+	}
+	if err := fillPostsTags(payload); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting post tags: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(CursoredApiResponse{
+		Payload:    payload,
+		Cursor:     encodedResponseCursor,
+		HasMore:    hasMore,
+		TotalCount: len(payload),
+	})
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/assaidy/blogging_app/internal/repo"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func HandleSubscribeToTopic(c *fiber.Ctx) error {
+	req := TopicSubscribeRequest{}
+	if err := parseAndValidateJsonBody(c, &req); err != nil {
+		return err
+	}
+
+	// postgres rejects a malformed tsquery, and a query made only of stop words has no nodes and would never match.
+	if nodesCount, err := queries.GetTsQueryNodesCount(context.Background(), postgres_repo.GetTsQueryNodesCountParams{
+		IsTsquery: req.IsTsQuery,
+		Query:     req.Query,
+	}); err != nil {
+		if repo.IsInvalidInputError(err) {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, "invalid topic query")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking topic query: %+v", err))
+	} else if nodesCount == 0 {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "topic query has no searchable words")
+	}
+
+	userID := getUserIDFromContext(c)
+
+	if exists, err := queries.CheckTopicSubscription(context.Background(), postgres_repo.CheckTopicSubscriptionParams{
+		UserID:    userID,
+		Query:     req.Query,
+		IsTsquery: req.IsTsQuery,
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking topic subscription: %+v", err))
+	} else if exists {
+		return fiber.NewError(fiber.StatusConflict, "already subscribed to this topic")
+	}
+
+	topic, err := queries.CreateTopicSubscription(context.Background(), postgres_repo.CreateTopicSubscriptionParams{
+		UserID:    userID,
+		Query:     req.Query,
+		IsTsquery: req.IsTsQuery,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating topic subscription: %+v", err))
+	}
+
+	var payload TopicPayload
+	fillTopicPayload(&payload, &topic)
+
+	return c.Status(fiber.StatusCreated).JSON(ApiResponse{
+		Payload: payload,
+	})
+}
+
+func HandleGetAllTopics(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit")
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	var requestCursor TopicsCursor
+	if err := decodeBase64AndUnmarshalJson(&requestCursor, c.Query("cursor")); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid cursor format")
+	}
+
+	topics, err := queries.GetAllUserTopicSubscriptions(context.Background(), postgres_repo.GetAllUserTopicSubscriptionsParams{
+		// filter
+		UserID: getUserIDFromContext(c),
+		// cursor
+		ID: requestCursor.ID,
+		// limit
+		Limit: int32(limit) + 1,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting topic subscriptions: %+v", err))
+	}
+
+	var encodedResponseCursor string
+	hasMore := limit < len(topics)
+	if hasMore {
+		responseCursor := TopicsCursor{
+			ID: topics[limit].ID,
+		}
+		encodedResponseCursor, err = marshalJsonAndEncodeBase64(responseCursor)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error encoding cursor: %+v", err))
+		}
+		topics = topics[:limit]
+	}
+
+	payload := make([]TopicPayload, 0, len(topics))
+	for _, topic := range topics {
+		var topicPayload TopicPayload
+		fillTopicPayload(&topicPayload, &topic)
+		payload = append(payload, topicPayload)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(CursoredApiResponse{
+		Payload:    payload,
+		Cursor:     encodedResponseCursor,
+		HasMore:    hasMore,
+		TotalCount: len(payload),
+	})
+}
+
+func HandleUnsubscribeFromTopic(c *fiber.Ctx) error {
+	topicID, err := uuid.Parse(c.Params("topic_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	if owns, err := queries.CheckUserOwnsTopicSubscription(context.Background(), postgres_repo.CheckUserOwnsTopicSubscriptionParams{
+		ID:     topicID,
+		UserID: getUserIDFromContext(c),
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking topic subscription: %+v", err))
+	} else if !owns {
+		return fiber.NewError(fiber.StatusNotFound, "topic subscription not found")
+	}
+
+	if err := queries.DeleteTopicSubscription(context.Background(), topicID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting topic subscription: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).SendString("unsubscribed from topic successfully")
+}
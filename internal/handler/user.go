@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 
+	"github.com/assaidy/blogging_app/internal/db/postgres_db"
+	"github.com/assaidy/blogging_app/internal/middleware"
 	"github.com/assaidy/blogging_app/internal/repo"
 	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
 	"github.com/assaidy/blogging_app/internal/utils"
@@ -86,13 +88,45 @@ func HandleUpdateUser(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error hashing password: %+v", err))
 	}
 
-	newUser, err := queries.UpdateUser(context.Background(), postgres_repo.UpdateUserParams{
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	newUser, err := qtx.UpdateUser(context.Background(), postgres_repo.UpdateUserParams{
 		ID:              userID,
 		Name:            req.Name,
 		Username:        req.Username,
 		HashedPassword:  newHashedPassword,
 		ProfileImageUrl: sql.NullString{Valid: true, String: req.ProfileImageUrl},
 	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error updating user: %+v", err))
+	}
+
+	// a password change ends the other sessions, and revokes the access tokens issued so far,
+	// including the current one, which can be refreshed with the refresh token of the session.
+	passwordChanged := req.NewPassword != req.OldPassword
+	if passwordChanged {
+		if err := qtx.DeleteOtherUserSessions(context.Background(), postgres_repo.DeleteOtherUserSessionsParams{
+			UserID: userID,
+			ID:     getSessionIDFromContext(c),
+		}); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting other sessions: %+v", err))
+		}
+		if err := qtx.UpdateUserTokensValidAfter(context.Background(), userID); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error revoking access tokens: %+v", err))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
+	}
+	if passwordChanged {
+		middleware.InvalidateAccessTokens(userID)
+	}
 
 	var userPayload UserPayload
 	fillUserPayload(&userPayload, &newUser)
@@ -138,17 +172,31 @@ func HandleFollow(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusConflict, "user is already followed")
 	}
 
-	if err := queries.CreateFollow(context.Background(), postgres_repo.CreateFollowParams{
+	tx, err := postgres_db.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error starting transaction: %+v", err))
+	}
+	defer tx.Rollback()
+
+	qtx := queries.WithTx(tx)
+
+	if err := qtx.CreateFollow(context.Background(), postgres_repo.CreateFollowParams{
 		FollowerID: userID,
 		FollowedID: followedID,
 	}); err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating follow: %+v", err))
 	}
 
-	notificationChan <- postgres_repo.Notification{
-		KindID:   repo.NotificationKindNewFollower,
-		UserID:   followedID,
-		SenderID: uuid.NullUUID{Valid: true, UUID: userID},
+	if err := queueFollowNotification(qtx, userID, followedID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error queueing follow notification: %+v", err))
+	}
+
+	if err := queueFollowWebhookEvent(qtx, userID, followedID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error queueing follow webhook event: %+v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error committing transaction: %+v", err))
 	}
 
 	return c.Status(fiber.StatusOK).SendString("user was followed successfully")
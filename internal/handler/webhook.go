@@ -0,0 +1,451 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/repo"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/assaidy/blogging_app/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var (
+	webhookStopChan = make(chan struct{})
+	webhookWg       sync.WaitGroup
+	webhookClient   = newWebhookClient()
+)
+
+const (
+	numWebhookWorkers           = 5
+	webhookPollInterval         = time.Second
+	webhookRequestTimeout       = 10 * time.Second
+	webhookDeliveryLease        = time.Minute // must be longer than webhookRequestTimeout
+	maxWebhookDeliveryAttempts  = 10
+	webhookRetryBaseDelay       = 30 * time.Second
+	webhookRetryMaxDelay        = 6 * time.Hour
+	maxWebhookResponseBodyBytes = 64 << 10
+	maxWebhookErrorLength       = 1000
+)
+
+// newWebhookClient returns the client sending the webhooks. The URLs are given by users, so it refuses
+// internal addresses, and doesn't follow redirects, which could lead to them too.
+func newWebhookClient() *http.Client {
+	client := utils.NewPublicHTTPClient(webhookRequestTimeout)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return client
+}
+
+// StartWebhookWorkers starts workers sending the pending webhook deliveries.
+// NOTE: with prefork every child process runs its own workers. Deliveries are claimed with a lease
+// using `FOR UPDATE SKIP LOCKED`, so each delivery is sent by a single worker at a time.
+func StartWebhookWorkers() {
+	for range numWebhookWorkers {
+		webhookWg.Add(1)
+
+		go func() {
+			defer webhookWg.Done()
+
+			ticker := time.NewTicker(webhookPollInterval)
+			defer ticker.Stop()
+
+			for {
+				// send due deliveries until none are left, then wait for the next poll
+				for {
+					sent, err := sendNextWebhookDelivery()
+					if err != nil {
+						slog.Error("error sending webhook delivery", "err", err)
+						break
+					}
+					if !sent {
+						break
+					}
+					select {
+					case <-webhookStopChan:
+						return
+					default:
+					}
+				}
+
+				select {
+				case <-webhookStopChan:
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+func StopWebhookWorkers() {
+	close(webhookStopChan)
+	webhookWg.Wait()
+}
+
+// queueWebhookEvent queues a delivery of the event to every webhook of the user subscribed to it.
+// q should be bound to the transaction making the change the event is about.
+func queueWebhookEvent(q *postgres_repo.Queries, userID uuid.UUID, event string, data any) error {
+	payload, err := json.Marshal(WebhookEventPayload{
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+	return q.CreateWebhookDeliveries(context.Background(), postgres_repo.CreateWebhookDeliveriesParams{
+		Event:   event,
+		Payload: payload,
+		UserID:  userID,
+	})
+}
+
+// queuePostWebhookEvent queues a 'post.published' or 'post.updated' event for the webhooks of the author.
+func queuePostWebhookEvent(q *postgres_repo.Queries, event string, post *postgres_repo.Post) error {
+	data := WebhookPostData{
+		ID:        post.ID,
+		UserID:    post.UserID,
+		Title:     post.Title,
+		Slug:      post.Slug,
+		Status:    post.Status,
+		CreatedAt: post.CreatedAt,
+	}
+	if post.PublishedAt.Valid {
+		data.PublishedAt = &post.PublishedAt.Time
+	}
+	return queueWebhookEvent(q, post.UserID, event, data)
+}
+
+// queueCommentWebhookEvent queues a 'comment.created' event for the webhooks of the author of the post.
+func queueCommentWebhookEvent(q *postgres_repo.Queries, postAuthorID uuid.UUID, comment *postgres_repo.PostComment) error {
+	var data CommentPayload
+	fillCommentPayload(&data, comment)
+	return queueWebhookEvent(q, postAuthorID, repo.WebhookEventCommentCreated, data)
+}
+
+// queueFollowWebhookEvent queues a 'user.followed' event for the webhooks of the followed user.
+func queueFollowWebhookEvent(q *postgres_repo.Queries, followerID, followedID uuid.UUID) error {
+	return queueWebhookEvent(q, followedID, repo.WebhookEventUserFollowed, WebhookFollowData{
+		FollowerID: followerID,
+		FollowedID: followedID,
+	})
+}
+
+// sendNextWebhookDelivery claims the next due delivery and sends it. If sending fails, the delivery is retried
+// later with a backoff, until it's failed after maxWebhookDeliveryAttempts.
+// Returns false if there was no due delivery.
+// No transaction is held open while sending: the claim and the result are each recorded by a single statement.
+func sendNextWebhookDelivery() (bool, error) {
+	delivery, err := queries.ClaimWebhookDelivery(context.Background(), time.Now().Add(webhookDeliveryLease).UTC())
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error claiming webhook delivery: %w", err)
+	}
+
+	statusCode, sendErr := sendWebhook(&delivery)
+	lastStatusCode := sql.NullInt32{Valid: statusCode != 0, Int32: int32(statusCode)}
+
+	if sendErr == nil {
+		err = queries.MarkWebhookDeliverySucceeded(context.Background(), postgres_repo.MarkWebhookDeliverySucceededParams{
+			LastStatusCode: lastStatusCode,
+			ID:             delivery.ID,
+		})
+	} else {
+		err = failWebhookDelivery(queries, &delivery, lastStatusCode, sendErr)
+	}
+	if err != nil {
+		return false, fmt.Errorf("error recording webhook delivery result: %w", err)
+	}
+
+	return true, nil
+}
+
+// sendWebhook posts the payload of the delivery to the webhook, signed with its secret. Any 2xx response
+// is a success. Returns the response status code, or 0 if there was no response.
+func sendWebhook(delivery *postgres_repo.ClaimWebhookDeliveryRow) (int, error) {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "blogging-app-webhooks")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", delivery.ID.String())
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", utils.SignWebhookPayload(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// drain (part of) the body, so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxWebhookResponseBodyBytes))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func failWebhookDelivery(q *postgres_repo.Queries, delivery *postgres_repo.ClaimWebhookDeliveryRow, lastStatusCode sql.NullInt32, sendErr error) error {
+	attempts := int(delivery.Attempts) + 1
+	lastError := sendErr.Error()
+	if len(lastError) > maxWebhookErrorLength {
+		lastError = lastError[:maxWebhookErrorLength]
+	}
+
+	if attempts >= maxWebhookDeliveryAttempts {
+		slog.Warn("webhook delivery failed", "err", sendErr, "deliveryID", delivery.ID, "attempts", attempts)
+		return q.FailWebhookDelivery(context.Background(), postgres_repo.FailWebhookDeliveryParams{
+			LastStatusCode: lastStatusCode,
+			LastError:      sql.NullString{Valid: true, String: lastError},
+			ID:             delivery.ID,
+		})
+	}
+
+	delay := utils.Backoff(attempts, webhookRetryBaseDelay, webhookRetryMaxDelay)
+	return q.RetryWebhookDelivery(context.Background(), postgres_repo.RetryWebhookDeliveryParams{
+		// the column has no time zone, so store it as UTC like NOW() does.
+		NextAttemptAt:  time.Now().Add(delay).UTC(),
+		LastStatusCode: lastStatusCode,
+		LastError:      sql.NullString{Valid: true, String: lastError},
+		ID:             delivery.ID,
+	})
+}
+
+func HandleCreateWebhook(c *fiber.Ctx) error {
+	req := WebhookCreateRequest{}
+	if err := parseAndValidateJsonBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := utils.CheckPublicURL(context.Background(), req.URL); err != nil {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, fmt.Sprintf("invalid webhook url: %v", err))
+	}
+
+	secret, err := utils.GenerateWebhookSecret()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error generating webhook secret: %+v", err))
+	}
+
+	webhook, err := queries.CreateWebhook(context.Background(), postgres_repo.CreateWebhookParams{
+		UserID: getUserIDFromContext(c),
+		Url:    req.URL,
+		Secret: secret,
+		Events: req.Events,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating webhook: %+v", err))
+	}
+
+	var payload WebhookPayload
+	fillWebhookPayload(&payload, &webhook)
+	// the secret is only shown once, for the receiver to verify the signatures
+	payload.Secret = webhook.Secret
+
+	return c.Status(fiber.StatusCreated).JSON(ApiResponse{
+		Payload: payload,
+	})
+}
+
+func HandleGetAllWebhooks(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit")
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	var requestCursor WebhooksCursor
+	if err := decodeBase64AndUnmarshalJson(&requestCursor, c.Query("cursor")); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid cursor format")
+	}
+
+	webhooks, err := queries.GetAllUserWebhooks(context.Background(), postgres_repo.GetAllUserWebhooksParams{
+		// filter
+		UserID: getUserIDFromContext(c),
+		// cursor
+		ID: requestCursor.ID,
+		// limit
+		Limit: int32(limit) + 1,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting webhooks: %+v", err))
+	}
+
+	var encodedResponseCursor string
+	hasMore := limit < len(webhooks)
+	if hasMore {
+		responseCursor := WebhooksCursor{
+			ID: webhooks[limit].ID,
+		}
+		encodedResponseCursor, err = marshalJsonAndEncodeBase64(responseCursor)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error encoding cursor: %+v", err))
+		}
+		webhooks = webhooks[:limit]
+	}
+
+	payload := make([]WebhookPayload, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		var webhookPayload WebhookPayload
+		fillWebhookPayload(&webhookPayload, &webhook)
+		payload = append(payload, webhookPayload)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(CursoredApiResponse{
+		Payload:    payload,
+		Cursor:     encodedResponseCursor,
+		HasMore:    hasMore,
+		TotalCount: len(payload),
+	})
+}
+
+func HandleDeleteWebhook(c *fiber.Ctx) error {
+	webhookID, err := uuid.Parse(c.Params("webhook_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	if err := checkUserOwnsWebhook(c, webhookID); err != nil {
+		return err
+	}
+
+	if err := queries.DeleteWebhook(context.Background(), webhookID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error deleting webhook: %+v", err))
+	}
+
+	return c.Status(fiber.StatusOK).SendString("webhook deleted successfully")
+}
+
+// HandleGetAllWebhookDeliveries lists the deliveries of a webhook, latest first, with their status,
+// attempts and last response.
+func HandleGetAllWebhookDeliveries(c *fiber.Ctx) error {
+	webhookID, err := uuid.Parse(c.Params("webhook_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	if err := checkUserOwnsWebhook(c, webhookID); err != nil {
+		return err
+	}
+
+	limit := c.QueryInt("limit")
+	if limit < 10 || limit > 100 {
+		limit = 10
+	}
+
+	var requestCursor WebhookDeliveriesCursor
+	if err := decodeBase64AndUnmarshalJson(&requestCursor, c.Query("cursor")); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid cursor format")
+	}
+
+	deliveries, err := queries.GetAllWebhookDeliveries(context.Background(), postgres_repo.GetAllWebhookDeliveriesParams{
+		// filter
+		WebhookID: webhookID,
+		// cursor
+		ID: requestCursor.ID,
+		// limit
+		Limit: int32(limit) + 1,
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting webhook deliveries: %+v", err))
+	}
+
+	var encodedResponseCursor string
+	hasMore := limit < len(deliveries)
+	if hasMore {
+		responseCursor := WebhookDeliveriesCursor{
+			ID: deliveries[limit].ID,
+		}
+		encodedResponseCursor, err = marshalJsonAndEncodeBase64(responseCursor)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error encoding cursor: %+v", err))
+		}
+		deliveries = deliveries[:limit]
+	}
+
+	payload := make([]WebhookDeliveryPayload, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		var deliveryPayload WebhookDeliveryPayload
+		fillWebhookDeliveryPayload(&deliveryPayload, &delivery)
+		payload = append(payload, deliveryPayload)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(CursoredApiResponse{
+		Payload:    payload,
+		Cursor:     encodedResponseCursor,
+		HasMore:    hasMore,
+		TotalCount: len(payload),
+	})
+}
+
+// HandleRedeliverWebhook queues a new delivery with the same event and payload as a finished delivery.
+// The original delivery is kept in the log.
+func HandleRedeliverWebhook(c *fiber.Ctx) error {
+	webhookID, err := uuid.Parse(c.Params("webhook_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+	deliveryID, err := uuid.Parse(c.Params("delivery_id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid ID fromat")
+	}
+
+	if err := checkUserOwnsWebhook(c, webhookID); err != nil {
+		return err
+	}
+
+	delivery, err := queries.GetWebhookDelivery(context.Background(), postgres_repo.GetWebhookDeliveryParams{
+		ID:        deliveryID,
+		WebhookID: webhookID,
+	})
+	if err != nil {
+		if repo.IsNotFoundError(err) {
+			return fiber.NewError(fiber.StatusNotFound, "webhook delivery not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error getting webhook delivery: %+v", err))
+	}
+	if delivery.Status == repo.WebhookDeliveryStatusPending {
+		return fiber.NewError(fiber.StatusConflict, "webhook delivery is still pending")
+	}
+
+	redelivery, err := queries.CreateWebhookRedelivery(context.Background(), deliveryID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error creating webhook redelivery: %+v", err))
+	}
+
+	var payload WebhookDeliveryPayload
+	fillWebhookDeliveryPayload(&payload, &redelivery)
+
+	return c.Status(fiber.StatusCreated).JSON(ApiResponse{
+		Payload: payload,
+	})
+}
+
+func checkUserOwnsWebhook(c *fiber.Ctx, webhookID uuid.UUID) error {
+	if owns, err := queries.CheckUserOwnsWebhook(context.Background(), postgres_repo.CheckUserOwnsWebhookParams{
+		ID:     webhookID,
+		UserID: getUserIDFromContext(c),
+	}); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking webhook: %+v", err))
+	} else if !owns {
+		return fiber.NewError(fiber.StatusNotFound, "webhook not found")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"html/template"
+	"log"
+	"mime"
+	"os"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+}
+
+// Mailer sends emails. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Default is the mailer used by the app, selected by the MAILER env var:
+//   - "smtp": sends emails through SMTP_HOST:SMTP_PORT, authenticating with SMTP_USERNAME and SMTP_PASSWORD.
+//   - "file" (the default): writes each email as an .eml file into MAIL_DIR.
+//   - "memory": keeps the emails in memory.
+var Default Mailer
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+var templates = template.Must(template.ParseFS(templatesFS, "templates/*.html"))
+
+func init() {
+	switch kind := os.Getenv("MAILER"); kind {
+	case "smtp":
+		Default = NewSMTPMailer(
+			os.Getenv("SMTP_HOST"),
+			os.Getenv("SMTP_PORT"),
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+			os.Getenv("MAIL_FROM"),
+		)
+	case "file", "":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "./mails"
+		}
+		Default = NewFileMailer(dir, os.Getenv("MAIL_FROM"))
+	case "memory":
+		Default = NewMemoryMailer()
+	default:
+		log.Fatal("invalid env value for MAILER:", kind)
+	}
+}
+
+// Render executes the named template (a file in templates/) with data. Values are escaped
+// by html/template, so user content can be passed as is.
+func Render(name string, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("error rendering template %q: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// encode encodes the message as an RFC 5322 email with an HTML body.
+func (msg Message) encode(from string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/html; charset=\"utf-8\"\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(msg.HTML)
+	return buf.Bytes()
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"strings"
@@ -12,11 +13,15 @@ import (
 	"github.com/assaidy/blogging_app/internal/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/google/uuid"
 )
 
 var repo = postgres_repo.New(postgres_db.DB)
 
-const AuthUserID = "middleware.auth.userID"
+const (
+	AuthUserID    = "middleware.auth.userID"
+	AuthSessionID = "middleware.auth.sessionID"
+)
 
 var Logger = logger.New()
 
@@ -32,18 +37,73 @@ func Auth(c *fiber.Ctx) error {
 	if claims.ExpiresAt.Sub(time.Now()) < 0 {
 		return fiber.ErrUnauthorized
 	}
+	// tokens issued before jti were added can't be revoked, so they have to be refreshed.
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return fiber.ErrUnauthorized
+	}
 	// NOTE: if the users deleted his account, but his access token hasn't expired yet,
 	// and we got a request that uses mwAuth(get's userid from context),
 	// we need to ensure that user exists.
-	if exists, err := repo.CheckUserID(context.Background(), claims.UserID); err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking user ID: %+v", err))
-	} else if !exists {
+	if valid, err := checkAccessToken(claims); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("error checking access token: %+v", err))
+	} else if !valid {
 		return fiber.ErrUnauthorized
 	}
 	c.Locals(AuthUserID, claims.UserID)
+	c.Locals(AuthSessionID, claims.SessionID)
 	return c.Next()
 }
 
+const (
+	accessTokenStateTTL        = 30 * time.Second
+	maxCachedAccessTokenStates = 10_000
+)
+
+type accessTokenState struct {
+	userID uuid.UUID
+	valid  bool
+}
+
+// accessTokenStates caches whether the access tokens are still valid by their jti,
+// to avoid a DB hit per request.
+//
+// NOTE: in prefork mode, every process has its own cache, and InvalidateAccessTokens only
+// clears the cache of the current process, so a revoked token can still be accepted
+// by the other processes for up to accessTokenStateTTL.
+var accessTokenStates = utils.NewTTLCache[string, accessTokenState](accessTokenStateTTL, maxCachedAccessTokenStates)
+
+// checkAccessToken tells whether the user of the unexpired token still exists, its session isn't
+// ended, it isn't revoked, and it's issued after the last time all the user's tokens were revoked.
+func checkAccessToken(claims *utils.JWTClaims) (bool, error) {
+	if state, ok := accessTokenStates.Get(claims.ID); ok {
+		return state.valid, nil
+	}
+
+	row, err := repo.GetAccessTokenState(context.Background(), postgres_repo.GetAccessTokenStateParams{
+		SessionID: claims.SessionID,
+		Jti:       claims.ID,
+		UserID:    claims.UserID,
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	valid := err == nil && row.SessionExists && !row.Revoked &&
+		// both are truncated to the second, so a token issued in the same second as the revocation,
+		// like the one refreshed right after a password change, is accepted.
+		(!row.TokensValidAfter.Valid || !claims.IssuedAt.Time.Before(row.TokensValidAfter.Time))
+
+	accessTokenStates.Set(claims.ID, accessTokenState{userID: claims.UserID, valid: valid})
+	return valid, nil
+}
+
+// InvalidateAccessTokens drops the cached states of the user's access tokens,
+// so revoking them takes effect right away in the current process.
+func InvalidateAccessTokens(userID uuid.UUID) {
+	accessTokenStates.DeleteFunc(func(_ string, state accessTokenState) bool {
+		return state.userID == userID
+	})
+}
+
 func ErrorHandler(c *fiber.Ctx, err error) error {
 	code := fiber.StatusInternalServerError
 	var fiberErr *fiber.Error
@@ -3,15 +3,60 @@ package repo
 import (
 	"database/sql"
 	"errors"
+
+	"github.com/lib/pq"
 )
 
 func IsNotFoundError(err error) bool {
 	return errors.Is(err, sql.ErrNoRows)
 }
 
+func IsUniqueViolationError(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// IsInvalidInputError reports whether postgres rejected a user provided value it parses,
+// like a malformed tsquery: a syntax error or an invalid parameter value.
+func IsInvalidInputError(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == "42601" || pqErr.Code == "22023")
+}
+
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+	PostStatusUnlisted  = "unlisted"
+)
+
+const (
+	WebhookEventPostPublished  = "post.published"
+	WebhookEventPostUpdated    = "post.updated"
+	WebhookEventCommentCreated = "comment.created"
+	WebhookEventUserFollowed   = "user.followed"
+)
+
+const (
+	WebhookDeliveryStatusPending   = "pending"
+	WebhookDeliveryStatusSucceeded = "succeeded"
+	WebhookDeliveryStatusFailed    = "failed"
+)
+
+const (
+	DigestFrequencyNever  = "never"
+	DigestFrequencyDaily  = "daily"
+	DigestFrequencyWeekly = "weekly"
+)
+
 // NOTE: order is very important here.
 // order follows kind's id in db.
 const (
 	NotificationKindNewFollower = iota + 1
 	NotificationKindNewPost
+	NotificationKindTopicMatch
+	NotificationKindNewComment
+	NotificationKindNewReply
+	NotificationKindNewReaction
+	NotificationKindMention
 )
@@ -6,31 +6,89 @@ package postgres_repo
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ActivityDelivery struct {
+	ID            uuid.UUID
+	UserID        uuid.UUID
+	InboxUrl      string
+	Activity      json.RawMessage
+	Attempts      int32
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+}
+
+type ActorKey struct {
+	UserID        uuid.UUID
+	PublicKeyPem  string
+	PrivateKeyPem string
+	CreatedAt     time.Time
+}
+
 type Bookmark struct {
 	UserID    uuid.UUID
 	PostID    uuid.UUID
 	CreatedAt time.Time
 }
 
+type EmailVerificationToken struct {
+	Token     string
+	UserID    uuid.UUID
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
 type Follow struct {
 	FollowerID uuid.UUID
 	FollowedID uuid.UUID
 	CreatedAt  time.Time
 }
 
-type Notification struct {
-	ID        uuid.UUID
-	KindID    int32
+type MfaChallenge struct {
+	Token     string
 	UserID    uuid.UUID
-	SenderID  uuid.NullUUID
-	PostID    uuid.NullUUID
-	IsRead    bool
+	Attempts  int32
 	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type Notification struct {
+	ID          uuid.UUID
+	KindID      int32
+	UserID      uuid.UUID
+	SenderID    uuid.NullUUID
+	PostID      uuid.NullUUID
+	IsRead      bool
+	CreatedAt   time.Time
+	CommentID   uuid.NullUUID
+	ActorIds    []uuid.UUID
+	ActorsCount int32
+	InApp       bool
+	GroupID     uuid.NullUUID
+}
+
+type NotificationEvent struct {
+	ID            uuid.UUID
+	KindID        int32
+	UserID        uuid.NullUUID
+	SenderID      uuid.NullUUID
+	PostID        uuid.NullUUID
+	Status        string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     sql.NullString
+	CreatedAt     time.Time
+	CommentID     uuid.NullUUID
+}
+
+type NotificationGroupActor struct {
+	GroupID uuid.UUID
+	ActorID uuid.UUID
 }
 
 type NotificationKind struct {
@@ -38,6 +96,21 @@ type NotificationKind struct {
 	Name string
 }
 
+type NotificationMute struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	MutedUserID uuid.NullUUID
+	MutedPostID uuid.NullUUID
+	CreatedAt   time.Time
+}
+
+type NotificationPreference struct {
+	UserID uuid.UUID
+	KindID int32
+	InApp  bool
+	Digest bool
+}
+
 type Post struct {
 	ID               uuid.UUID
 	UserID           uuid.UUID
@@ -47,14 +120,24 @@ type Post struct {
 	ViewsCount       int32
 	CommentsCount    int32
 	FeaturedImageUrl sql.NullString
+	Status           string
+	PublishedAt      sql.NullTime
+	PublishAt        sql.NullTime
+	ContentHtml      sql.NullString
+	Toc              json.RawMessage
+	Slug             string
+	UpdatedAt        time.Time
 }
 
 type PostComment struct {
-	ID        uuid.UUID
-	PostID    uuid.UUID
-	UserID    uuid.UUID
-	Content   string
-	CreatedAt time.Time
+	ID           uuid.UUID
+	PostID       uuid.UUID
+	UserID       uuid.UUID
+	Content      string
+	CreatedAt    time.Time
+	ParentID     uuid.NullUUID
+	RepliesCount int32
+	ContentHtml  sql.NullString
 }
 
 type PostReaction struct {
@@ -64,6 +147,20 @@ type PostReaction struct {
 	CreatedAt time.Time
 }
 
+type PostRevision struct {
+	ID               uuid.UUID
+	PostID           uuid.UUID
+	Title            string
+	Content          string
+	FeaturedImageUrl sql.NullString
+	CreatedAt        time.Time
+}
+
+type PostTag struct {
+	PostID uuid.UUID
+	TagID  int32
+}
+
 type PostView struct {
 	PostID    uuid.UUID
 	UserID    uuid.UUID
@@ -75,21 +172,121 @@ type ReactionKind struct {
 	Name string
 }
 
+type RecoveryCode struct {
+	ID       uuid.UUID
+	UserID   uuid.UUID
+	CodeHash string
+	UsedAt   sql.NullTime
+}
+
 type RefreshToken struct {
 	Token     string
 	UserID    uuid.UUID
 	CreatedAt time.Time
 	ExpiresAt time.Time
+	FamilyID  uuid.UUID
+	UsedAt    sql.NullTime
+}
+
+type RemoteFollower struct {
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	ActorID        string
+	InboxUrl       string
+	SharedInboxUrl sql.NullString
+	FollowedAt     time.Time
+}
+
+type RevokedAccessToken struct {
+	Jti       string
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	RevokedAt time.Time
+}
+
+type Session struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	UserAgent  string
+	IpAddress  string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+type SitemapSync struct {
+	ID       bool
+	SyncedAt time.Time
+}
+
+type SitemapUrl struct {
+	ID      int64
+	PostID  uuid.NullUUID
+	UserID  uuid.NullUUID
+	Lastmod time.Time
+}
+
+type Tag struct {
+	ID         int32
+	Slug       string
+	Name       string
+	PostsCount int32
+	CreatedAt  time.Time
+}
+
+type TopicSubscription struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Query     string
+	IsTsquery bool
+	CreatedAt time.Time
+}
+
+type TotpAuthenticator struct {
+	UserID         uuid.UUID
+	Secret         string
+	CreatedAt      time.Time
+	ConfirmedAt    sql.NullTime
+	LastUsedStep   int64
+	FailedAttempts int32
+	LockedUntil    sql.NullTime
 }
 
 type User struct {
-	ID              uuid.UUID
-	Name            string
-	Username        string
-	HashedPassword  string
-	JoinedAt        time.Time
-	PostsCount      int32
-	FollowingCount  int32
-	FollowersCount  int32
-	ProfileImageUrl sql.NullString
+	ID               uuid.UUID
+	Name             string
+	Username         string
+	HashedPassword   string
+	JoinedAt         time.Time
+	PostsCount       int32
+	FollowingCount   int32
+	FollowersCount   int32
+	ProfileImageUrl  sql.NullString
+	Email            sql.NullString
+	EmailVerifiedAt  sql.NullTime
+	DigestFrequency  string
+	LastDigestSentAt sql.NullTime
+	TokensValidAfter sql.NullTime
+}
+
+type Webhook struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Url       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	WebhookID      uuid.UUID
+	Event          string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	CreatedAt      time.Time
+	DeliveredAt    sql.NullTime
 }
@@ -7,13 +7,15 @@ package postgres_repo
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const checkNotificationForUser = `-- name: CheckNotificationForUser :one
-SELECT EXISTS(SELECT 1 FROM notifications WHERE id = $1 AND user_id = $2)
+SELECT EXISTS(SELECT 1 FROM notifications WHERE id = $1 AND user_id = $2 AND in_app)
 `
 
 type CheckNotificationForUserParams struct {
@@ -28,18 +30,143 @@ func (q *Queries) CheckNotificationForUser(ctx context.Context, arg CheckNotific
 	return exists, err
 }
 
-const createNotification = `-- name: CreateNotification :one
-INSERT INTO notifications(kind_id, user_id, sender_id, post_id, is_read)
-VALUES($1, $2, $3, $4, $5)
-RETURNING id, kind_id, user_id, sender_id, post_id, is_read, created_at
+const claimNotificationEvent = `-- name: ClaimNotificationEvent :one
+SELECT id, kind_id, user_id, sender_id, post_id, status, attempts, next_attempt_at, last_error, created_at, comment_id
+FROM notification_events
+WHERE status = 'pending' AND next_attempt_at <= NOW()
+ORDER BY next_attempt_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED
 `
 
-type CreateNotificationParams struct {
+func (q *Queries) ClaimNotificationEvent(ctx context.Context) (NotificationEvent, error) {
+	row := q.db.QueryRowContext(ctx, claimNotificationEvent)
+	var i NotificationEvent
+	err := row.Scan(
+		&i.ID,
+		&i.KindID,
+		&i.UserID,
+		&i.SenderID,
+		&i.PostID,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.CommentID,
+	)
+	return i, err
+}
+
+const createFollowersNotifications = `-- name: CreateFollowersNotifications :exec
+INSERT INTO notifications(kind_id, user_id, sender_id, post_id, actor_ids, in_app)
+SELECT
+    $1::INTEGER,
+    follower_id,
+    $2::UUID,
+    $3::UUID,
+    ARRAY[$2::UUID],
+    notify_in_app(follower_id, $1::INTEGER)
+FROM follows
+WHERE
+    followed_id = $2::UUID AND
+    should_notify(follower_id, $1::INTEGER, $2::UUID, $3::UUID)
+`
+
+type CreateFollowersNotificationsParams struct {
 	KindID   int32
-	UserID   uuid.UUID
-	SenderID uuid.NullUUID
+	SenderID uuid.UUID
 	PostID   uuid.NullUUID
-	IsRead   bool
+}
+
+func (q *Queries) CreateFollowersNotifications(ctx context.Context, arg CreateFollowersNotificationsParams) error {
+	_, err := q.db.ExecContext(ctx, createFollowersNotifications, arg.KindID, arg.SenderID, arg.PostID)
+	return err
+}
+
+const createGroupedNotificationIfWanted = `-- name: CreateGroupedNotificationIfWanted :exec
+WITH previous AS (
+    DELETE FROM notifications
+    WHERE
+        id = (
+            SELECT id FROM notifications
+            WHERE
+                user_id = $1::UUID AND
+                kind_id = $2::INTEGER AND
+                post_id IS NOT DISTINCT FROM $3::UUID AND
+                is_read = FALSE AND
+                created_at > $4::TIMESTAMP
+            ORDER BY id DESC
+            LIMIT 1
+        ) AND
+        should_notify($1::UUID, $2::INTEGER, $5::UUID, $3::UUID)
+    RETURNING group_id, actor_ids, actors_count
+),
+notification_group AS MATERIALIZED (
+    SELECT COALESCE((SELECT group_id FROM previous), generate_ulid_as_uuid()) AS id
+    WHERE should_notify($1::UUID, $2::INTEGER, $5::UUID, $3::UUID)
+),
+new_actor AS (
+    INSERT INTO notification_group_actors(group_id, actor_id)
+    SELECT id, $5::UUID FROM notification_group
+    ON CONFLICT DO NOTHING
+    RETURNING actor_id
+)
+INSERT INTO notifications(kind_id, user_id, sender_id, post_id, comment_id, actor_ids, actors_count, in_app, group_id)
+SELECT
+    $2::INTEGER,
+    $1::UUID,
+    $5::UUID,
+    $3::UUID,
+    $6::UUID,
+    ($5::UUID || array_remove(COALESCE(previous.actor_ids, '{}'), $5::UUID))[1:$7::INTEGER],
+    COALESCE(previous.actors_count, 0) + (SELECT COUNT(*) FROM new_actor)::INTEGER,
+    notify_in_app($1::UUID, $2::INTEGER),
+    notification_group.id
+FROM notification_group
+LEFT JOIN previous ON TRUE
+`
+
+type CreateGroupedNotificationIfWantedParams struct {
+	UserID    uuid.UUID
+	KindID    int32
+	PostID    uuid.NullUUID
+	Since     time.Time
+	SenderID  uuid.UUID
+	CommentID uuid.NullUUID
+	MaxActors int32
+}
+
+// creates the notification, unless the receiver opted out of it, merging it with the latest unread notification
+// of the same kind and post created after since, if any. The merged notification is replaced with a new one
+// of the same group, so it's listed and streamed as the latest notification. The sender is only counted
+// in actors_count if they're new to the group.
+func (q *Queries) CreateGroupedNotificationIfWanted(ctx context.Context, arg CreateGroupedNotificationIfWantedParams) error {
+	_, err := q.db.ExecContext(ctx, createGroupedNotificationIfWanted,
+		arg.UserID,
+		arg.KindID,
+		arg.PostID,
+		arg.Since,
+		arg.SenderID,
+		arg.CommentID,
+		arg.MaxActors,
+	)
+	return err
+}
+
+const createNotification = `-- name: CreateNotification :one
+INSERT INTO notifications(kind_id, user_id, sender_id, post_id, comment_id, is_read)
+VALUES($1, $2, $3, $4, $5, $6)
+RETURNING id, kind_id, user_id, sender_id, post_id, is_read, created_at, comment_id, actor_ids, actors_count, in_app, group_id
+`
+
+type CreateNotificationParams struct {
+	KindID    int32
+	UserID    uuid.UUID
+	SenderID  uuid.NullUUID
+	PostID    uuid.NullUUID
+	CommentID uuid.NullUUID
+	IsRead    bool
 }
 
 func (q *Queries) CreateNotification(ctx context.Context, arg CreateNotificationParams) (Notification, error) {
@@ -48,6 +175,7 @@ func (q *Queries) CreateNotification(ctx context.Context, arg CreateNotification
 		arg.UserID,
 		arg.SenderID,
 		arg.PostID,
+		arg.CommentID,
 		arg.IsRead,
 	)
 	var i Notification
@@ -59,10 +187,191 @@ func (q *Queries) CreateNotification(ctx context.Context, arg CreateNotification
 		&i.PostID,
 		&i.IsRead,
 		&i.CreatedAt,
+		&i.CommentID,
+		pq.Array(&i.ActorIds),
+		&i.ActorsCount,
+		&i.InApp,
+		&i.GroupID,
 	)
 	return i, err
 }
 
+const createNotificationEvent = `-- name: CreateNotificationEvent :exec
+INSERT INTO notification_events(kind_id, user_id, sender_id, post_id, comment_id)
+VALUES($1, $2, $3, $4, $5)
+`
+
+type CreateNotificationEventParams struct {
+	KindID    int32
+	UserID    uuid.NullUUID
+	SenderID  uuid.NullUUID
+	PostID    uuid.NullUUID
+	CommentID uuid.NullUUID
+}
+
+func (q *Queries) CreateNotificationEvent(ctx context.Context, arg CreateNotificationEventParams) error {
+	_, err := q.db.ExecContext(ctx, createNotificationEvent,
+		arg.KindID,
+		arg.UserID,
+		arg.SenderID,
+		arg.PostID,
+		arg.CommentID,
+	)
+	return err
+}
+
+const createNotificationIfWanted = `-- name: CreateNotificationIfWanted :exec
+INSERT INTO notifications(kind_id, user_id, sender_id, post_id, comment_id, actor_ids, in_app)
+SELECT
+    $1::INTEGER,
+    $2::UUID,
+    $3::UUID,
+    $4::UUID,
+    $5::UUID,
+    array_remove(ARRAY[$3::UUID], NULL),
+    notify_in_app($2::UUID, $1::INTEGER)
+WHERE should_notify($2::UUID, $1::INTEGER, $3::UUID, $4::UUID)
+`
+
+type CreateNotificationIfWantedParams struct {
+	KindID    int32
+	UserID    uuid.UUID
+	SenderID  uuid.NullUUID
+	PostID    uuid.NullUUID
+	CommentID uuid.NullUUID
+}
+
+// creates the notification, unless the receiver opted out of it.
+func (q *Queries) CreateNotificationIfWanted(ctx context.Context, arg CreateNotificationIfWantedParams) error {
+	_, err := q.db.ExecContext(ctx, createNotificationIfWanted,
+		arg.KindID,
+		arg.UserID,
+		arg.SenderID,
+		arg.PostID,
+		arg.CommentID,
+	)
+	return err
+}
+
+const createTopicSubscribersNotifications = `-- name: CreateTopicSubscribersNotifications :exec
+INSERT INTO notifications(kind_id, user_id, sender_id, post_id, actor_ids, in_app)
+SELECT DISTINCT
+    $1::INTEGER,
+    topic_subscriptions.user_id,
+    posts.user_id,
+    posts.id,
+    ARRAY[posts.user_id],
+    notify_in_app(topic_subscriptions.user_id, $1::INTEGER)
+FROM topic_subscriptions, posts
+WHERE
+    posts.id = $2::UUID AND
+    topic_subscriptions.user_id <> posts.user_id AND
+    should_notify(topic_subscriptions.user_id, $1::INTEGER, posts.user_id, posts.id) AND
+    -- followers already get a 'new_post' notification
+    NOT EXISTS(
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = topic_subscriptions.user_id AND follows.followed_id = posts.user_id
+    ) AND
+    to_tsvector('english', posts.title || ' ' || posts.content) @@ (
+        CASE WHEN topic_subscriptions.is_tsquery
+        THEN to_tsquery('english', topic_subscriptions.query)
+        ELSE plainto_tsquery('english', topic_subscriptions.query)
+        END
+    )
+`
+
+type CreateTopicSubscribersNotificationsParams struct {
+	KindID int32
+	PostID uuid.UUID
+}
+
+func (q *Queries) CreateTopicSubscribersNotifications(ctx context.Context, arg CreateTopicSubscribersNotificationsParams) error {
+	_, err := q.db.ExecContext(ctx, createTopicSubscribersNotifications, arg.KindID, arg.PostID)
+	return err
+}
+
+const deleteAllReadNotifications = `-- name: DeleteAllReadNotifications :execrows
+DELETE FROM notifications WHERE user_id = $1 AND is_read = true
+`
+
+func (q *Queries) DeleteAllReadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteAllReadNotifications, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteClosedNotificationGroupActors = `-- name: DeleteClosedNotificationGroupActors :execrows
+DELETE FROM notification_group_actors
+WHERE (group_id, actor_id) IN (
+    SELECT a.group_id, a.actor_id FROM notification_group_actors a
+    WHERE NOT EXISTS(
+        SELECT 1 FROM notifications n
+        WHERE n.group_id = a.group_id AND n.is_read = FALSE AND n.created_at > $2::TIMESTAMP
+    )
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+`
+
+type DeleteClosedNotificationGroupActorsParams struct {
+	Limit int32
+	Since time.Time
+}
+
+// deletes up to limit actors of the groups that can't get new notifications: their notification is read,
+// deleted, or was created before since.
+func (q *Queries) DeleteClosedNotificationGroupActors(ctx context.Context, arg DeleteClosedNotificationGroupActorsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteClosedNotificationGroupActors, arg.Limit, arg.Since)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteNotification = `-- name: DeleteNotification :exec
+DELETE FROM notifications WHERE id = $1
+`
+
+func (q *Queries) DeleteNotification(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteNotification, id)
+	return err
+}
+
+const deleteNotificationEvent = `-- name: DeleteNotificationEvent :exec
+DELETE FROM notification_events WHERE id = $1
+`
+
+func (q *Queries) DeleteNotificationEvent(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteNotificationEvent, id)
+	return err
+}
+
+const deleteOldReadNotifications = `-- name: DeleteOldReadNotifications :execrows
+DELETE FROM notifications
+WHERE id IN (
+    SELECT id FROM notifications
+    WHERE is_read = true AND created_at < $2::TIMESTAMP
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+`
+
+type DeleteOldReadNotificationsParams struct {
+	Limit  int32
+	Before time.Time
+}
+
+// deletes up to limit read notifications created before the given time.
+func (q *Queries) DeleteOldReadNotifications(ctx context.Context, arg DeleteOldReadNotificationsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOldReadNotifications, arg.Limit, arg.Before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getAllNotifications = `-- name: GetAllNotifications :many
 SELECT 
     n.id,
@@ -70,6 +379,9 @@ SELECT
     n.user_id,
     n.sender_id,
     n.post_id,
+    n.comment_id,
+    n.actor_ids,
+    n.actors_count,
     n.is_read,
     n.created_at
 FROM notifications n
@@ -77,30 +389,41 @@ JOIN notification_kinds nk ON nk.id = n.kind_id
 WHERE
     -- filter
     n.user_id = $1 AND
+    n.in_app AND
+    (NOT $3::BOOLEAN OR n.is_read = false) AND
     -- cursor
-    (is_zero_uuid($3::UUID) OR id <= $3::UUID)
+    (is_zero_uuid($4::UUID) OR id <= $4::UUID)
 ORDER BY n.id DESC
 LIMIT $2
 `
 
 type GetAllNotificationsParams struct {
-	UserID uuid.UUID
-	Limit  int32
-	ID     uuid.UUID
+	UserID     uuid.UUID
+	Limit      int32
+	UnreadOnly bool
+	ID         uuid.UUID
 }
 
 type GetAllNotificationsRow struct {
-	ID        uuid.UUID
-	Kind      string
-	UserID    uuid.UUID
-	SenderID  uuid.NullUUID
-	PostID    uuid.NullUUID
-	IsRead    bool
-	CreatedAt time.Time
+	ID          uuid.UUID
+	Kind        string
+	UserID      uuid.UUID
+	SenderID    uuid.NullUUID
+	PostID      uuid.NullUUID
+	CommentID   uuid.NullUUID
+	ActorIds    []uuid.UUID
+	ActorsCount int32
+	IsRead      bool
+	CreatedAt   time.Time
 }
 
 func (q *Queries) GetAllNotifications(ctx context.Context, arg GetAllNotificationsParams) ([]GetAllNotificationsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getAllNotifications, arg.UserID, arg.Limit, arg.ID)
+	rows, err := q.db.QueryContext(ctx, getAllNotifications,
+		arg.UserID,
+		arg.Limit,
+		arg.UnreadOnly,
+		arg.ID,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -114,6 +437,85 @@ func (q *Queries) GetAllNotifications(ctx context.Context, arg GetAllNotificatio
 			&i.UserID,
 			&i.SenderID,
 			&i.PostID,
+			&i.CommentID,
+			pq.Array(&i.ActorIds),
+			&i.ActorsCount,
+			&i.IsRead,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllNotificationsAfter = `-- name: GetAllNotificationsAfter :many
+SELECT 
+    n.id,
+    nk.name as kind,
+    n.user_id,
+    n.sender_id,
+    n.post_id,
+    n.comment_id,
+    n.actor_ids,
+    n.actors_count,
+    n.is_read,
+    n.created_at
+FROM notifications n
+JOIN notification_kinds nk ON nk.id = n.kind_id
+WHERE
+    n.user_id = $1 AND
+    n.in_app AND
+    n.id > $3::UUID
+ORDER BY n.id ASC
+LIMIT $2
+`
+
+type GetAllNotificationsAfterParams struct {
+	UserID uuid.UUID
+	Limit  int32
+	ID     uuid.UUID
+}
+
+type GetAllNotificationsAfterRow struct {
+	ID          uuid.UUID
+	Kind        string
+	UserID      uuid.UUID
+	SenderID    uuid.NullUUID
+	PostID      uuid.NullUUID
+	CommentID   uuid.NullUUID
+	ActorIds    []uuid.UUID
+	ActorsCount int32
+	IsRead      bool
+	CreatedAt   time.Time
+}
+
+// used to stream notifications, oldest first.
+func (q *Queries) GetAllNotificationsAfter(ctx context.Context, arg GetAllNotificationsAfterParams) ([]GetAllNotificationsAfterRow, error) {
+	rows, err := q.db.QueryContext(ctx, getAllNotificationsAfter, arg.UserID, arg.Limit, arg.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetAllNotificationsAfterRow
+	for rows.Next() {
+		var i GetAllNotificationsAfterRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.UserID,
+			&i.SenderID,
+			&i.PostID,
+			&i.CommentID,
+			pq.Array(&i.ActorIds),
+			&i.ActorsCount,
 			&i.IsRead,
 			&i.CreatedAt,
 		); err != nil {
@@ -130,9 +532,23 @@ func (q *Queries) GetAllNotifications(ctx context.Context, arg GetAllNotificatio
 	return items, nil
 }
 
+const getLatestNotificationID = `-- name: GetLatestNotificationID :one
+SELECT id FROM notifications
+WHERE user_id = $1
+ORDER BY id DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestNotificationID(ctx context.Context, userID uuid.UUID) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, getLatestNotificationID, userID)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
 const getNotificationsCount = `-- name: GetNotificationsCount :one
 SELECT COUNT(*) FROM notifications
-WHERE user_id = $1
+WHERE user_id = $1 AND in_app
 `
 
 func (q *Queries) GetNotificationsCount(ctx context.Context, userID uuid.UUID) (int64, error) {
@@ -144,7 +560,7 @@ func (q *Queries) GetNotificationsCount(ctx context.Context, userID uuid.UUID) (
 
 const getUnreadNotificationsCount = `-- name: GetUnreadNotificationsCount :one
 SELECT COUNT(*) FROM notifications
-WHERE user_id = $1 AND is_read = false
+WHERE user_id = $1 AND in_app AND is_read = false
 `
 
 func (q *Queries) GetUnreadNotificationsCount(ctx context.Context, userID uuid.UUID) (int64, error) {
@@ -154,6 +570,67 @@ func (q *Queries) GetUnreadNotificationsCount(ctx context.Context, userID uuid.U
 	return count, err
 }
 
+const killNotificationEvent = `-- name: KillNotificationEvent :exec
+UPDATE notification_events
+SET
+    status = 'dead',
+    attempts = attempts + 1,
+    last_error = $1
+WHERE id = $2
+`
+
+type KillNotificationEventParams struct {
+	LastError sql.NullString
+	ID        uuid.UUID
+}
+
+func (q *Queries) KillNotificationEvent(ctx context.Context, arg KillNotificationEventParams) error {
+	_, err := q.db.ExecContext(ctx, killNotificationEvent, arg.LastError, arg.ID)
+	return err
+}
+
+const lockNotificationGroup = `-- name: LockNotificationGroup :exec
+SELECT pg_advisory_xact_lock(hashtextextended(
+    $1::UUID::TEXT || ':' || $2::INTEGER::TEXT || ':' || COALESCE($3::UUID::TEXT, ''),
+    0
+))
+`
+
+type LockNotificationGroupParams struct {
+	UserID uuid.UUID
+	KindID int32
+	PostID uuid.NullUUID
+}
+
+// serializes the creation of grouped notifications of the same group until the end of the transaction.
+func (q *Queries) LockNotificationGroup(ctx context.Context, arg LockNotificationGroupParams) error {
+	_, err := q.db.ExecContext(ctx, lockNotificationGroup, arg.UserID, arg.KindID, arg.PostID)
+	return err
+}
+
+const markAllNotificationsAsRead = `-- name: MarkAllNotificationsAsRead :execrows
+UPDATE notifications SET is_read = true
+WHERE
+    user_id = $1 AND
+    in_app AND
+    is_read = false AND
+    (is_zero_uuid($2::UUID) OR id <= $2::UUID)
+`
+
+type MarkAllNotificationsAsReadParams struct {
+	UserID  uuid.UUID
+	UntilID uuid.UUID
+}
+
+// marks all notifications of the user as read, or only the ones up to until_id if it's not zero.
+func (q *Queries) MarkAllNotificationsAsRead(ctx context.Context, arg MarkAllNotificationsAsReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markAllNotificationsAsRead, arg.UserID, arg.UntilID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const markNotificationAsRead = `-- name: MarkNotificationAsRead :exec
 UPDATE notifications SET is_read = true WHERE id = $1
 `
@@ -162,3 +639,41 @@ func (q *Queries) MarkNotificationAsRead(ctx context.Context, id uuid.UUID) erro
 	_, err := q.db.ExecContext(ctx, markNotificationAsRead, id)
 	return err
 }
+
+const markNotificationsAsRead = `-- name: MarkNotificationsAsRead :execrows
+UPDATE notifications SET is_read = true
+WHERE user_id = $1 AND in_app AND is_read = false AND id = ANY($2::UUID[])
+`
+
+type MarkNotificationsAsReadParams struct {
+	UserID uuid.UUID
+	Ids    []uuid.UUID
+}
+
+func (q *Queries) MarkNotificationsAsRead(ctx context.Context, arg MarkNotificationsAsReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markNotificationsAsRead, arg.UserID, pq.Array(arg.Ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const retryNotificationEvent = `-- name: RetryNotificationEvent :exec
+UPDATE notification_events
+SET
+    attempts = attempts + 1,
+    next_attempt_at = $1,
+    last_error = $2
+WHERE id = $3
+`
+
+type RetryNotificationEventParams struct {
+	NextAttemptAt time.Time
+	LastError     sql.NullString
+	ID            uuid.UUID
+}
+
+func (q *Queries) RetryNotificationEvent(ctx context.Context, arg RetryNotificationEventParams) error {
+	_, err := q.db.ExecContext(ctx, retryNotificationEvent, arg.NextAttemptAt, arg.LastError, arg.ID)
+	return err
+}
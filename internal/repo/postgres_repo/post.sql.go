@@ -8,9 +8,11 @@ package postgres_repo
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const checkBookmark = `-- name: CheckBookmark :one
@@ -51,6 +53,26 @@ func (q *Queries) CheckPost(ctx context.Context, id uuid.UUID) (bool, error) {
 	return exists, err
 }
 
+const checkPostVisible = `-- name: CheckPostVisible :one
+SELECT EXISTS(
+    SELECT 1 FROM posts
+    WHERE id = $1 AND (status IN ('published', 'unlisted') OR user_id = $2)
+)
+`
+
+type CheckPostVisibleParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+// checks that the post exists and the user can interact with it: it's published or unlisted, or it's theirs.
+func (q *Queries) CheckPostVisible(ctx context.Context, arg CheckPostVisibleParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, checkPostVisible, arg.ID, arg.UserID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const checkReaction = `-- name: CheckReaction :one
 SELECT EXISTS(SELECT 1 FROM post_reactions WHERE post_id = $1 AND user_id = $2)
 `
@@ -116,19 +138,25 @@ func (q *Queries) CreateBookmark(ctx context.Context, arg CreateBookmarkParams)
 }
 
 const createComment = `-- name: CreateComment :one
-INSERT INTO post_comments(post_id, user_id, content)
-VALUES($1, $2, $3)
-RETURNING id, post_id, user_id, content, created_at
+INSERT INTO post_comments(post_id, user_id, content, content_html)
+VALUES($1, $2, $3, $4)
+RETURNING id, post_id, user_id, content, created_at, parent_id, replies_count, content_html
 `
 
 type CreateCommentParams struct {
-	PostID  uuid.UUID
-	UserID  uuid.UUID
-	Content string
+	PostID      uuid.UUID
+	UserID      uuid.UUID
+	Content     string
+	ContentHtml sql.NullString
 }
 
 func (q *Queries) CreateComment(ctx context.Context, arg CreateCommentParams) (PostComment, error) {
-	row := q.db.QueryRowContext(ctx, createComment, arg.PostID, arg.UserID, arg.Content)
+	row := q.db.QueryRowContext(ctx, createComment,
+		arg.PostID,
+		arg.UserID,
+		arg.Content,
+		arg.ContentHtml,
+	)
 	var i PostComment
 	err := row.Scan(
 		&i.ID,
@@ -136,6 +164,9 @@ func (q *Queries) CreateComment(ctx context.Context, arg CreateCommentParams) (P
 		&i.UserID,
 		&i.Content,
 		&i.CreatedAt,
+		&i.ParentID,
+		&i.RepliesCount,
+		&i.ContentHtml,
 	)
 	return i, err
 }
@@ -175,24 +206,34 @@ func (q *Queries) CreateLike(ctx context.Context, arg CreateLikeParams) error {
 }
 
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts(user_id, title, content, featured_image_url)
-VALUES($1, $2, $3, $4)
-RETURNING id, user_id, title, content, created_at, views_count, comments_count, featured_image_url
+INSERT INTO posts(user_id, title, slug, content, content_html, toc, featured_image_url, status, publish_at)
+VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, user_id, title, content, created_at, views_count, comments_count, featured_image_url, status, published_at, publish_at, content_html, toc, slug, updated_at
 `
 
 type CreatePostParams struct {
 	UserID           uuid.UUID
 	Title            string
+	Slug             string
 	Content          string
+	ContentHtml      sql.NullString
+	Toc              json.RawMessage
 	FeaturedImageUrl sql.NullString
+	Status           string
+	PublishAt        sql.NullTime
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
 	row := q.db.QueryRowContext(ctx, createPost,
 		arg.UserID,
 		arg.Title,
+		arg.Slug,
 		arg.Content,
+		arg.ContentHtml,
+		arg.Toc,
 		arg.FeaturedImageUrl,
+		arg.Status,
+		arg.PublishAt,
 	)
 	var i Post
 	err := row.Scan(
@@ -204,6 +245,13 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.ViewsCount,
 		&i.CommentsCount,
 		&i.FeaturedImageUrl,
+		&i.Status,
+		&i.PublishedAt,
+		&i.PublishAt,
+		&i.ContentHtml,
+		&i.Toc,
+		&i.Slug,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
@@ -226,6 +274,42 @@ func (q *Queries) CreateReaction(ctx context.Context, arg CreateReactionParams)
 	return err
 }
 
+const createReply = `-- name: CreateReply :one
+INSERT INTO post_comments(post_id, user_id, parent_id, content, content_html)
+VALUES($1, $2, $3, $4, $5)
+RETURNING id, post_id, user_id, content, created_at, parent_id, replies_count, content_html
+`
+
+type CreateReplyParams struct {
+	PostID      uuid.UUID
+	UserID      uuid.UUID
+	ParentID    uuid.NullUUID
+	Content     string
+	ContentHtml sql.NullString
+}
+
+func (q *Queries) CreateReply(ctx context.Context, arg CreateReplyParams) (PostComment, error) {
+	row := q.db.QueryRowContext(ctx, createReply,
+		arg.PostID,
+		arg.UserID,
+		arg.ParentID,
+		arg.Content,
+		arg.ContentHtml,
+	)
+	var i PostComment
+	err := row.Scan(
+		&i.ID,
+		&i.PostID,
+		&i.UserID,
+		&i.Content,
+		&i.CreatedAt,
+		&i.ParentID,
+		&i.RepliesCount,
+		&i.ContentHtml,
+	)
+	return i, err
+}
+
 const deleteBookmark = `-- name: DeleteBookmark :exec
 DELETE FROM bookmarks WHERE user_id = $1 AND post_id = $2
 `
@@ -273,7 +357,7 @@ func (q *Queries) DeleteReaction(ctx context.Context, arg DeleteReactionParams)
 }
 
 const getAllBookmarks = `-- name: GetAllBookmarks :many
-SELECT posts.id, posts.user_id, posts.title, posts.content, posts.created_at, posts.views_count, posts.comments_count, posts.featured_image_url
+SELECT posts.id, posts.user_id, posts.title, posts.content, posts.created_at, posts.views_count, posts.comments_count, posts.featured_image_url, posts.status, posts.published_at, posts.publish_at, posts.content_html, posts.toc, posts.slug, posts.updated_at
 FROM bookmarks
 JOIN posts ON bookmarks.post_id = posts.id
 WHERE
@@ -312,6 +396,122 @@ func (q *Queries) GetAllBookmarks(ctx context.Context, arg GetAllBookmarksParams
 			&i.ViewsCount,
 			&i.CommentsCount,
 			&i.FeaturedImageUrl,
+			&i.Status,
+			&i.PublishedAt,
+			&i.PublishAt,
+			&i.ContentHtml,
+			&i.Toc,
+			&i.Slug,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllCommentReplies = `-- name: GetAllCommentReplies :many
+SELECT id, post_id, user_id, content, created_at, parent_id, replies_count, content_html
+FROM post_comments
+WHERE
+    -- filters
+    parent_id = $1 AND
+    -- cursor
+    (is_zero_uuid($3::UUID) OR id <= $3::UUID)
+ORDER BY id DESC
+LIMIT $2
+`
+
+type GetAllCommentRepliesParams struct {
+	ParentID uuid.NullUUID
+	Limit    int32
+	ID       uuid.UUID
+}
+
+func (q *Queries) GetAllCommentReplies(ctx context.Context, arg GetAllCommentRepliesParams) ([]PostComment, error) {
+	rows, err := q.db.QueryContext(ctx, getAllCommentReplies, arg.ParentID, arg.Limit, arg.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PostComment
+	for rows.Next() {
+		var i PostComment
+		if err := rows.Scan(
+			&i.ID,
+			&i.PostID,
+			&i.UserID,
+			&i.Content,
+			&i.CreatedAt,
+			&i.ParentID,
+			&i.RepliesCount,
+			&i.ContentHtml,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllFeedPosts = `-- name: GetAllFeedPosts :many
+SELECT posts.id, posts.user_id, posts.title, posts.content, posts.created_at, posts.views_count, posts.comments_count, posts.featured_image_url, posts.status, posts.published_at, posts.publish_at, posts.content_html, posts.toc, posts.slug, posts.updated_at
+FROM follows
+JOIN posts ON follows.followed_id = posts.user_id
+WHERE
+    -- filter
+    follows.follower_id = $1 AND
+    posts.status = 'published' AND
+    -- cursor
+    (is_zero_uuid($3::UUID) OR posts.id <= $3::UUID)
+ORDER BY posts.id DESC
+LIMIT $2
+`
+
+type GetAllFeedPostsParams struct {
+	FollowerID uuid.UUID
+	Limit      int32
+	ID         uuid.UUID
+}
+
+func (q *Queries) GetAllFeedPosts(ctx context.Context, arg GetAllFeedPostsParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getAllFeedPosts, arg.FollowerID, arg.Limit, arg.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.Content,
+			&i.CreatedAt,
+			&i.ViewsCount,
+			&i.CommentsCount,
+			&i.FeaturedImageUrl,
+			&i.Status,
+			&i.PublishedAt,
+			&i.PublishAt,
+			&i.ContentHtml,
+			&i.Toc,
+			&i.Slug,
+			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -327,11 +527,12 @@ func (q *Queries) GetAllBookmarks(ctx context.Context, arg GetAllBookmarksParams
 }
 
 const getAllPostComments = `-- name: GetAllPostComments :many
-SELECT id, post_id, user_id, content, created_at
+SELECT id, post_id, user_id, content, created_at, parent_id, replies_count, content_html
 FROM post_comments
 WHERE
     -- filters
     post_id = $1 AND
+    parent_id IS NULL AND
     -- cursor
     (is_zero_uuid($3::UUID) OR id <= $3::UUID)
 ORDER BY id DESC
@@ -359,6 +560,9 @@ func (q *Queries) GetAllPostComments(ctx context.Context, arg GetAllPostComments
 			&i.UserID,
 			&i.Content,
 			&i.CreatedAt,
+			&i.ParentID,
+			&i.RepliesCount,
+			&i.ContentHtml,
 		); err != nil {
 			return nil, err
 		}
@@ -374,14 +578,21 @@ func (q *Queries) GetAllPostComments(ctx context.Context, arg GetAllPostComments
 }
 
 const getAllPosts = `-- name: GetAllPosts :many
-SELECT id, user_id, title, content, created_at, views_count, comments_count, featured_image_url
+SELECT id, user_id, title, content, created_at, views_count, comments_count, featured_image_url, status, published_at, publish_at, content_html, toc, slug, updated_at
 FROM posts
 WHERE
     -- filters
-    to_tsvector('english', title || ' ' || content) @@ to_tsquery('english', $2::VARCHAR) AND
+    status = 'published' AND
+    ($2::VARCHAR = '' OR to_tsvector('english', title || ' ' || content) @@ to_tsquery('english', $2::VARCHAR)) AND
+    (COALESCE(cardinality($3::VARCHAR[]), 0) = 0 OR EXISTS(
+        SELECT 1
+        FROM post_tags
+        JOIN tags ON post_tags.tag_id = tags.id
+        WHERE post_tags.post_id = posts.id AND tags.slug = ANY($3::VARCHAR[])
+    )) AND
     -- cursor
-    ($3::INTEGER = 0 OR views_count <= $3::INTEGER) AND
-    (is_zero_uuid($4::UUID) OR id <= $4::UUID)
+    ($4::INTEGER = 0 OR views_count <= $4::INTEGER) AND
+    (is_zero_uuid($5::UUID) OR id <= $5::UUID)
 ORDER BY
     views_count DESC,
     id DESC
@@ -391,6 +602,7 @@ LIMIT $1
 type GetAllPostsParams struct {
 	Limit       int32
 	SearchQuery string
+	Tags        []string
 	ViewsCount  int32
 	ID          uuid.UUID
 }
@@ -399,6 +611,7 @@ func (q *Queries) GetAllPosts(ctx context.Context, arg GetAllPostsParams) ([]Pos
 	rows, err := q.db.QueryContext(ctx, getAllPosts,
 		arg.Limit,
 		arg.SearchQuery,
+		pq.Array(arg.Tags),
 		arg.ViewsCount,
 		arg.ID,
 	)
@@ -418,6 +631,71 @@ func (q *Queries) GetAllPosts(ctx context.Context, arg GetAllPostsParams) ([]Pos
 			&i.ViewsCount,
 			&i.CommentsCount,
 			&i.FeaturedImageUrl,
+			&i.Status,
+			&i.PublishedAt,
+			&i.PublishAt,
+			&i.ContentHtml,
+			&i.Toc,
+			&i.Slug,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllUserDrafts = `-- name: GetAllUserDrafts :many
+SELECT id, user_id, title, content, created_at, views_count, comments_count, featured_image_url, status, published_at, publish_at, content_html, toc, slug, updated_at
+FROM posts
+WHERE
+    -- filter
+    user_id = $1 AND
+    status IN ('draft', 'scheduled') AND
+    -- cursor
+    (is_zero_uuid($3::UUID) OR id <= $3::UUID)
+ORDER BY id DESC
+LIMIT $2
+`
+
+type GetAllUserDraftsParams struct {
+	UserID uuid.UUID
+	Limit  int32
+	ID     uuid.UUID
+}
+
+func (q *Queries) GetAllUserDrafts(ctx context.Context, arg GetAllUserDraftsParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getAllUserDrafts, arg.UserID, arg.Limit, arg.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.Content,
+			&i.CreatedAt,
+			&i.ViewsCount,
+			&i.CommentsCount,
+			&i.FeaturedImageUrl,
+			&i.Status,
+			&i.PublishedAt,
+			&i.PublishAt,
+			&i.ContentHtml,
+			&i.Toc,
+			&i.Slug,
+			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -433,11 +711,12 @@ func (q *Queries) GetAllPosts(ctx context.Context, arg GetAllPostsParams) ([]Pos
 }
 
 const getAllUserPosts = `-- name: GetAllUserPosts :many
-SELECT id, user_id, title, content, created_at, views_count, comments_count, featured_image_url
+SELECT id, user_id, title, content, created_at, views_count, comments_count, featured_image_url, status, published_at, publish_at, content_html, toc, slug, updated_at
 FROM posts
 WHERE
     -- filter
     user_id = $1 AND
+    status = 'published' AND
     -- cursor
     (is_zero_uuid($3::UUID) OR id <= $3::UUID)
 ORDER BY
@@ -470,6 +749,13 @@ func (q *Queries) GetAllUserPosts(ctx context.Context, arg GetAllUserPostsParams
 			&i.ViewsCount,
 			&i.CommentsCount,
 			&i.FeaturedImageUrl,
+			&i.Status,
+			&i.PublishedAt,
+			&i.PublishAt,
+			&i.ContentHtml,
+			&i.Toc,
+			&i.Slug,
+			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -485,7 +771,7 @@ func (q *Queries) GetAllUserPosts(ctx context.Context, arg GetAllUserPostsParams
 }
 
 const getBookmarks = `-- name: GetBookmarks :many
-SELECT posts.id, posts.user_id, posts.title, posts.content, posts.created_at, posts.views_count, posts.comments_count, posts.featured_image_url
+SELECT posts.id, posts.user_id, posts.title, posts.content, posts.created_at, posts.views_count, posts.comments_count, posts.featured_image_url, posts.status, posts.published_at, posts.publish_at, posts.content_html, posts.toc, posts.slug, posts.updated_at
 FROM bookmarks
 JOIN posts ON bookmarks.post_id = posts.id
 WHERE bookmarks.user_id = $1
@@ -518,6 +804,13 @@ func (q *Queries) GetBookmarks(ctx context.Context, arg GetBookmarksParams) ([]P
 			&i.ViewsCount,
 			&i.CommentsCount,
 			&i.FeaturedImageUrl,
+			&i.Status,
+			&i.PublishedAt,
+			&i.PublishAt,
+			&i.ContentHtml,
+			&i.Toc,
+			&i.Slug,
+			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -543,8 +836,76 @@ func (q *Queries) GetBookmarksCount(ctx context.Context, userID uuid.UUID) (int6
 	return count, err
 }
 
+const getComment = `-- name: GetComment :one
+SELECT id, post_id, user_id, content, created_at, parent_id, replies_count, content_html FROM post_comments WHERE id = $1
+`
+
+func (q *Queries) GetComment(ctx context.Context, id uuid.UUID) (PostComment, error) {
+	row := q.db.QueryRowContext(ctx, getComment, id)
+	var i PostComment
+	err := row.Scan(
+		&i.ID,
+		&i.PostID,
+		&i.UserID,
+		&i.Content,
+		&i.CreatedAt,
+		&i.ParentID,
+		&i.RepliesCount,
+		&i.ContentHtml,
+	)
+	return i, err
+}
+
+const getDueScheduledPosts = `-- name: GetDueScheduledPosts :many
+SELECT id, user_id, title, content, created_at, views_count, comments_count, featured_image_url, status, published_at, publish_at, content_html, toc, slug, updated_at
+FROM posts
+WHERE status = 'scheduled' AND publish_at <= NOW()
+ORDER BY publish_at
+LIMIT $1
+FOR UPDATE SKIP LOCKED
+`
+
+func (q *Queries) GetDueScheduledPosts(ctx context.Context, limit int32) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getDueScheduledPosts, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.Content,
+			&i.CreatedAt,
+			&i.ViewsCount,
+			&i.CommentsCount,
+			&i.FeaturedImageUrl,
+			&i.Status,
+			&i.PublishedAt,
+			&i.PublishAt,
+			&i.ContentHtml,
+			&i.Toc,
+			&i.Slug,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPost = `-- name: GetPost :one
-SELECT id, user_id, title, content, created_at, views_count, comments_count, featured_image_url FROM posts WHERE id = $1
+SELECT id, user_id, title, content, created_at, views_count, comments_count, featured_image_url, status, published_at, publish_at, content_html, toc, slug, updated_at FROM posts WHERE id = $1
 `
 
 func (q *Queries) GetPost(ctx context.Context, id uuid.UUID) (Post, error) {
@@ -559,12 +920,19 @@ func (q *Queries) GetPost(ctx context.Context, id uuid.UUID) (Post, error) {
 		&i.ViewsCount,
 		&i.CommentsCount,
 		&i.FeaturedImageUrl,
+		&i.Status,
+		&i.PublishedAt,
+		&i.PublishAt,
+		&i.ContentHtml,
+		&i.Toc,
+		&i.Slug,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
 
 const getPostComments = `-- name: GetPostComments :many
-SELECT id, post_id, user_id, content, created_at
+SELECT id, post_id, user_id, content, created_at, parent_id, replies_count, content_html
 FROM post_comments
 WHERE post_id = $1
 ORDER BY created_at
@@ -593,6 +961,9 @@ func (q *Queries) GetPostComments(ctx context.Context, arg GetPostCommentsParams
 			&i.UserID,
 			&i.Content,
 			&i.CreatedAt,
+			&i.ParentID,
+			&i.RepliesCount,
+			&i.ContentHtml,
 		); err != nil {
 			return nil, err
 		}
@@ -618,6 +989,34 @@ func (q *Queries) GetPostCommentsCount(ctx context.Context, id uuid.UUID) (int32
 	return comments_count, err
 }
 
+const getPostForUpdate = `-- name: GetPostForUpdate :one
+SELECT id, user_id, title, content, created_at, views_count, comments_count, featured_image_url, status, published_at, publish_at, content_html, toc, slug, updated_at FROM posts WHERE id = $1 FOR UPDATE
+`
+
+// locks the post until the end of the transaction, so concurrent changes of its status are serialized.
+func (q *Queries) GetPostForUpdate(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostForUpdate, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.Content,
+		&i.CreatedAt,
+		&i.ViewsCount,
+		&i.CommentsCount,
+		&i.FeaturedImageUrl,
+		&i.Status,
+		&i.PublishedAt,
+		&i.PublishAt,
+		&i.ContentHtml,
+		&i.Toc,
+		&i.Slug,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const getPostReactions = `-- name: GetPostReactions :many
 SELECT 
     rk.name,
@@ -657,7 +1056,7 @@ func (q *Queries) GetPostReactions(ctx context.Context, postID uuid.UUID) ([]Get
 }
 
 const getPostViews = `-- name: GetPostViews :many
-SELECT users.id, users.name, users.username, users.hashed_password, users.joined_at, users.posts_count, users.following_count, users.followers_count, users.profile_image_url
+SELECT users.id, users.name, users.username, users.hashed_password, users.joined_at, users.posts_count, users.following_count, users.followers_count, users.profile_image_url, users.email, users.email_verified_at, users.digest_frequency, users.last_digest_sent_at, users.tokens_valid_after
 FROM post_views
 JOIN users ON post_views.user_id = users.id
 WHERE post_id = $1
@@ -691,6 +1090,11 @@ func (q *Queries) GetPostViews(ctx context.Context, arg GetPostViewsParams) ([]U
 			&i.FollowingCount,
 			&i.FollowersCount,
 			&i.ProfileImageUrl,
+			&i.Email,
+			&i.EmailVerifiedAt,
+			&i.DigestFrequency,
+			&i.LastDigestSentAt,
+			&i.TokensValidAfter,
 		); err != nil {
 			return nil, err
 		}
@@ -727,8 +1131,80 @@ func (q *Queries) GetReactionKindIDByName(ctx context.Context, name string) (int
 	return id, err
 }
 
+const getUnrenderedComments = `-- name: GetUnrenderedComments :many
+SELECT id, content
+FROM post_comments
+WHERE content_html IS NULL
+LIMIT $1
+FOR UPDATE SKIP LOCKED
+`
+
+type GetUnrenderedCommentsRow struct {
+	ID      uuid.UUID
+	Content string
+}
+
+func (q *Queries) GetUnrenderedComments(ctx context.Context, limit int32) ([]GetUnrenderedCommentsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUnrenderedComments, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUnrenderedCommentsRow
+	for rows.Next() {
+		var i GetUnrenderedCommentsRow
+		if err := rows.Scan(&i.ID, &i.Content); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnrenderedPosts = `-- name: GetUnrenderedPosts :many
+SELECT id, content
+FROM posts
+WHERE content_html IS NULL
+LIMIT $1
+FOR UPDATE SKIP LOCKED
+`
+
+type GetUnrenderedPostsRow struct {
+	ID      uuid.UUID
+	Content string
+}
+
+func (q *Queries) GetUnrenderedPosts(ctx context.Context, limit int32) ([]GetUnrenderedPostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUnrenderedPosts, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUnrenderedPostsRow
+	for rows.Next() {
+		var i GetUnrenderedPostsRow
+		if err := rows.Scan(&i.ID, &i.Content); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserPosts = `-- name: GetUserPosts :many
-SELECT id, user_id, title, content, created_at, views_count, comments_count, featured_image_url
+SELECT id, user_id, title, content, created_at, views_count, comments_count, featured_image_url, status, published_at, publish_at, content_html, toc, slug, updated_at
 FROM posts
 WHERE user_id = $1
 ORDER BY created_at
@@ -760,6 +1236,13 @@ func (q *Queries) GetUserPosts(ctx context.Context, arg GetUserPostsParams) ([]P
 			&i.ViewsCount,
 			&i.CommentsCount,
 			&i.FeaturedImageUrl,
+			&i.Status,
+			&i.PublishedAt,
+			&i.PublishAt,
+			&i.ContentHtml,
+			&i.Toc,
+			&i.Slug,
+			&i.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -785,20 +1268,83 @@ func (q *Queries) GetUserPostsCount(ctx context.Context, id uuid.UUID) (int32, e
 	return posts_count, err
 }
 
+const publishPost = `-- name: PublishPost :one
+UPDATE posts
+SET
+    status = 'published',
+    published_at = COALESCE(published_at, NOW())
+WHERE id = $1 AND status <> 'published'
+RETURNING id, user_id, title, content, created_at, views_count, comments_count, featured_image_url, status, published_at, publish_at, content_html, toc, slug, updated_at
+`
+
+func (q *Queries) PublishPost(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, publishPost, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.Content,
+		&i.CreatedAt,
+		&i.ViewsCount,
+		&i.CommentsCount,
+		&i.FeaturedImageUrl,
+		&i.Status,
+		&i.PublishedAt,
+		&i.PublishAt,
+		&i.ContentHtml,
+		&i.Toc,
+		&i.Slug,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const unlistPost = `-- name: UnlistPost :one
+UPDATE posts
+SET status = 'unlisted'
+WHERE id = $1
+RETURNING id, user_id, title, content, created_at, views_count, comments_count, featured_image_url, status, published_at, publish_at, content_html, toc, slug, updated_at
+`
+
+func (q *Queries) UnlistPost(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, unlistPost, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.Content,
+		&i.CreatedAt,
+		&i.ViewsCount,
+		&i.CommentsCount,
+		&i.FeaturedImageUrl,
+		&i.Status,
+		&i.PublishedAt,
+		&i.PublishAt,
+		&i.ContentHtml,
+		&i.Toc,
+		&i.Slug,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const updateComment = `-- name: UpdateComment :one
 UPDATE post_comments
-SET content = $1
-WHERE id = $2
-RETURNING id, post_id, user_id, content, created_at
+SET content = $1, content_html = $2
+WHERE id = $3
+RETURNING id, post_id, user_id, content, created_at, parent_id, replies_count, content_html
 `
 
 type UpdateCommentParams struct {
-	Content string
-	ID      uuid.UUID
+	Content     string
+	ContentHtml sql.NullString
+	ID          uuid.UUID
 }
 
 func (q *Queries) UpdateComment(ctx context.Context, arg UpdateCommentParams) (PostComment, error) {
-	row := q.db.QueryRowContext(ctx, updateComment, arg.Content, arg.ID)
+	row := q.db.QueryRowContext(ctx, updateComment, arg.Content, arg.ContentHtml, arg.ID)
 	var i PostComment
 	err := row.Scan(
 		&i.ID,
@@ -806,23 +1352,46 @@ func (q *Queries) UpdateComment(ctx context.Context, arg UpdateCommentParams) (P
 		&i.UserID,
 		&i.Content,
 		&i.CreatedAt,
+		&i.ParentID,
+		&i.RepliesCount,
+		&i.ContentHtml,
 	)
 	return i, err
 }
 
+const updateCommentRendering = `-- name: UpdateCommentRendering :exec
+UPDATE post_comments
+SET content_html = $1
+WHERE id = $2
+`
+
+type UpdateCommentRenderingParams struct {
+	ContentHtml sql.NullString
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdateCommentRendering(ctx context.Context, arg UpdateCommentRenderingParams) error {
+	_, err := q.db.ExecContext(ctx, updateCommentRendering, arg.ContentHtml, arg.ID)
+	return err
+}
+
 const updatePost = `-- name: UpdatePost :one
 UPDATE posts
 SET 
     title = $1,
     content = $2,
-    featured_image_url = $3
-WHERE id = $4
-RETURNING id, user_id, title, content, created_at, views_count, comments_count, featured_image_url
+    content_html = $3,
+    toc = $4,
+    featured_image_url = $5
+WHERE id = $6
+RETURNING id, user_id, title, content, created_at, views_count, comments_count, featured_image_url, status, published_at, publish_at, content_html, toc, slug, updated_at
 `
 
 type UpdatePostParams struct {
 	Title            string
 	Content          string
+	ContentHtml      sql.NullString
+	Toc              json.RawMessage
 	FeaturedImageUrl sql.NullString
 	ID               uuid.UUID
 }
@@ -831,6 +1400,8 @@ func (q *Queries) UpdatePost(ctx context.Context, arg UpdatePostParams) (Post, e
 	row := q.db.QueryRowContext(ctx, updatePost,
 		arg.Title,
 		arg.Content,
+		arg.ContentHtml,
+		arg.Toc,
 		arg.FeaturedImageUrl,
 		arg.ID,
 	)
@@ -844,10 +1415,34 @@ func (q *Queries) UpdatePost(ctx context.Context, arg UpdatePostParams) (Post, e
 		&i.ViewsCount,
 		&i.CommentsCount,
 		&i.FeaturedImageUrl,
+		&i.Status,
+		&i.PublishedAt,
+		&i.PublishAt,
+		&i.ContentHtml,
+		&i.Toc,
+		&i.Slug,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
 
+const updatePostRendering = `-- name: UpdatePostRendering :exec
+UPDATE posts
+SET content_html = $1, toc = $2
+WHERE id = $3
+`
+
+type UpdatePostRenderingParams struct {
+	ContentHtml sql.NullString
+	Toc         json.RawMessage
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdatePostRendering(ctx context.Context, arg UpdatePostRenderingParams) error {
+	_, err := q.db.ExecContext(ctx, updatePostRendering, arg.ContentHtml, arg.Toc, arg.ID)
+	return err
+}
+
 const viewPost = `-- name: ViewPost :exec
 INSERT INTO post_views(post_id, user_id)
 VALUES($1, $2)
@@ -13,18 +13,25 @@ import (
 )
 
 const createRefreshToken = `-- name: CreateRefreshToken :exec
-INSERT INTO refresh_tokens(token, user_id, expires_at)
-VALUES($1, $2, $3)
+INSERT INTO refresh_tokens(token, user_id, expires_at, family_id)
+VALUES($1, $2, $3, $4)
 `
 
 type CreateRefreshTokenParams struct {
 	Token     string
 	UserID    uuid.UUID
 	ExpiresAt time.Time
+	FamilyID  uuid.UUID
 }
 
+// family_id is the id of the session the token is issued for.
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) error {
-	_, err := q.db.ExecContext(ctx, createRefreshToken, arg.Token, arg.UserID, arg.ExpiresAt)
+	_, err := q.db.ExecContext(ctx, createRefreshToken,
+		arg.Token,
+		arg.UserID,
+		arg.ExpiresAt,
+		arg.FamilyID,
+	)
 	return err
 }
 
@@ -38,7 +45,7 @@ func (q *Queries) DeleteRefreshToken(ctx context.Context, token string) error {
 }
 
 const getRefreshToken = `-- name: GetRefreshToken :one
-SELECT token, user_id, created_at, expires_at FROM refresh_tokens WHERE token = $1
+SELECT token, user_id, created_at, expires_at, family_id, used_at FROM refresh_tokens WHERE token = $1
 `
 
 func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
@@ -49,6 +56,29 @@ func (q *Queries) GetRefreshToken(ctx context.Context, token string) (RefreshTok
 		&i.UserID,
 		&i.CreatedAt,
 		&i.ExpiresAt,
+		&i.FamilyID,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
+const useRefreshToken = `-- name: UseRefreshToken :one
+UPDATE refresh_tokens SET used_at = NOW()
+WHERE token = $1 AND used_at IS NULL
+RETURNING token, user_id, created_at, expires_at, family_id, used_at
+`
+
+// marks the token as used, only if it's not used yet. Concurrent uses of the same token can't both succeed.
+func (q *Queries) UseRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, useRefreshToken, token)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.FamilyID,
+		&i.UsedAt,
 	)
 	return i, err
 }
@@ -10,6 +10,7 @@ import (
 	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const checkFollow = `-- name: CheckFollow :one
@@ -69,7 +70,7 @@ func (q *Queries) CreateFollow(ctx context.Context, arg CreateFollowParams) erro
 const createUser = `-- name: CreateUser :one
 INSERT INTO users(name, username, hashed_password, profile_image_url)
 VALUES($1, $2, $3, $4)
-RETURNING id, name, username, hashed_password, joined_at, posts_count, following_count, followers_count, profile_image_url
+RETURNING id, name, username, hashed_password, joined_at, posts_count, following_count, followers_count, profile_image_url, email, email_verified_at, digest_frequency, last_digest_sent_at, tokens_valid_after
 `
 
 type CreateUserParams struct {
@@ -97,6 +98,11 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.FollowingCount,
 		&i.FollowersCount,
 		&i.ProfileImageUrl,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.DigestFrequency,
+		&i.LastDigestSentAt,
+		&i.TokensValidAfter,
 	)
 	return i, err
 }
@@ -125,7 +131,7 @@ func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
 }
 
 const getAllFollowers = `-- name: GetAllFollowers :many
-SELECT users.id, users.name, users.username, users.hashed_password, users.joined_at, users.posts_count, users.following_count, users.followers_count, users.profile_image_url
+SELECT users.id, users.name, users.username, users.hashed_password, users.joined_at, users.posts_count, users.following_count, users.followers_count, users.profile_image_url, users.email, users.email_verified_at, users.digest_frequency, users.last_digest_sent_at, users.tokens_valid_after
 FROM follows
 JOIN users ON follows.follower_id = users.id
 WHERE
@@ -162,6 +168,11 @@ func (q *Queries) GetAllFollowers(ctx context.Context, arg GetAllFollowersParams
 			&i.FollowingCount,
 			&i.FollowersCount,
 			&i.ProfileImageUrl,
+			&i.Email,
+			&i.EmailVerifiedAt,
+			&i.DigestFrequency,
+			&i.LastDigestSentAt,
+			&i.TokensValidAfter,
 		); err != nil {
 			return nil, err
 		}
@@ -204,7 +215,7 @@ func (q *Queries) GetAllFollowersIDs(ctx context.Context, followedID uuid.UUID)
 }
 
 const getAllUsers = `-- name: GetAllUsers :many
-SELECT id, name, username, hashed_password, joined_at, posts_count, following_count, followers_count, profile_image_url
+SELECT id, name, username, hashed_password, joined_at, posts_count, following_count, followers_count, profile_image_url, email, email_verified_at, digest_frequency, last_digest_sent_at, tokens_valid_after
 FROM users
 WHERE
      -- filter
@@ -255,6 +266,11 @@ func (q *Queries) GetAllUsers(ctx context.Context, arg GetAllUsersParams) ([]Use
 			&i.FollowingCount,
 			&i.FollowersCount,
 			&i.ProfileImageUrl,
+			&i.Email,
+			&i.EmailVerifiedAt,
+			&i.DigestFrequency,
+			&i.LastDigestSentAt,
+			&i.TokensValidAfter,
 		); err != nil {
 			return nil, err
 		}
@@ -281,7 +297,7 @@ func (q *Queries) GetFollowersCount(ctx context.Context, followedID uuid.UUID) (
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, name, username, hashed_password, joined_at, posts_count, following_count, followers_count, profile_image_url FROM users WHERE id = $1
+SELECT id, name, username, hashed_password, joined_at, posts_count, following_count, followers_count, profile_image_url, email, email_verified_at, digest_frequency, last_digest_sent_at, tokens_valid_after FROM users WHERE id = $1
 `
 
 func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
@@ -297,12 +313,17 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.FollowingCount,
 		&i.FollowersCount,
 		&i.ProfileImageUrl,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.DigestFrequency,
+		&i.LastDigestSentAt,
+		&i.TokensValidAfter,
 	)
 	return i, err
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, name, username, hashed_password, joined_at, posts_count, following_count, followers_count, profile_image_url FROM users WHERE username = $1
+SELECT id, name, username, hashed_password, joined_at, posts_count, following_count, followers_count, profile_image_url, email, email_verified_at, digest_frequency, last_digest_sent_at, tokens_valid_after FROM users WHERE username = $1
 `
 
 func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
@@ -318,10 +339,42 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 		&i.FollowingCount,
 		&i.FollowersCount,
 		&i.ProfileImageUrl,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.DigestFrequency,
+		&i.LastDigestSentAt,
+		&i.TokensValidAfter,
 	)
 	return i, err
 }
 
+const getUsersIDsByUsernames = `-- name: GetUsersIDsByUsernames :many
+SELECT id FROM users WHERE username = ANY($1::VARCHAR[])
+`
+
+func (q *Queries) GetUsersIDsByUsernames(ctx context.Context, usernames []string) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersIDsByUsernames, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET 
@@ -330,7 +383,7 @@ SET
     hashed_password = $3,
     profile_image_url = $4
 WHERE id = $5
-RETURNING id, name, username, hashed_password, joined_at, posts_count, following_count, followers_count, profile_image_url
+RETURNING id, name, username, hashed_password, joined_at, posts_count, following_count, followers_count, profile_image_url, email, email_verified_at, digest_frequency, last_digest_sent_at, tokens_valid_after
 `
 
 type UpdateUserParams struct {
@@ -360,6 +413,22 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.FollowingCount,
 		&i.FollowersCount,
 		&i.ProfileImageUrl,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.DigestFrequency,
+		&i.LastDigestSentAt,
+		&i.TokensValidAfter,
 	)
 	return i, err
 }
+
+const updateUserTokensValidAfter = `-- name: UpdateUserTokensValidAfter :exec
+UPDATE users SET tokens_valid_after = date_trunc('second', NOW()) WHERE id = $1
+`
+
+// revokes the access tokens issued to the user so far. It's truncated to the second like the iat
+// of the tokens, so the tokens issued right after, in the same second, are valid.
+func (q *Queries) UpdateUserTokensValidAfter(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, updateUserTokensValidAfter, id)
+	return err
+}
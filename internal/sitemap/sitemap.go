@@ -0,0 +1,64 @@
+// Package sitemap encodes sitemaps and sitemap indexes, as defined by https://www.sitemaps.org/protocol.html.
+package sitemap
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// MaxURLs is the max number of urls in a sitemap. Larger sites are split into sitemaps
+// listed by a sitemap index.
+const MaxURLs = 50_000
+
+const namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type URL struct {
+	Loc     string
+	LastMod time.Time
+}
+
+type urlSet struct {
+	XMLName xml.Name `xml:"urlset"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	URLs    []entry  `xml:"url"`
+}
+
+type index struct {
+	XMLName  xml.Name `xml:"sitemapindex"`
+	XMLNS    string   `xml:"xmlns,attr"`
+	Sitemaps []entry  `xml:"sitemap"`
+}
+
+type entry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// EncodeURLSet encodes a sitemap of urls. The caller keeps them within MaxURLs.
+func EncodeURLSet(urls []URL) ([]byte, error) {
+	return marshal(urlSet{XMLNS: namespace, URLs: entries(urls)})
+}
+
+// EncodeIndex encodes a sitemap index, where each url is the location of a sitemap.
+func EncodeIndex(sitemaps []URL) ([]byte, error) {
+	return marshal(index{XMLNS: namespace, Sitemaps: entries(sitemaps)})
+}
+
+func entries(urls []URL) []entry {
+	result := make([]entry, 0, len(urls))
+	for _, url := range urls {
+		result = append(result, entry{
+			Loc:     url.Loc,
+			LastMod: url.LastMod.UTC().Format(time.RFC3339),
+		})
+	}
+	return result
+}
+
+func marshal(v any) ([]byte, error) {
+	out, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
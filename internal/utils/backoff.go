@@ -0,0 +1,16 @@
+package utils
+
+import "time"
+
+// Backoff returns the delay before retrying after the given number of failed attempts (starting from 1),
+// doubling the base delay on every attempt, up to maxDelay.
+func Backoff(attempts int, base, maxDelay time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return min(delay, maxDelay)
+}
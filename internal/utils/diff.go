@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+)
+
+type DiffOp string
+
+const (
+	DiffOpEqual  DiffOp = "equal"
+	DiffOpInsert DiffOp = "insert"
+	DiffOpDelete DiffOp = "delete"
+)
+
+type DiffLine struct {
+	Op   DiffOp
+	Text string
+}
+
+// MaxDiffLines is the most lines a text can have to be diffed, which bounds the time of a diff.
+const MaxDiffLines = 5_000
+
+// DiffLines computes a line-level diff that turns `a` into `b`.
+// It's based on the longest common subsequence of the lines of both texts, so unchanged lines
+// are reported as equal, and everything else as deleted from `a` or inserted from `b`.
+// The subsequence is found with Hirschberg's algorithm, in linear space, so diffing large texts
+// doesn't allocate a table of len(a)*len(b) entries.
+func DiffLines(a, b string) []DiffLine {
+	aLines, bLines := splitLines(a), splitLines(b)
+	return appendLinesDiff(make([]DiffLine, 0, max(len(aLines), len(bLines))), aLines, bLines)
+}
+
+func appendLinesDiff(diff []DiffLine, a, b []string) []DiffLine {
+	// the common prefix and suffix are equal, and are often most of the text
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		diff = append(diff, DiffLine{Op: DiffOpEqual, Text: a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	a, b, common := a[:len(a)-suffix], b[:len(b)-suffix], a[len(a)-suffix:]
+
+	switch {
+	case len(a) == 0:
+		diff = appendLines(diff, DiffOpInsert, b)
+	case len(b) == 0:
+		diff = appendLines(diff, DiffOpDelete, a)
+	case len(a) == 1:
+		// a single line is either kept, or replaced by all the lines of b
+		if k := slices.Index(b, a[0]); k >= 0 {
+			diff = appendLines(diff, DiffOpInsert, b[:k])
+			diff = append(diff, DiffLine{Op: DiffOpEqual, Text: a[0]})
+			diff = appendLines(diff, DiffOpInsert, b[k+1:])
+		} else {
+			diff = appendLines(diff, DiffOpDelete, a)
+			diff = appendLines(diff, DiffOpInsert, b)
+		}
+	default:
+		// split a in half, and b where the halves' common subsequences add up to the longest one
+		mid := len(a) / 2
+		forward := lcsLengths(a[:mid], b, false)
+		backward := lcsLengths(a[mid:], b, true)
+		split := 0
+		for k := range len(b) + 1 {
+			if forward[k]+backward[len(b)-k] > forward[split]+backward[len(b)-split] {
+				split = k
+			}
+		}
+		diff = appendLinesDiff(diff, a[:mid], b[:split])
+		diff = appendLinesDiff(diff, a[mid:], b[split:])
+	}
+
+	return appendLines(diff, DiffOpEqual, common)
+}
+
+// lcsLengths returns, for every j, the length of the longest common subsequence of a and the first
+// j lines of b, or the last j lines when reversed, keeping only one row of the table at a time.
+func lcsLengths(a, b []string, reversed bool) []int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		ai := a[i]
+		if reversed {
+			ai = a[len(a)-1-i]
+		}
+		for j := 1; j <= len(b); j++ {
+			bj := b[j-1]
+			if reversed {
+				bj = b[len(b)-j]
+			}
+			if ai == bj {
+				cur[j] = prev[j-1] + 1
+			} else {
+				cur[j] = max(prev[j], cur[j-1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+func appendLines(diff []DiffLine, op DiffOp, lines []string) []DiffLine {
+	for _, line := range lines {
+		diff = append(diff, DiffLine{Op: op, Text: line})
+	}
+	return diff
+}
+
+// CountLines returns the number of lines of s, as diffed by DiffLines.
+func CountLines(s string) int {
+	if s == "" {
+		return 0
+	}
+	return strings.Count(s, "\n") + 1
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
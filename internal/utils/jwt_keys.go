@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minJWTRSAKeyBits = 2048
+
+// jwtKey is a key from JWT_KEYS_DIR. Its id (the kid header of the tokens it signs) is its file name
+// without the .pem extension. A key with only a public key can verify tokens, but not sign them.
+type jwtKey struct {
+	id         string
+	method     jwt.SigningMethod
+	privateKey crypto.Signer
+	publicKey  crypto.PublicKey
+}
+
+type jwtKeySet struct {
+	dir          string
+	signingKeyID string
+	signing      *jwtKey
+	keys         []*jwtKey // sorted by id
+}
+
+func (s *jwtKeySet) key(id string) *jwtKey {
+	for _, key := range s.keys {
+		if key.id == id {
+			return key
+		}
+	}
+	return nil
+}
+
+var (
+	jwtKeysMu sync.Mutex
+	jwtKeys   *jwtKeySet
+)
+
+// currentJWTKeys returns the keys in JWT_KEYS_DIR, or nil when it's not set, in which case
+// the tokens are signed with SECRET (HS256). The keys are loaded once, so adding or removing
+// a key takes effect on restart.
+func currentJWTKeys() (*jwtKeySet, error) {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		return nil, nil
+	}
+	signingKeyID := os.Getenv("JWT_SIGNING_KEY_ID")
+
+	jwtKeysMu.Lock()
+	defer jwtKeysMu.Unlock()
+	if jwtKeys != nil && jwtKeys.dir == dir && jwtKeys.signingKeyID == signingKeyID {
+		return jwtKeys, nil
+	}
+	keys, err := loadJWTKeySet(dir, signingKeyID)
+	if err != nil {
+		return nil, err
+	}
+	jwtKeys = keys
+	return keys, nil
+}
+
+// loadJWTKeySet loads the .pem files of dir. The signing key is the one with the given id,
+// or when it's empty, the last private key by name, so naming the keys by their creation date
+// makes the newest key the signing key.
+func loadJWTKeySet(dir, signingKeyID string) (*jwtKeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing JWT keys: %w", err)
+	}
+	slices.Sort(paths)
+
+	keySet := &jwtKeySet{dir: dir, signingKeyID: signingKeyID}
+	for _, path := range paths {
+		keyPem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading JWT key: %w", err)
+		}
+		key, err := parseJWTKey(keyPem)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing JWT key %s: %w", path, err)
+		}
+		key.id = strings.TrimSuffix(filepath.Base(path), ".pem")
+		keySet.keys = append(keySet.keys, key)
+
+		if key.privateKey != nil && (signingKeyID == "" || key.id == signingKeyID) {
+			keySet.signing = key
+		}
+	}
+
+	if keySet.signing == nil {
+		if signingKeyID != "" {
+			return nil, fmt.Errorf("no private key %q in JWT_KEYS_DIR", signingKeyID)
+		}
+		return nil, errors.New("no private key in JWT_KEYS_DIR")
+	}
+	return keySet, nil
+}
+
+// parseJWTKey parses an Ed25519 or RSA key encoded as PEM: a private key (PKCS#8 or PKCS#1),
+// or a public key (PKIX).
+func parseJWTKey(keyPem []byte) (*jwtKey, error) {
+	block, _ := pem.Decode(keyPem)
+	if block == nil {
+		return nil, errors.New("invalid PEM")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unexpected PEM type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case ed25519.PrivateKey:
+		return &jwtKey{method: jwt.SigningMethodEdDSA, privateKey: key, publicKey: key.Public()}, nil
+	case ed25519.PublicKey:
+		return &jwtKey{method: jwt.SigningMethodEdDSA, publicKey: key}, nil
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minJWTRSAKeyBits {
+			return nil, fmt.Errorf("RSA keys must have at least %d bits", minJWTRSAKeyBits)
+		}
+		return &jwtKey{method: jwt.SigningMethodRS256, privateKey: key, publicKey: &key.PublicKey}, nil
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minJWTRSAKeyBits {
+			return nil, fmt.Errorf("RSA keys must have at least %d bits", minJWTRSAKeyBits)
+		}
+		return &jwtKey{method: jwt.SigningMethodRS256, publicKey: key}, nil
+	default:
+		return nil, errors.New("not an Ed25519 or RSA key")
+	}
+}
+
+// JWK is a public key in the JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"` // OKP
+	X   string `json:"x,omitempty"`   // OKP
+	N   string `json:"n,omitempty"`   // RSA
+	E   string `json:"e,omitempty"`   // RSA
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// GetJWKS returns the public keys that verify the access tokens, so other services can verify them
+// without the signing keys. It's empty when the tokens are signed with SECRET.
+func GetJWKS() (JWKS, error) {
+	keys, err := currentJWTKeys()
+	if err != nil {
+		return JWKS{}, err
+	}
+
+	jwks := JWKS{Keys: []JWK{}}
+	if keys == nil {
+		return jwks, nil
+	}
+	for _, key := range keys.keys {
+		jwk := JWK{Kid: key.id, Use: "sig", Alg: key.method.Alg()}
+		switch publicKey := key.publicKey.(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks, nil
+}
+
+// LoadJWTKeys loads the keys in JWT_KEYS_DIR, if any, to report a bad key at startup
+// rather than on the first login.
+func LoadJWTKeys() error {
+	_, err := currentJWTKeys()
+	return err
+}
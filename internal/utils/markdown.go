@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+type TocEntry struct {
+	Level int    `json:"level"`
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+}
+
+var (
+	markdown = goldmark.New(
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	)
+	htmlPolicy = func() *bluemonday.Policy {
+		p := bluemonday.UGCPolicy()
+		// keep the generated heading ids, so the toc entries can link to them.
+		p.AllowAttrs("id").Matching(bluemonday.SpaceSeparatedTokens).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+		return p
+	}()
+)
+
+// RenderMarkdown renders CommonMark source into sanitized HTML that is safe to embed in a page,
+// along with a table of contents built from its headings, in document order.
+func RenderMarkdown(source string) (string, []TocEntry, error) {
+	src := []byte(source)
+	doc := markdown.Parser().Parse(text.NewReader(src))
+
+	toc := []TocEntry{}
+	if err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		id, _ := heading.AttributeString("id")
+		idBytes, _ := id.([]byte)
+		toc = append(toc, TocEntry{
+			Level: heading.Level,
+			ID:    string(idBytes),
+			Text:  nodeText(heading, src),
+		})
+		return ast.WalkSkipChildren, nil
+	}); err != nil {
+		return "", nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := markdown.Renderer().Render(&buf, src, doc); err != nil {
+		return "", nil, err
+	}
+
+	return htmlPolicy.Sanitize(buf.String()), toc, nil
+}
+
+// nodeText returns the plain text of the inline content of n, without any markup.
+func nodeText(n ast.Node, src []byte) string {
+	var sb strings.Builder
+	_ = ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			sb.Write(n.Segment.Value(src))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			sb.Write(n.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(sb.String())
+}
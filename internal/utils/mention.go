@@ -0,0 +1,22 @@
+package utils
+
+import "regexp"
+
+// a mention is an @ followed by a username, that's not part of a word or an email address.
+var mentionRegex = regexp.MustCompile(`(?:^|[^A-Za-z0-9_@])@([A-Za-z0-9_]+)`)
+
+// ExtractMentions returns the usernames mentioned as @username in text, without duplicates,
+// in the order they first appear.
+func ExtractMentions(text string) []string {
+	usernames := []string{}
+	seen := make(map[string]bool)
+	for _, match := range mentionRegex.FindAllStringSubmatch(text, -1) {
+		username := match[1]
+		if seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+	return usernames
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Slugify turns s into a URL friendly slug: lowercase letters and digits separated by single dashes.
+// Any other characters are treated as separators. Returns an empty string if s has no letters or digits.
+func Slugify(s string) string {
+	var sb strings.Builder
+	pendingDash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if pendingDash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			pendingDash = false
+			sb.WriteRune(r)
+		} else {
+			pendingDash = true
+		}
+	}
+	return sb.String()
+}
+
+// UniqueSlug returns base if it's not taken, otherwise base suffixed with the smallest number
+// starting from 2 that makes it not taken, e.g. "hello-world-2".
+func UniqueSlug(base string, taken []string) string {
+	takenSet := make(map[string]bool, len(taken))
+	for _, slug := range taken {
+		takenSet[slug] = true
+	}
+	slug := base
+	for n := 2; takenSet[slug]; n++ {
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+	return slug
+}
@@ -34,38 +34,98 @@ func GenerateRefreshToken() (RefreshToken, error) {
 	}, nil
 }
 
-type jwtClaims struct {
-	UserID uuid.UUID `json:"userID"`
+const EmailVerificationTokenTTL = 24 * time.Hour
+
+type EmailVerificationToken struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+func GenerateEmailVerificationToken() (EmailVerificationToken, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return EmailVerificationToken{}, fmt.Errorf("error generating random bytes: %w", err)
+	}
+	return EmailVerificationToken{
+		Token:     hex.EncodeToString(buf),
+		ExpiresAt: time.Now().Add(EmailVerificationTokenTTL),
+	}, nil
+}
+
+type JWTClaims struct {
+	UserID    uuid.UUID `json:"userID"`
+	SessionID uuid.UUID `json:"sid"` // the session (login) the token is issued for
 	jwt.RegisteredClaims
 }
 
-func GenerateJWTAccessToken(userID uuid.UUID) (string, error) {
+func GenerateJWTAccessToken(userID, sessionID uuid.UUID) (string, error) {
 	minutes, err := strconv.Atoi(os.Getenv("ACCESS_TOKEN_EXPIRATION_MINUTES"))
 	if err != nil {
 		return "", fmt.Errorf("non-numeric env value for ACCESS_TOKEN_EXPIRATION_MINUTES")
 	}
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaims{
-		UserID: userID,
+	keys, err := currentJWTKeys()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := JWTClaims{
+		UserID:    userID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(minutes) * time.Minute)),
+			// the jti identifies the token to revoke it before it expires.
+			ID:        ulid.Make().String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Duration(minutes) * time.Minute)),
 		},
-	})
-	return jwtToken.SignedString([]byte(os.Getenv("SECRET")))
+	}
+
+	if keys == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(os.Getenv("SECRET")))
+	}
+	jwtToken := jwt.NewWithClaims(keys.signing.method, claims)
+	jwtToken.Header["kid"] = keys.signing.id
+	return jwtToken.SignedString(keys.signing.privateKey)
 }
 
 // ParseJWTTokenString parses a JWT token string and returns its claims.
 // Returns an error if the token is malformed, has an invalid signature, or uses an unexpected signing method.
-func ParseJWTTokenString(tokenString string) (*jwtClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwtClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if token.Method.Alg() != jwt.SigningMethodHS256.Name {
+//
+// Once JWT_KEYS_DIR is set, tokens signed with SECRET (HS256) are only accepted with JWT_ACCEPT_HS256=true,
+// so the tokens issued before the switch can keep working until they expire.
+func ParseJWTTokenString(tokenString string) (*JWTClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+		keys, err := currentJWTKeys()
+		if err != nil {
+			return nil, err
+		}
+
+		if token.Method.Alg() == jwt.SigningMethodHS256.Name {
+			secret := os.Getenv("SECRET")
+			if secret == "" || (keys != nil && os.Getenv("JWT_ACCEPT_HS256") != "true") {
+				return nil, fmt.Errorf("unexpected signing method")
+			}
+			return []byte(secret), nil
+		}
+
+		if keys == nil {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		kid, _ := token.Header["kid"].(string)
+		key := keys.key(kid)
+		if key == nil {
+			return nil, fmt.Errorf("unknown key id")
+		}
+		// the key decides the algorithm, not the token
+		if token.Method.Alg() != key.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method")
 		}
-		return []byte(os.Getenv("SECRET")), nil
+		return key.publicKey, nil
 	})
 	if err != nil {
 		return nil, jwt.ErrTokenSignatureInvalid
 	}
-	claims, ok := token.Claims.(*jwtClaims)
+	claims, ok := token.Claims.(*JWTClaims)
 	if !ok {
 		return nil, jwt.ErrTokenInvalidClaims
 	}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP (RFC 6238) with the parameters every authenticator app supports: HMAC-SHA1, 6 digits and 30s steps.
+const (
+	TOTPDigits = 6
+	TOTPPeriod = 30 * time.Second
+
+	totpSecretSize = 20 // 160 bits, as recommended by RFC 4226
+	// the number of steps before and after the current one accepted, for clock drift.
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret generates a random secret, encoded in base32 like authenticator apps expect it.
+func GenerateTOTPSecret() (string, error) {
+	buf := make([]byte, totpSecretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating random bytes: %w", err)
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// TOTPURI returns the otpauth:// URI of the secret, which authenticator apps scan as a QR code.
+func TOTPURI(issuer, accountName, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod.Seconds())))
+	return (&url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + accountName,
+		RawQuery: query.Encode(),
+	}).String()
+}
+
+// TOTPStep returns the time step of t, which is the HOTP counter.
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod.Seconds())
+}
+
+// HOTP computes the HOTP (RFC 4226) code of the counter with the given number of digits.
+func HOTP(key []byte, counter int64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for range digits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
+
+// ValidateTOTP checks the code against the secret at now, allowing for a step of clock drift.
+// It returns the step of the matching code, so it can be stored to reject replaying it.
+func ValidateTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != TOTPDigits {
+		return 0, false
+	}
+	step := TOTPStep(now)
+	for i := -totpSkew; i <= totpSkew; i++ {
+		expected := HOTP(key, step+int64(i), TOTPDigits)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step + int64(i), true
+		}
+	}
+	return 0, false
+}
+
+const (
+	RecoveryCodesCount = 10
+	recoveryCodeSize   = 10 // base32 characters, ~48 random bits
+)
+
+// GenerateRecoveryCodes generates one-time codes to log in without the authenticator,
+// formatted as two groups of 5 lowercase base32 characters (e.g. "abcde-fghij").
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodesCount)
+	for i := range codes {
+		buf := make([]byte, recoveryCodeSize*5/8)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("error generating random bytes: %w", err)
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(buf))
+		codes[i] = code[:recoveryCodeSize/2] + "-" + code[recoveryCodeSize/2:]
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode hashes a recovery code to be stored. The codes are random, so a fast hash is enough.
+// The code is normalized first, so it can be entered without the dash or in uppercase.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+const MFATokenTTL = 5 * time.Minute
+
+type MFAToken struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// GenerateMFAToken generates the token returned by the login of a user with two-factor authentication,
+// to be exchanged for the access and refresh tokens with a code.
+func GenerateMFAToken() (MFAToken, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return MFAToken{}, fmt.Errorf("error generating random bytes: %w", err)
+	}
+	return MFAToken{
+		Token:     hex.EncodeToString(buf),
+		ExpiresAt: time.Now().Add(MFATokenTTL),
+	}, nil
+}
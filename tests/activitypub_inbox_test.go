@@ -0,0 +1,158 @@
+//go:build integration
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/activitypub"
+	"github.com/assaidy/blogging_app/internal/db/postgres_db"
+	"github.com/assaidy/blogging_app/internal/handler"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/gofiber/fiber/v2"
+	"github.com/oklog/ulid/v2"
+)
+
+// TestActorInboxFollowAndUndo sends a signed Follow then an Undo from a stub remote server to the inbox
+// of a local user. It needs a migrated database: `PG_URL=... go test -tags integration ./tests/...`.
+func TestActorInboxFollowAndUndo(t *testing.T) {
+	t.Setenv("APP_BASE_URL", "http://local.test")
+	t.Setenv("ACTIVITYPUB_ALLOW_HTTP", "true")
+	t.Setenv("ACTIVITYPUB_ALLOW_PRIVATE_ADDRESSES", "true")
+
+	queries := postgres_repo.New(postgres_db.DB)
+	user, err := queries.CreateUser(context.Background(), postgres_repo.CreateUserParams{
+		Name:            "inbox test",
+		Username:        "inbox_" + ulid.Make().String()[16:],
+		HashedPassword:  "not a hash",
+		ProfileImageUrl: sql.NullString{},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error creating user: %v", err)
+	}
+	defer queries.DeleteUser(context.Background(), user.ID)
+	localActorURL := "http://local.test/ap/users/" + user.ID.String()
+
+	remoteKey, remotePublicKeyPem := generateTestKey(t)
+	accepts := make(chan activitypub.Activity, 1)
+	var stub *httptest.Server
+	stub = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/users/john":
+			w.Header().Set("Content-Type", activitypub.ContentType)
+			json.NewEncoder(w).Encode(activitypub.Actor{
+				ID:                stub.URL + "/users/john",
+				Type:              "Person",
+				PreferredUsername: "john",
+				Inbox:             stub.URL + "/users/john/inbox",
+				PublicKey: activitypub.PublicKey{
+					ID:           stub.URL + "/users/john#main-key",
+					Owner:        stub.URL + "/users/john",
+					PublicKeyPem: remotePublicKeyPem,
+				},
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/users/john/inbox":
+			body, _ := io.ReadAll(r.Body)
+			var activity activitypub.Activity
+			if err := json.Unmarshal(body, &activity); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			accepts <- activity
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer stub.Close()
+	remoteActorURL := stub.URL + "/users/john"
+
+	app := fiber.New()
+	app.Post("/ap/users/:user_id/inbox", handler.HandleActorInbox)
+
+	postToInbox := func(activity any) int {
+		t.Helper()
+		body, err := json.Marshal(activity)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPost, localActorURL+"/inbox", bytes.NewReader(body))
+		req.Header.Set("Content-Type", activitypub.ContentType)
+		if err := activitypub.SignRequest(req, body, remoteActorURL+"#main-key", remoteKey, time.Now()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	remoteFollowersCount := func() int64 {
+		t.Helper()
+		count, err := queries.GetRemoteFollowersCount(context.Background(), user.ID)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return count
+	}
+
+	follow := map[string]any{
+		"@context": activitypub.ActivityStreamsContext,
+		"id":       remoteActorURL + "#follows/1",
+		"type":     "Follow",
+		"actor":    remoteActorURL,
+		"object":   localActorURL,
+	}
+	if status := postToInbox(follow); status != fiber.StatusAccepted {
+		t.Fatalf("Expected status %d for the Follow, got: %d", fiber.StatusAccepted, status)
+	}
+	if count := remoteFollowersCount(); count != 1 {
+		t.Fatalf("Expected 1 remote follower, got: %d", count)
+	}
+
+	handler.StartActivityPubWorkers()
+	defer handler.StopActivityPubWorkers()
+	select {
+	case accept := <-accepts:
+		if accept.Type != "Accept" || accept.Actor != localActorURL {
+			t.Errorf("Expected an Accept from the local actor, got: %+v", accept)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the stub to receive the Accept")
+	}
+
+	undo := map[string]any{
+		"@context": activitypub.ActivityStreamsContext,
+		"id":       remoteActorURL + "#undos/1",
+		"type":     "Undo",
+		"actor":    remoteActorURL,
+		"object":   follow,
+	}
+	if status := postToInbox(undo); status != fiber.StatusAccepted {
+		t.Fatalf("Expected status %d for the Undo, got: %d", fiber.StatusAccepted, status)
+	}
+	if count := remoteFollowersCount(); count != 0 {
+		t.Errorf("Expected no remote followers, got: %d", count)
+	}
+
+	// an unsigned request is rejected
+	body, _ := json.Marshal(follow)
+	req := httptest.NewRequest(http.MethodPost, localActorURL+"/inbox", bytes.NewReader(body))
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("Expected status %d for an unsigned request, got: %d", fiber.StatusUnauthorized, resp.StatusCode)
+	}
+}
@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/activitypub"
+)
+
+func generateTestKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+	privateKeyPem, publicKeyPem, err := activitypub.GenerateKey()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	key, err := activitypub.ParsePrivateKey(privateKeyPem)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return key, publicKeyPem
+}
+
+func verifyTestRequest(req *http.Request, body []byte, key *rsa.PublicKey, now time.Time) error {
+	signature, err := activitypub.ParseSignature(req.Header.Get("Signature"))
+	if err != nil {
+		return err
+	}
+	return signature.Verify(req.Method, req.URL.RequestURI(), activitypub.RequestHeader(req), body, key, now)
+}
+
+func TestSignAndVerifyRequest(t *testing.T) {
+	key, _ := generateTestKey(t)
+	otherKey, _ := generateTestKey(t)
+	body := []byte(`{"type":"Follow"}`)
+	now := time.Now()
+
+	newSignedRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "https://remote.example/users/john/inbox", bytes.NewReader(body))
+		if err := activitypub.SignRequest(req, body, "https://local.example/actor#main-key", key, now); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return req
+	}
+
+	tests := []struct {
+		name   string
+		modify func(req *http.Request) (body []byte, key *rsa.PublicKey, now time.Time)
+		valid  bool
+	}{
+		{
+			name: "valid",
+			modify: func(req *http.Request) ([]byte, *rsa.PublicKey, time.Time) {
+				return body, &key.PublicKey, now
+			},
+			valid: true,
+		},
+		{
+			name: "tampered body",
+			modify: func(req *http.Request) ([]byte, *rsa.PublicKey, time.Time) {
+				return []byte(`{"type":"Undo"}`), &key.PublicKey, now
+			},
+		},
+		{
+			name: "other key",
+			modify: func(req *http.Request) ([]byte, *rsa.PublicKey, time.Time) {
+				return body, &otherKey.PublicKey, now
+			},
+		},
+		{
+			name: "other target",
+			modify: func(req *http.Request) ([]byte, *rsa.PublicKey, time.Time) {
+				req.URL.Path = "/users/jane/inbox"
+				return body, &key.PublicKey, now
+			},
+		},
+		{
+			name: "expired",
+			modify: func(req *http.Request) ([]byte, *rsa.PublicKey, time.Time) {
+				return body, &key.PublicKey, now.Add(activitypub.MaxClockSkew + time.Minute)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := newSignedRequest()
+			body, publicKey, now := test.modify(req)
+			err := verifyTestRequest(req, body, publicKey, now)
+			if test.valid && err != nil {
+				t.Errorf("Expected a valid signature, got error: %v", err)
+			}
+			if !test.valid && !errors.Is(err, activitypub.ErrInvalidSignature) {
+				t.Errorf("Expected ErrInvalidSignature, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	signature, err := activitypub.ParseSignature(`keyId="https://remote.example/users/john#main-key",algorithm="rsa-sha256",headers="(request-target) host date digest",signature="c2lnbmF0dXJl"`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if signature.KeyID != "https://remote.example/users/john#main-key" {
+		t.Errorf("Unexpected keyId: %s", signature.KeyID)
+	}
+	if len(signature.Headers) != 4 || string(signature.Signature) != "signature" {
+		t.Errorf("Unexpected signature: %+v", signature)
+	}
+
+	if _, err := activitypub.ParseSignature(""); !errors.Is(err, activitypub.ErrMissingSignature) {
+		t.Errorf("Expected ErrMissingSignature, got: %v", err)
+	}
+	if _, err := activitypub.ParseSignature(`algorithm="rsa-sha256",signature="c2ln"`); !errors.Is(err, activitypub.ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a missing keyId, got: %v", err)
+	}
+}
+
+func TestActivityObject(t *testing.T) {
+	var follow activitypub.Activity
+	if err := json.Unmarshal([]byte(`{"type":"Follow","actor":"https://remote.example/users/john","object":"https://local.example/ap/users/1"}`), &follow); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if follow.ObjectID() != "https://local.example/ap/users/1" {
+		t.Errorf("Unexpected object id: %s", follow.ObjectID())
+	}
+
+	var undo activitypub.Activity
+	if err := json.Unmarshal([]byte(`{"type":"Undo","actor":"https://remote.example/users/john","object":{"id":"https://remote.example/follows/1","type":"Follow","actor":"https://remote.example/users/john"}}`), &undo); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if undo.ObjectID() != "https://remote.example/follows/1" {
+		t.Errorf("Unexpected object id: %s", undo.ObjectID())
+	}
+	undone, err := undo.EmbeddedActivity()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if undone.Type != "Follow" || undone.Actor != "https://remote.example/users/john" {
+		t.Errorf("Unexpected undone activity: %+v", undone)
+	}
+}
+
+// TestClientAgainstStubInstance fetches an actor from, and delivers an activity to, a local stub of a
+// remote server, which checks the signatures of the requests like Mastodon does.
+func TestClientAgainstStubInstance(t *testing.T) {
+	localKey, _ := generateTestKey(t)
+	_, remotePublicKeyPem := generateTestKey(t)
+	const localKeyID = "http://local.example/ap/users/1#main-key"
+
+	var (
+		mu       sync.Mutex
+		received []activitypub.Activity
+	)
+	var stub *httptest.Server
+	stub = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := verifyTestRequest(r, body, &localKey.PublicKey, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/users/john":
+			w.Header().Set("Content-Type", activitypub.ContentType)
+			json.NewEncoder(w).Encode(activitypub.Actor{
+				ID:                stub.URL + "/users/john",
+				Type:              "Person",
+				PreferredUsername: "john",
+				Inbox:             stub.URL + "/users/john/inbox",
+				PublicKey: activitypub.PublicKey{
+					ID:           stub.URL + "/users/john#main-key",
+					Owner:        stub.URL + "/users/john",
+					PublicKeyPem: remotePublicKeyPem,
+				},
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/users/john/inbox":
+			var activity activitypub.Activity
+			if err := json.Unmarshal(body, &activity); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			mu.Lock()
+			received = append(received, activity)
+			mu.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer stub.Close()
+
+	client := &activitypub.Client{HTTP: stub.Client(), AllowHTTP: true}
+
+	// the key id has a fragment, which isn't part of the actor url
+	actor, err := client.FetchActor(context.Background(), stub.URL+"/users/john#main-key", localKeyID, localKey)
+	if err != nil {
+		t.Fatalf("Unexpected error fetching actor: %v", err)
+	}
+	if actor.PublicKey.PublicKeyPem != remotePublicKeyPem {
+		t.Errorf("Expected the public key of the actor")
+	}
+
+	accept := activitypub.Activity{
+		ID:     "http://local.example/ap/users/1#accepts/1",
+		Type:   "Accept",
+		Actor:  "http://local.example/ap/users/1",
+		Object: json.RawMessage(`{"type":"Follow","actor":"` + actor.ID + `"}`),
+	}
+	if err := client.Deliver(context.Background(), actor.Inbox, accept, localKeyID, localKey); err != nil {
+		t.Fatalf("Unexpected error delivering activity: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || received[0].Type != "Accept" {
+		t.Errorf("Expected the stub to receive the Accept, got: %+v", received)
+	}
+
+	if _, err := client.FetchActor(context.Background(), stub.URL+"/users/jane", localKeyID, localKey); err == nil {
+		t.Error("Expected an error fetching an unknown actor")
+	}
+
+	insecureClient := &activitypub.Client{HTTP: stub.Client()}
+	if _, err := insecureClient.FetchActor(context.Background(), actor.ID, localKeyID, localKey); !errors.Is(err, activitypub.ErrInsecureURL) {
+		t.Errorf("Expected ErrInsecureURL without AllowHTTP, got: %v", err)
+	}
+}
+
+// TestSignatureCheck checks the requests rejected before fetching the key of the signer.
+func TestSignatureCheck(t *testing.T) {
+	key, _ := generateTestKey(t)
+	body := []byte(`{"type":"Follow"}`)
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		sign  func(req *http.Request)
+		valid bool
+	}{
+		{
+			name: "valid",
+			sign: func(req *http.Request) {
+				activitypub.SignRequest(req, body, "https://remote.example/actor#main-key", key, now)
+			},
+			valid: true,
+		},
+		{
+			name: "stale date",
+			sign: func(req *http.Request) {
+				activitypub.SignRequest(req, body, "https://remote.example/actor#main-key", key, now.Add(-2*activitypub.MaxClockSkew))
+			},
+		},
+		{
+			name: "digest not signed",
+			sign: func(req *http.Request) {
+				activitypub.SignRequest(req, nil, "https://remote.example/actor#main-key", key, now)
+			},
+		},
+		{
+			name: "tampered digest",
+			sign: func(req *http.Request) {
+				activitypub.SignRequest(req, body, "https://remote.example/actor#main-key", key, now)
+				req.Header.Set("Digest", activitypub.Digest([]byte(`{"type":"Undo"}`)))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "https://local.example/ap/users/1/inbox", bytes.NewReader(body))
+			test.sign(req)
+			signature, err := activitypub.ParseSignature(req.Header.Get("Signature"))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			err = signature.Check(req.Method, req.URL.RequestURI(), activitypub.RequestHeader(req), body, now)
+			if got := err == nil; got != test.valid {
+				t.Errorf("Expected valid: %v, got error: %v", test.valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/utils"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		expected time.Duration
+	}{
+		{name: "First Attempt", attempts: 1, expected: time.Second},
+		{name: "Second Attempt", attempts: 2, expected: 2 * time.Second},
+		{name: "Fifth Attempt", attempts: 5, expected: 16 * time.Second},
+		{name: "Capped", attempts: 10, expected: time.Minute},
+		{name: "Many Attempts", attempts: 1000, expected: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := utils.Backoff(tt.attempts, time.Second, time.Minute); got != tt.expected {
+				t.Errorf("Expected: %v, got: %v", tt.expected, got)
+			}
+		})
+	}
+}
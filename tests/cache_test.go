@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTLCache(t *testing.T) {
+	cache := utils.NewTTLCache[string, int](50*time.Millisecond, 10)
+
+	cache.Set("a", 1)
+	value, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+
+	time.Sleep(60 * time.Millisecond)
+	_, ok = cache.Get("a")
+	assert.False(t, ok, "expected the entry to expire")
+}
+
+func TestTTLCacheMaxEntries(t *testing.T) {
+	cache := utils.NewTTLCache[int, int](time.Minute, 3)
+	for i := range 3 {
+		cache.Set(i, i)
+	}
+	cache.Set(1, 10) // replacing an entry doesn't evict
+	assert.Equal(t, 3, cache.Len())
+
+	cache.Set(3, 3) // a full cache without expired entries is cleared
+	assert.Equal(t, 1, cache.Len())
+	value, ok := cache.Get(3)
+	assert.True(t, ok)
+	assert.Equal(t, 3, value)
+}
+
+func TestTTLCacheDeleteFunc(t *testing.T) {
+	cache := utils.NewTTLCache[string, string](time.Minute, 10)
+	cache.Set("token1", "user1")
+	cache.Set("token2", "user2")
+	cache.Set("token3", "user1")
+
+	cache.DeleteFunc(func(_ string, userID string) bool { return userID == "user1" })
+
+	assert.Equal(t, 1, cache.Len())
+	_, ok := cache.Get("token2")
+	assert.True(t, ok)
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/assaidy/blogging_app/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected []utils.DiffLine
+	}{
+		{
+			name: "Identical",
+			a:    "one\ntwo",
+			b:    "one\ntwo",
+			expected: []utils.DiffLine{
+				{Op: utils.DiffOpEqual, Text: "one"},
+				{Op: utils.DiffOpEqual, Text: "two"},
+			},
+		},
+		{
+			name: "Inserted Line",
+			a:    "one\nthree",
+			b:    "one\ntwo\nthree",
+			expected: []utils.DiffLine{
+				{Op: utils.DiffOpEqual, Text: "one"},
+				{Op: utils.DiffOpInsert, Text: "two"},
+				{Op: utils.DiffOpEqual, Text: "three"},
+			},
+		},
+		{
+			name: "Deleted Line",
+			a:    "one\ntwo\nthree",
+			b:    "one\nthree",
+			expected: []utils.DiffLine{
+				{Op: utils.DiffOpEqual, Text: "one"},
+				{Op: utils.DiffOpDelete, Text: "two"},
+				{Op: utils.DiffOpEqual, Text: "three"},
+			},
+		},
+		{
+			name: "Changed Line",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			expected: []utils.DiffLine{
+				{Op: utils.DiffOpEqual, Text: "one"},
+				{Op: utils.DiffOpDelete, Text: "two"},
+				{Op: utils.DiffOpInsert, Text: "2"},
+				{Op: utils.DiffOpEqual, Text: "three"},
+			},
+		},
+		{
+			name: "From Empty",
+			a:    "",
+			b:    "one\ntwo",
+			expected: []utils.DiffLine{
+				{Op: utils.DiffOpInsert, Text: "one"},
+				{Op: utils.DiffOpInsert, Text: "two"},
+			},
+		},
+		{
+			name:     "Both Empty",
+			a:        "",
+			b:        "",
+			expected: []utils.DiffLine{},
+		},
+		{
+			name: "CRLF Line Endings",
+			a:    "one\r\ntwo",
+			b:    "one\ntwo",
+			expected: []utils.DiffLine{
+				{Op: utils.DiffOpEqual, Text: "one"},
+				{Op: utils.DiffOpEqual, Text: "two"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, utils.DiffLines(tt.a, tt.b))
+		})
+	}
+}
+
+// TestDiffLinesIsMinimal checks on random texts that the diff turns a into b, and keeps as many lines
+// as their longest common subsequence, computed with the full table.
+func TestDiffLinesIsMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomText := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for i := range lines {
+			lines[i] = fmt.Sprint(rng.Intn(5))
+		}
+		return lines
+	}
+
+	for range 200 {
+		a, b := randomText(), randomText()
+		diff := utils.DiffLines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+
+		var gotA, gotB []string
+		equal := 0
+		for _, line := range diff {
+			if line.Op != utils.DiffOpInsert {
+				gotA = append(gotA, line.Text)
+			}
+			if line.Op != utils.DiffOpDelete {
+				gotB = append(gotB, line.Text)
+			}
+			if line.Op == utils.DiffOpEqual {
+				equal++
+			}
+		}
+		assert.Equal(t, strings.Join(a, "\n"), strings.Join(gotA, "\n"))
+		assert.Equal(t, strings.Join(b, "\n"), strings.Join(gotB, "\n"))
+		assert.Equal(t, lcsLength(a, b), equal, "a=%v b=%v", a, b)
+	}
+}
+
+func lcsLength(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	return lcs[0][0]
+}
+
+func TestCountLines(t *testing.T) {
+	assert.Equal(t, 0, utils.CountLines(""))
+	assert.Equal(t, 1, utils.CountLines("one"))
+	assert.Equal(t, 3, utils.CountLines("one\r\ntwo\n"))
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/feeds"
+)
+
+var testFeed = feeds.Feed{
+	Title:       "Latest posts",
+	Link:        "http://localhost",
+	FeedLink:    "http://localhost/api/v1/feed.rss",
+	Description: "Latest posts from all authors",
+	Updated:     time.Date(2025, 3, 2, 10, 0, 0, 0, time.UTC),
+	Items: []feeds.Item{
+		{
+			ID:          "urn:uuid:0195f2a4-0000-7000-8000-000000000001",
+			Title:       "Hello & welcome",
+			Link:        "http://localhost/api/v1/users/username/john/posts/hello-welcome",
+			Author:      "John",
+			ContentHTML: "<p>Hello <strong>world</strong></p>",
+			Published:   time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC),
+			Updated:     time.Date(2025, 3, 2, 10, 0, 0, 0, time.UTC),
+		},
+	},
+}
+
+func TestFeedRSS(t *testing.T) {
+	out, err := testFeed.RSS()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Channel struct {
+			Title string `xml:"title"`
+			Items []struct {
+				Title       string `xml:"title"`
+				GUID        string `xml:"guid"`
+				Description string `xml:"description"`
+				PubDate     string `xml:"pubDate"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("Expected valid XML, got error: %v\n%s", err, out)
+	}
+	if doc.Version != "2.0" || doc.Channel.Title != testFeed.Title {
+		t.Errorf("Unexpected channel: %+v", doc)
+	}
+	if len(doc.Channel.Items) != 1 {
+		t.Fatalf("Expected 1 item, got %d", len(doc.Channel.Items))
+	}
+	item := doc.Channel.Items[0]
+	if item.Title != "Hello & welcome" || item.GUID != testFeed.Items[0].ID {
+		t.Errorf("Unexpected item: %+v", item)
+	}
+	if item.Description != testFeed.Items[0].ContentHTML {
+		t.Errorf("Expected the HTML content to round trip, got %q", item.Description)
+	}
+	if item.PubDate != "Sat, 01 Mar 2025 09:30:00 +0000" {
+		t.Errorf("Expected an RFC 1123 date, got %q", item.PubDate)
+	}
+}
+
+func TestFeedAtom(t *testing.T) {
+	out, err := testFeed.Atom()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		Updated string   `xml:"updated"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Author  string `xml:"author>name"`
+			Content struct {
+				Type  string `xml:"type,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"content"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("Expected valid Atom XML, got error: %v\n%s", err, out)
+	}
+	if doc.Updated != "2025-03-02T10:00:00Z" {
+		t.Errorf("Expected an RFC 3339 date, got %q", doc.Updated)
+	}
+	if len(doc.Entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(doc.Entries))
+	}
+	entry := doc.Entries[0]
+	if entry.ID != testFeed.Items[0].ID || entry.Author != "John" {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+	if entry.Content.Type != "html" || entry.Content.Value != testFeed.Items[0].ContentHTML {
+		t.Errorf("Unexpected content: %+v", entry.Content)
+	}
+}
+
+func TestFeedJSON(t *testing.T) {
+	out, err := testFeed.JSON()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var doc struct {
+		Version string `json:"version"`
+		FeedURL string `json:"feed_url"`
+		Items   []struct {
+			ID            string `json:"id"`
+			ContentHTML   string `json:"content_html"`
+			DatePublished string `json:"date_published"`
+			Authors       []struct {
+				Name string `json:"name"`
+			} `json:"authors"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("Expected valid JSON, got error: %v", err)
+	}
+	if doc.Version != "https://jsonfeed.org/version/1.1" || doc.FeedURL != testFeed.FeedLink {
+		t.Errorf("Unexpected feed: %+v", doc)
+	}
+	if len(doc.Items) != 1 {
+		t.Fatalf("Expected 1 item, got %d", len(doc.Items))
+	}
+	item := doc.Items[0]
+	if item.ContentHTML != testFeed.Items[0].ContentHTML || item.DatePublished != "2025-03-01T09:30:00Z" {
+		t.Errorf("Unexpected item: %+v", item)
+	}
+	if len(item.Authors) != 1 || item.Authors[0].Name != "John" {
+		t.Errorf("Unexpected authors: %+v", item.Authors)
+	}
+}
+
+func TestFeedEmpty(t *testing.T) {
+	feed := feeds.Feed{Title: "Empty", Updated: time.Unix(0, 0)}
+	out, err := feed.JSON()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(string(out), `"items":[]`) {
+		t.Errorf("Expected an empty items array, got %s", out)
+	}
+}
+
+func TestFeedFormat(t *testing.T) {
+	for _, format := range []string{"rss", "atom", "json"} {
+		if !feeds.Format(format).IsValid() {
+			t.Errorf("Expected %q to be a valid format", format)
+		}
+	}
+	if feeds.Format("xml").IsValid() {
+		t.Error("Expected xml to be an invalid format")
+	}
+}
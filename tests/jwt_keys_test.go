@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/assaidy/blogging_app/internal/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestKey(t *testing.T, dir, id string, key any) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, id+".pem"), keyPem, 0o600))
+}
+
+func setJWTKeysEnv(t *testing.T, dir, signingKeyID string) {
+	t.Setenv("ACCESS_TOKEN_EXPIRATION_MINUTES", "30")
+	t.Setenv("SECRET", "")
+	t.Setenv("JWT_KEYS_DIR", dir)
+	t.Setenv("JWT_SIGNING_KEY_ID", signingKeyID)
+}
+
+func tokenKeyID(t *testing.T, tokenString string) string {
+	t.Helper()
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, &jwt.RegisteredClaims{})
+	require.NoError(t, err)
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+func TestJWTKeysSignAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	writeTestKey(t, dir, "2025-01-01", rsaKey)
+	writeTestKey(t, dir, "2025-02-01", edKey)
+
+	// the last key by name signs
+	setJWTKeysEnv(t, dir, "")
+	userID := uuid.New()
+	edToken, err := utils.GenerateJWTAccessToken(userID, uuid.New())
+	require.NoError(t, err)
+	assert.Equal(t, "2025-02-01", tokenKeyID(t, edToken))
+
+	// unless another one is chosen
+	setJWTKeysEnv(t, dir, "2025-01-01")
+	rsaToken, err := utils.GenerateJWTAccessToken(userID, uuid.New())
+	require.NoError(t, err)
+	assert.Equal(t, "2025-01-01", tokenKeyID(t, rsaToken))
+
+	// both keys verify their tokens
+	for _, tokenString := range []string{edToken, rsaToken} {
+		claims, err := utils.ParseJWTTokenString(tokenString)
+		require.NoError(t, err)
+		assert.Equal(t, userID, claims.UserID)
+	}
+
+	// a token of a removed key isn't accepted
+	rotatedDir := t.TempDir()
+	writeTestKey(t, rotatedDir, "2025-01-01", rsaKey)
+	setJWTKeysEnv(t, rotatedDir, "")
+	_, err = utils.ParseJWTTokenString(edToken)
+	assert.Error(t, err)
+	_, err = utils.ParseJWTTokenString(rsaToken)
+	assert.NoError(t, err)
+}
+
+func TestJWTKeysRejectForgedTokens(t *testing.T) {
+	dir := t.TempDir()
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writeTestKey(t, dir, "key", edKey)
+	setJWTKeysEnv(t, dir, "")
+
+	tokenString, err := utils.GenerateJWTAccessToken(uuid.New(), uuid.New())
+	require.NoError(t, err)
+
+	// signed by another key with the same kid
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.RegisteredClaims{Subject: "forged"})
+	forged.Header["kid"] = "key"
+	forgedString, err := forged.SignedString(otherKey)
+	require.NoError(t, err)
+	_, err = utils.ParseJWTTokenString(forgedString)
+	assert.Error(t, err)
+
+	// HS256 isn't accepted without SECRET
+	hsString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{}).SignedString([]byte("guess"))
+	require.NoError(t, err)
+	_, err = utils.ParseJWTTokenString(hsString)
+	assert.Error(t, err)
+
+	// a tampered payload
+	parts := strings.Split(tokenString, ".")
+	_, err = utils.ParseJWTTokenString(parts[0] + "." + parts[1] + "x." + parts[2])
+	assert.Error(t, err)
+}
+
+func TestJWTKeysSecretFallback(t *testing.T) {
+	t.Setenv("ACCESS_TOKEN_EXPIRATION_MINUTES", "30")
+	t.Setenv("SECRET", "test-secret")
+	t.Setenv("JWT_KEYS_DIR", "")
+
+	hsToken, err := utils.GenerateJWTAccessToken(uuid.New(), uuid.New())
+	require.NoError(t, err)
+	assert.Empty(t, tokenKeyID(t, hsToken))
+
+	jwks, err := utils.GetJWKS()
+	require.NoError(t, err)
+	assert.Empty(t, jwks.Keys)
+
+	_, err = utils.ParseJWTTokenString(hsToken)
+	assert.NoError(t, err)
+
+	// HS256 tokens are rejected once keys are configured, even with SECRET set
+	dir := t.TempDir()
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writeTestKey(t, dir, "key", edKey)
+	t.Setenv("JWT_KEYS_DIR", dir)
+	_, err = utils.ParseJWTTokenString(hsToken)
+	assert.Error(t, err)
+
+	// unless they're accepted while switching to keys
+	t.Setenv("JWT_ACCEPT_HS256", "true")
+	_, err = utils.ParseJWTTokenString(hsToken)
+	assert.NoError(t, err)
+}
+
+func TestGetJWKS(t *testing.T) {
+	dir := t.TempDir()
+	edPublicKey, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	writeTestKey(t, dir, "ed", edKey)
+	writeTestKey(t, dir, "rsa", rsaKey)
+	setJWTKeysEnv(t, dir, "")
+
+	jwks, err := utils.GetJWKS()
+	require.NoError(t, err)
+	require.Len(t, jwks.Keys, 2)
+
+	ed := jwks.Keys[0]
+	assert.Equal(t, utils.JWK{Kty: "OKP", Kid: "ed", Use: "sig", Alg: "EdDSA", Crv: "Ed25519", X: jwtBase64(edPublicKey)}, ed)
+	rsaJWK := jwks.Keys[1]
+	assert.Equal(t, "RSA", rsaJWK.Kty)
+	assert.Equal(t, "RS256", rsaJWK.Alg)
+	assert.Equal(t, jwtBase64(rsaKey.N.Bytes()), rsaJWK.N)
+	assert.Equal(t, "AQAB", rsaJWK.E) // 65537
+}
+
+func TestJWTKeysInvalidDir(t *testing.T) {
+	dir := t.TempDir()
+	setJWTKeysEnv(t, dir, "")
+	assert.Error(t, utils.LoadJWTKeys(), "expected an error without keys")
+
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	writeTestKey(t, dir, "weak", weakKey)
+	assert.Error(t, utils.LoadJWTKeys(), "expected an error for a weak RSA key")
+}
+
+func jwtBase64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
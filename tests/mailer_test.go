@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/assaidy/blogging_app/internal/mailer"
+)
+
+func TestRenderEscapesData(t *testing.T) {
+	html, err := mailer.Render("verify_email.html", struct {
+		Username  string
+		URL       string
+		ExpiresIn string
+	}{
+		Username:  "<script>alert(1)</script>",
+		URL:       "http://localhost/verify?token=abc",
+		ExpiresIn: "24 hours",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(html, "<script>") {
+		t.Errorf("Expected the username to be escaped, got: %s", html)
+	}
+	if !strings.Contains(html, `href="http://localhost/verify?token=abc"`) {
+		t.Errorf("Expected the verification link, got: %s", html)
+	}
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	if _, err := mailer.Render("unknown.html", nil); err == nil {
+		t.Error("Expected an error for an unknown template")
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := mailer.NewMemoryMailer()
+	msgs := []mailer.Message{
+		{To: "a@example.com", Subject: "first", HTML: "<p>1</p>"},
+		{To: "b@example.com", Subject: "second", HTML: "<p>2</p>"},
+	}
+	for _, msg := range msgs {
+		if err := m.Send(context.Background(), msg); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	got := m.Messages()
+	if len(got) != len(msgs) {
+		t.Fatalf("Expected %d messages, got: %d", len(msgs), len(got))
+	}
+	for i := range msgs {
+		if got[i] != msgs[i] {
+			t.Errorf("Expected: %+v, got: %+v", msgs[i], got[i])
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Send(ctx, msgs[0]); err == nil {
+		t.Error("Expected an error for a canceled context")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mails")
+	m := mailer.NewFileMailer(dir, "app <no-reply@example.com>")
+
+	if err := m.Send(context.Background(), mailer.Message{To: "a@example.com", Subject: "Hello", HTML: "<p>hi</p>"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected 1 email file, got: %v (err: %v)", files, err)
+	}
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{"To: a@example.com\r\n", "Subject: Hello\r\n", "\r\n\r\n<p>hi</p>"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected the email to contain %q, got: %s", expected, content)
+		}
+	}
+}
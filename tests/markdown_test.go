@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/assaidy/blogging_app/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	html, toc, err := utils.RenderMarkdown("# Getting *Started*\n\nSome **bold** text.\n\n## Install `go`\n")
+	assert.NoError(t, err)
+	assert.Contains(t, html, `<h1 id="getting-started">Getting <em>Started</em></h1>`)
+	assert.Contains(t, html, "<strong>bold</strong>")
+	assert.Equal(t, []utils.TocEntry{
+		{Level: 1, ID: "getting-started", Text: "Getting Started"},
+		{Level: 2, ID: "install-go", Text: "Install go"},
+	}, toc)
+}
+
+func TestRenderMarkdownSanitizesHtml(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		forbidden string
+	}{
+		{name: "Script Tag", input: "hello <script>alert(1)</script>", forbidden: "<script"},
+		{name: "Event Handler", input: `<img src="x.png" onerror="alert(1)">`, forbidden: "onerror"},
+		{name: "Javascript Link", input: "[click](javascript:alert(1))", forbidden: "javascript:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, _, err := utils.RenderMarkdown(tt.input)
+			assert.NoError(t, err)
+			if strings.Contains(html, tt.forbidden) {
+				t.Errorf("Expected %q to be sanitized, got: %q", tt.forbidden, html)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdownWithoutHeadings(t *testing.T) {
+	_, toc, err := utils.RenderMarkdown("just a paragraph")
+	assert.NoError(t, err)
+	assert.Empty(t, toc)
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/assaidy/blogging_app/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{name: "Single Mention", input: "thanks @john_doe!", expected: []string{"john_doe"}},
+		{name: "Start Of Text", input: "@alice look at this", expected: []string{"alice"}},
+		{name: "Many Mentions", input: "@alice, @bob and (@carol)", expected: []string{"alice", "bob", "carol"}},
+		{name: "Duplicates", input: "@alice @bob @alice", expected: []string{"alice", "bob"}},
+		{name: "Email Address", input: "mail me at me@example.com", expected: []string{}},
+		{name: "Double At", input: "@@alice", expected: []string{}},
+		{name: "Lone At", input: "meet @ noon", expected: []string{}},
+		{name: "No Mentions", input: "hello world", expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, utils.ExtractMentions(tt.input))
+		})
+	}
+}
@@ -0,0 +1,112 @@
+//go:build integration
+
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/db/postgres_db"
+	"github.com/assaidy/blogging_app/internal/repo"
+	"github.com/assaidy/blogging_app/internal/repo/postgres_repo"
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+)
+
+// TestDigestOnlyNotification checks that a notification of a kind with in-app off and digest on is kept
+// out of the app, but included in the digest. It needs a migrated database: `PG_URL=... go test -tags integration ./tests/...`.
+func TestDigestOnlyNotification(t *testing.T) {
+	queries := postgres_repo.New(postgres_db.DB)
+	createUser := func() uuid.UUID {
+		t.Helper()
+		user, err := queries.CreateUser(context.Background(), postgres_repo.CreateUserParams{
+			Name:            "digest test",
+			Username:        "digest_" + ulid.Make().String()[16:],
+			HashedPassword:  "not a hash",
+			ProfileImageUrl: sql.NullString{},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error creating user: %v", err)
+		}
+		t.Cleanup(func() { queries.DeleteUser(context.Background(), user.ID) })
+		return user.ID
+	}
+	receiverID := createUser()
+	senderID := createUser()
+	since := time.Now().Add(-time.Minute).UTC()
+
+	if err := queries.UpsertNotificationPreference(context.Background(), postgres_repo.UpsertNotificationPreferenceParams{
+		UserID: receiverID,
+		KindID: repo.NotificationKindNewFollower,
+		InApp:  false,
+		Digest: true,
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := queries.CreateNotificationIfWanted(context.Background(), postgres_repo.CreateNotificationIfWantedParams{
+		KindID:   repo.NotificationKindNewFollower,
+		UserID:   receiverID,
+		SenderID: uuid.NullUUID{UUID: senderID, Valid: true},
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	notifications, err := queries.GetAllNotifications(context.Background(), postgres_repo.GetAllNotificationsParams{
+		UserID: receiverID,
+		Limit:  10,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(notifications) != 0 {
+		t.Errorf("Expected no in-app notifications, got: %d", len(notifications))
+	}
+	if count, err := queries.GetUnreadNotificationsCount(context.Background(), receiverID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	} else if count != 0 {
+		t.Errorf("Expected no unread notifications, got: %d", count)
+	}
+	streamed, err := queries.GetAllNotificationsAfter(context.Background(), postgres_repo.GetAllNotificationsAfterParams{
+		UserID: receiverID,
+		Limit:  10,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(streamed) != 0 {
+		t.Errorf("Expected no streamed notifications, got: %d", len(streamed))
+	}
+
+	digestNotifications, err := queries.GetDigestNotifications(context.Background(), postgres_repo.GetDigestNotificationsParams{
+		UserID: receiverID,
+		Limit:  10,
+		Since:  since,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(digestNotifications) != 1 || digestNotifications[0].Kind != "new_follower" {
+		t.Fatalf("Expected the new_follower notification in the digest, got: %+v", digestNotifications)
+	}
+
+	// once in a digest, it's marked as read so it's purged, and isn't in the next digest
+	if err := queries.MarkDigestOnlyNotificationsAsRead(context.Background(), postgres_repo.MarkDigestOnlyNotificationsAsReadParams{
+		UserID:  receiverID,
+		UntilID: digestNotifications[0].ID,
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	digestNotifications, err = queries.GetDigestNotifications(context.Background(), postgres_repo.GetDigestNotificationsParams{
+		UserID: receiverID,
+		Limit:  10,
+		Since:  since,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(digestNotifications) != 0 {
+		t.Errorf("Expected no unread digest notifications, got: %d", len(digestNotifications))
+	}
+}
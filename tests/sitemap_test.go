@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/sitemap"
+)
+
+func TestEncodeURLSet(t *testing.T) {
+	out, err := sitemap.EncodeURLSet([]sitemap.URL{
+		{Loc: "http://localhost/api/v1/users/username/john", LastMod: time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)},
+		{Loc: "http://localhost/api/v1/users/username/john/posts/a&b", LastMod: time.Date(2025, 3, 2, 10, 0, 0, 0, time.FixedZone("", 2*3600))},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+		URLs    []struct {
+			Loc     string `xml:"loc"`
+			LastMod string `xml:"lastmod"`
+		} `xml:"url"`
+	}
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("Expected valid XML, got error: %v\n%s", err, out)
+	}
+	if len(doc.URLs) != 2 {
+		t.Fatalf("Expected 2 urls, got %d", len(doc.URLs))
+	}
+	if doc.URLs[1].Loc != "http://localhost/api/v1/users/username/john/posts/a&b" {
+		t.Errorf("Expected the loc to round trip, got %q", doc.URLs[1].Loc)
+	}
+	if doc.URLs[1].LastMod != "2025-03-02T08:00:00Z" {
+		t.Errorf("Expected the lastmod in UTC, got %q", doc.URLs[1].LastMod)
+	}
+}
+
+func TestEncodeIndex(t *testing.T) {
+	out, err := sitemap.EncodeIndex([]sitemap.URL{
+		{Loc: "http://localhost/sitemaps/1.xml", LastMod: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{Loc: "http://localhost/sitemaps/2.xml", LastMod: time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var doc struct {
+		XMLName  xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+		Sitemaps []struct {
+			Loc string `xml:"loc"`
+		} `xml:"sitemap"`
+	}
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("Expected valid XML, got error: %v\n%s", err, out)
+	}
+	if len(doc.Sitemaps) != 2 || doc.Sitemaps[1].Loc != "http://localhost/sitemaps/2.xml" {
+		t.Errorf("Unexpected sitemaps: %+v", doc.Sitemaps)
+	}
+}
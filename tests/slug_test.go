@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/assaidy/blogging_app/internal/utils"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "Simple", input: "golang", expected: "golang"},
+		{name: "Mixed Case And Spaces", input: "Hello World", expected: "hello-world"},
+		{name: "Punctuation", input: "Go 1.24: what's new?", expected: "go-1-24-what-s-new"},
+		{name: "Outer Separators", input: "  --Hello--  ", expected: "hello"},
+		{name: "Repeated Separators", input: "a  &  b", expected: "a-b"},
+		{name: "Unicode Letters", input: "مرحبا بالعالم", expected: "مرحبا-بالعالم"},
+		{name: "No Letters Or Digits", input: "?!", expected: ""},
+		{name: "Empty", input: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := utils.Slugify(tt.input); got != tt.expected {
+				t.Errorf("Expected: %q, got: %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestUniqueSlug(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		taken    []string
+		expected string
+	}{
+		{name: "Not Taken", base: "hello", taken: nil, expected: "hello"},
+		{name: "Taken", base: "hello", taken: []string{"hello"}, expected: "hello-2"},
+		{name: "Suffixes Taken", base: "hello", taken: []string{"hello", "hello-2", "hello-3"}, expected: "hello-4"},
+		{name: "Gap In Suffixes", base: "hello", taken: []string{"hello", "hello-3"}, expected: "hello-2"},
+		{name: "Only Suffix Taken", base: "hello", taken: []string{"hello-2"}, expected: "hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := utils.UniqueSlug(tt.base, tt.taken); got != tt.expected {
+				t.Errorf("Expected: %q, got: %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/utils"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false}, // cloud metadata
+		{"fd00:ec2::254", false},   // cloud metadata (IPv6)
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:93.184.216.34", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.addr, func(t *testing.T) {
+			if got := utils.IsPublicAddr(netip.MustParseAddr(test.addr)); got != test.expected {
+				t.Errorf("Expected: %v, got: %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckPublicURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		expected bool
+	}{
+		{"public ip", "https://93.184.216.34/hook", true},
+		{"loopback", "http://127.0.0.1:8080/hook", false},
+		{"localhost", "http://localhost/hook", false},
+		{"metadata", "http://169.254.169.254/latest/meta-data", false},
+		{"ipv6 loopback", "http://[::1]/hook", false},
+		{"unsupported scheme", "ftp://93.184.216.34/hook", false},
+		{"missing host", "https:///hook", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := utils.CheckPublicURL(context.Background(), test.url)
+			if got := err == nil; got != test.expected {
+				t.Errorf("Expected valid: %v, got error: %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestPublicHTTPClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := utils.NewPublicHTTPClient(time.Second)
+	resp, err := client.Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("Expected the request to a loopback address to fail")
+	}
+	if !errors.Is(err, utils.ErrNonPublicAddress) {
+		t.Errorf("Expected ErrNonPublicAddress, got: %v", err)
+	}
+}
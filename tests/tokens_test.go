@@ -27,13 +27,15 @@ func TestGenerateJWTAccessToken(t *testing.T) {
 	defer os.Unsetenv("SECRET")
 
 	userID := uuid.New()
-	tokenString, err := utils.GenerateJWTAccessToken(userID)
+	sessionID := uuid.New()
+	tokenString, err := utils.GenerateJWTAccessToken(userID, sessionID)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, tokenString)
 
 	claims, err := utils.ParseJWTTokenString(tokenString)
 	assert.NoError(t, err)
 	assert.Equal(t, userID, claims.UserID)
+	assert.Equal(t, sessionID, claims.SessionID)
 	assert.True(t, claims.ExpiresAt.Time.After(time.Now()))
 }
 
@@ -44,7 +46,7 @@ func TestParseJWTTokenString(t *testing.T) {
 	defer os.Unsetenv("SECRET")
 
 	userID := uuid.New()
-	tokenString, err := utils.GenerateJWTAccessToken(userID)
+	tokenString, err := utils.GenerateJWTAccessToken(userID, uuid.New())
 	assert.NoError(t, err)
 
 	claims, err := utils.ParseJWTTokenString(tokenString)
@@ -53,3 +55,25 @@ func TestParseJWTTokenString(t *testing.T) {
 	_, err = utils.ParseJWTTokenString("invalid-token")
 	assert.Error(t, err)
 }
+
+func TestJWTAccessTokenID(t *testing.T) {
+	os.Setenv("ACCESS_TOKEN_EXPIRATION_MINUTES", "30")
+	os.Setenv("SECRET", "test-secret")
+	defer os.Unsetenv("ACCESS_TOKEN_EXPIRATION_MINUTES")
+	defer os.Unsetenv("SECRET")
+
+	userID, sessionID := uuid.New(), uuid.New()
+	first, err := utils.GenerateJWTAccessToken(userID, sessionID)
+	assert.NoError(t, err)
+	second, err := utils.GenerateJWTAccessToken(userID, sessionID)
+	assert.NoError(t, err)
+
+	firstClaims, err := utils.ParseJWTTokenString(first)
+	assert.NoError(t, err)
+	secondClaims, err := utils.ParseJWTTokenString(second)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, firstClaims.ID)
+	assert.NotEqual(t, firstClaims.ID, secondClaims.ID)
+	assert.NotNil(t, firstClaims.IssuedAt)
+	assert.False(t, firstClaims.IssuedAt.After(time.Now()))
+}
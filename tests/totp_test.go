@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/assaidy/blogging_app/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the SHA1 test vectors of RFC 6238 (appendix B).
+func TestTOTPRFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, test := range tests {
+		step := utils.TOTPStep(time.Unix(test.unix, 0))
+		assert.Equal(t, test.code, utils.HOTP(key, step, 8), "unix time %d", test.unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+	key := []byte("12345678901234567890")
+	code := utils.HOTP(key, utils.TOTPStep(now), utils.TOTPDigits)
+
+	step, ok := utils.ValidateTOTP(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, utils.TOTPStep(now), step)
+
+	// a step of drift is accepted, more isn't
+	_, ok = utils.ValidateTOTP(secret, code, now.Add(utils.TOTPPeriod))
+	assert.True(t, ok)
+	_, ok = utils.ValidateTOTP(secret, code, now.Add(2*utils.TOTPPeriod))
+	assert.False(t, ok)
+
+	// the secret can be entered in lowercase
+	_, ok = utils.ValidateTOTP(strings.ToLower(secret), code, now)
+	assert.True(t, ok)
+
+	_, ok = utils.ValidateTOTP(secret, "000000", now)
+	assert.False(t, ok)
+	_, ok = utils.ValidateTOTP(secret, code[:5], now)
+	assert.False(t, ok)
+	_, ok = utils.ValidateTOTP("not base32!", code, now)
+	assert.False(t, ok)
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := utils.GenerateTOTPSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32) // 20 bytes in base32
+
+	other, err := utils.GenerateTOTPSecret()
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+
+	code := utils.HOTP(mustDecodeBase32(t, secret), utils.TOTPStep(time.Now()), utils.TOTPDigits)
+	_, ok := utils.ValidateTOTP(secret, code, time.Now())
+	assert.True(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(utils.TOTPURI("blogging app", "john", "JBSWY3DPEHPK3PXP"))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/blogging app:john", uri.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", uri.Query().Get("secret"))
+	assert.Equal(t, "blogging app", uri.Query().Get("issuer"))
+	assert.Equal(t, "6", uri.Query().Get("digits"))
+	assert.Equal(t, "30", uri.Query().Get("period"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := utils.GenerateRecoveryCodes()
+	require.NoError(t, err)
+	assert.Len(t, codes, utils.RecoveryCodesCount)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+		assert.False(t, seen[code], "duplicate code %s", code)
+		seen[code] = true
+	}
+
+	hash := utils.HashRecoveryCode(codes[0])
+	assert.Equal(t, hash, utils.HashRecoveryCode(strings.ToUpper(codes[0])))
+	assert.Equal(t, hash, utils.HashRecoveryCode(strings.ReplaceAll(codes[0], "-", "")))
+	assert.NotEqual(t, hash, utils.HashRecoveryCode(codes[1]))
+}
+
+func mustDecodeBase32(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/assaidy/blogging_app/internal/utils"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	payload := []byte(`{"event":"post.published"}`)
+	expected := "sha256=7b75d2efbd85f56f9d78d15cce3ed1b6a66b9deace99aae9bbbef7cc25692285"
+
+	if got := utils.SignWebhookPayload("whsec_test", 1700000000, payload); got != expected {
+		t.Errorf("Expected: %s, got: %s", expected, got)
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	secret := "whsec_test"
+	payload := []byte(`{"event":"post.published"}`)
+	signature := utils.SignWebhookPayload(secret, 1700000000, payload)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		payload   []byte
+		signature string
+		expected  bool
+	}{
+		{name: "Valid Signature", secret: secret, timestamp: 1700000000, payload: payload, signature: signature, expected: true},
+		{name: "Wrong Secret", secret: "whsec_other", timestamp: 1700000000, payload: payload, signature: signature, expected: false},
+		{name: "Wrong Timestamp", secret: secret, timestamp: 1700000001, payload: payload, signature: signature, expected: false},
+		{name: "Tampered Payload", secret: secret, timestamp: 1700000000, payload: []byte(`{"event":"post.updated"}`), signature: signature, expected: false},
+		{name: "Missing Prefix", secret: secret, timestamp: 1700000000, payload: payload, signature: strings.TrimPrefix(signature, "sha256="), expected: false},
+		{name: "Empty Signature", secret: secret, timestamp: 1700000000, payload: payload, signature: "", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := utils.VerifyWebhookSignature(tt.secret, tt.timestamp, tt.payload, tt.signature); got != tt.expected {
+				t.Errorf("Expected: %v, got: %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGenerateWebhookSecret(t *testing.T) {
+	first, err := utils.GenerateWebhookSecret()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	second, err := utils.GenerateWebhookSecret()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(first, "whsec_") || len(first) != len("whsec_")+64 {
+		t.Errorf("Unexpected secret format: %s", first)
+	}
+	if first == second {
+		t.Error("Expected different secrets")
+	}
+}